@@ -0,0 +1,153 @@
+// Package artifact keeps large binary values of brains, e.g. images, audio and documents, in a content-addressed
+// directory, so memory keeps only references to them instead of the values themselves.
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Rovanta/rmodel/codec"
+)
+
+// digestPrefix is the algorithm prefix of digests, the content of an artifact is kept at sha256/<2 hex>/<hex>
+const digestPrefix = "sha256:"
+
+// Ref is a reference to an artifact, it is the memory value set by PutArtifact of brains
+type Ref struct {
+	Name string `json:"name"`
+	// Digest is the digest of the content, e.g. sha256:2cf24d...
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+func init() {
+	// references are read back as Ref from the memories encoded by codecs, e.g. the ones of BrainLite
+	_ = codec.Register("artifact.Ref", Ref{}, codec.JSON)
+}
+
+// RefOf returns the reference in a memory value, which is a Ref, a *Ref, or the JSON view of a Ref,
+// e.g. a reference written by Python processors
+func RefOf(value any) (Ref, bool) {
+	switch v := value.(type) {
+	case Ref:
+		return v, v.Digest != ""
+	case *Ref:
+		if v == nil {
+			return Ref{}, false
+		}
+		return *v, v.Digest != ""
+	case map[string]any:
+		ref := Ref{}
+		ref.Name, _ = v["name"].(string)
+		ref.Digest, _ = v["digest"].(string)
+		switch size := v["size"].(type) {
+		case float64:
+			ref.Size = int64(size)
+		case int:
+			ref.Size = int64(size)
+		case int64:
+			ref.Size = size
+		}
+		return ref, ref.Digest != ""
+	default:
+		return Ref{}, false
+	}
+}
+
+// Store is a content-addressed directory of artifacts, the same content is kept once.
+// It is safe for concurrent use by goroutines and processes sharing the directory.
+// Artifacts are never deleted by brains, they may be referenced by the memories of other brains.
+type Store struct {
+	dir string
+}
+
+// NewStore creates a Store in dir, the directory is created by the first Put
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the directory of store
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Put keeps the content of r, and returns the reference to it with name
+func (s *Store) Put(name string, r io.Reader) (Ref, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return Ref{}, err
+	}
+	// the content is written to a temporary file first, and moved to its digest path when it is complete
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return Ref{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Ref{}, fmt.Errorf("unable to write artifact %s: %v", name, err)
+	}
+
+	ref := Ref{Name: name, Digest: digestPrefix + hex.EncodeToString(h.Sum(nil)), Size: size}
+	path, _ := s.Path(ref.Digest)
+	if _, err = os.Stat(path); err == nil {
+		return ref, nil
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Ref{}, err
+	}
+	if err = os.Chmod(tmp.Name(), 0o644); err != nil {
+		return Ref{}, err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return Ref{}, fmt.Errorf("unable to store artifact %s: %v", name, err)
+	}
+
+	return ref, nil
+}
+
+// Open opens the content of digest, the caller closes it
+func (s *Store) Open(digest string) (io.ReadCloser, error) {
+	path, err := s.Path(digest)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open artifact %s: %v", digest, err)
+	}
+
+	return f, nil
+}
+
+// OpenMemory opens the content of the artifact referenced by value, the value of memory name, the caller closes it
+func (s *Store) OpenMemory(name string, value any) (io.ReadCloser, error) {
+	ref, ok := RefOf(value)
+	if !ok {
+		return nil, fmt.Errorf("memory %s is not an artifact", name)
+	}
+
+	return s.Open(ref.Digest)
+}
+
+// Path returns the path of the content of digest
+func (s *Store) Path(digest string) (string, error) {
+	hexDigest := strings.TrimPrefix(digest, digestPrefix)
+	if len(hexDigest) != 2*sha256.Size || len(hexDigest) == len(digest) {
+		return "", fmt.Errorf("invalid artifact digest %q", digest)
+	}
+	if _, err := hex.DecodeString(hexDigest); err != nil {
+		return "", fmt.Errorf("invalid artifact digest %q", digest)
+	}
+
+	return filepath.Join(s.dir, "sha256", hexDigest[:2], hexDigest), nil
+}
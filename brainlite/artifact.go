@@ -0,0 +1,27 @@
+package brainlite
+
+import (
+	"io"
+
+	"github.com/Rovanta/rmodel/internal/errors"
+)
+
+// PutArtifact writes the content of r to the artifact directory of brain and sets memory name to its artifact.Ref,
+// which memory keeps in place of the content
+func (b *BrainLite) PutArtifact(name string, r io.Reader) error {
+	return b.putArtifact("", name, r)
+}
+
+func (b *BrainLite) putArtifact(neuronID, name string, r io.Reader) error {
+	ref, err := b.BrainMemory.artifacts.Put(name, r)
+	if err != nil {
+		return errors.Wrapf(err, "put artifact %s failed", name)
+	}
+
+	return b.setMemory(neuronID, name, ref)
+}
+
+// OpenArtifact opens the artifact which memory name references, the caller closes it
+func (b *BrainLite) OpenArtifact(name string) (io.ReadCloser, error) {
+	return b.BrainMemory.artifacts.OpenMemory(name, b.GetMemory(name))
+}
@@ -1,16 +1,23 @@
 package brainlite
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
+	"github.com/Rovanta/rmodel/artifact"
+	"github.com/Rovanta/rmodel/codec"
 	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/interrupt"
+	"github.com/Rovanta/rmodel/internal/queue"
 	"github.com/Rovanta/rmodel/internal/utils"
-	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/processor"
 )
 
 const (
@@ -23,14 +30,7 @@ const (
 )
 
 func BuildBrain(blueprint core.Blueprint, withOpts ...Option) *BrainLite {
-	b := &BrainLite{
-		id:      utils.GenID(),
-		labels:  utils.LabelsDeepCopy(blueprint.GetLabels()),
-		state:   core.BrainStateShutdown,
-		neurons: make(map[string]*neuron),
-		links:   make(map[string]*link),
-	}
-	b.cond = sync.NewCond(&b.mu)
+	b := newBrain(blueprint.GetLabels())
 
 	for _, l := range blueprint.ListLinks() {
 		lk := newLink(l)
@@ -41,7 +41,32 @@ func BuildBrain(blueprint core.Blueprint, withOpts ...Option) *BrainLite {
 		b.neurons[neu.id] = neu
 	}
 
-	// init config
+	b.BrainMemory.reducers = blueprint.ListMemoryReducers()
+	b.init(withOpts...)
+
+	if err := blueprint.Err(); err != nil {
+		b.logger.Error().Err(err).Interface("blueprint", blueprint).Msg("brain build with invalid blueprint")
+	} else {
+		b.logger.Info().Interface("blueprint", blueprint).Msg("brain build success")
+	}
+	return b
+}
+
+func newBrain(labels map[string]string) *BrainLite {
+	b := &BrainLite{
+		id:      utils.GenID(),
+		labels:  utils.LabelsDeepCopy(labels),
+		state:   core.BrainStateShutdown,
+		neurons: make(map[string]*neuron),
+		links:   make(map[string]*link),
+	}
+	b.cond = sync.NewCond(&b.mu)
+
+	return b
+}
+
+// init sets default config of brain, then applies options
+func (b *BrainLite) init(withOpts ...Option) {
 	b.logger = zerolog.New(zerolog.ConsoleWriter{
 		Out:        os.Stdout,
 		TimeFormat: time.RFC3339,
@@ -64,16 +89,22 @@ func BuildBrain(blueprint core.Blueprint, withOpts ...Option) *BrainLite {
 	}).With().Caller().Timestamp().Logger().Level(zerolog.InfoLevel)
 	b.BrainMaintainer.nQueueLen = defaultNQueueLen
 	b.BrainMaintainer.nWorkerNum = defaultNWorkerNum
-	b.BrainMemory.datasourceName = fmt.Sprintf("%s.db", b.id)
+	b.BrainMemory.codecs = codec.Default
+	b.BrainMemory.sweepInterval = defaultSweepInterval
 
 	for _, opt := range withOpts {
 		opt.apply(b)
 	}
 
-	b.logger = b.logger.With().Str("brainID", b.id).Logger()
+	if b.BrainMemory.datasourceName == "" {
+		b.BrainMemory.datasourceName = filepath.Join(b.BrainMemory.dataDir, fmt.Sprintf("%s.db", b.id))
+	}
+	if b.BrainMemory.artifacts == nil {
+		b.BrainMemory.artifacts = artifact.NewStore(filepath.Join(b.BrainMemory.dataDir, "artifacts"))
+	}
+	b.BrainMemory.brainID = b.id
 
-	b.logger.Info().Interface("blueprint", blueprint).Msg("brain build success")
-	return b
+	b.logger = b.logger.With().Str("brainID", b.id).Logger()
 }
 
 func BuildMultiLangBrain(blueprint core.MultiLangBlueprint, withOpts ...Option) *BrainLite {
@@ -86,6 +117,9 @@ type BrainLite struct {
 
 	neurons map[string]*neuron
 	links   map[string]*link
+	// topoMu guards the topology and the states, a maintainer step holds read lock, Apply holds write lock,
+	// and the states changed out of the maintainer, by the triggers and the neuron workers, are written with write lock
+	topoMu sync.RWMutex
 
 	// brain is in the Running state when there are 1 or more Activate neuron or 1 or more StandBy link.
 	state core.BrainState
@@ -99,16 +133,46 @@ type BrainLite struct {
 }
 
 type BrainMaintainer struct {
-	bQueue chan maintainEvent
-	stop   chan struct{}
+	bQueue *queue.Events[maintainEvent]
+	// stop is closed when brain is shutting down, the maintainer flushes the queued events and exits
+	stop chan struct{}
+	// done is closed when the maintainer exits
+	done chan struct{}
+	// stopping is set when brain is shutting down, triggers and neuron activations are not accepted
+	stopping atomic.Bool
+	// lifecycleMu serializes start and shutdown of maintainer
+	lifecycleMu sync.Mutex
+	// number of neurons in process, guarded by mu
+	processing int
+	// memVersion is increased when memory is changed, guarded by mu
+	memVersion uint64
+	// context of the run, it is cancelled when brain is shut down
+	runCtx    context.Context
+	runCancel context.CancelFunc
+	// queued but not handled entries, they are persisted in checkpoint
+	pending queue.Pending[maintainEvent]
+	// activations published while topoMu is held, they are pushed to the neuron queue by flushActivations
+	deferred queue.Deferred[string]
+	// snapshots taken after maintainer steps
+	snapshots snapshotSetting
+	// explanation of the last run, it is taken before brain goes to sleep, guarded by mu
+	explanation *core.Explanation
+	// reachedEnd is set when the run reaches END neuron
+	reachedEnd atomic.Bool
+	// resolving is taken by Resume or Reject, and released after the maintainer handled the interrupt points
+	resolving interrupt.Gate
+	// state restored by Fork, it is continued by Continue
+	restored *checkpoint
 
 	NeuronRunner
 }
 
 type NeuronRunner struct {
-	nQueue     chan string
+	nQueue     *queue.Priority[string]
 	nQueueLen  int
 	nWorkerNum int
+	// nAging is the aging interval of neuron priority
+	nAging time.Duration
 }
 
 func (b *BrainLite) TrigLinks(links ...core.Link) error {
@@ -125,11 +189,13 @@ func (b *BrainLite) TrigLinks(links ...core.Link) error {
 func (b *BrainLite) Entry() error {
 	// get all entry links
 	linkIDs := make([]string, 0)
+	b.topoMu.RLock()
 	for _, l := range b.links {
 		if l.isEntryLink() {
 			linkIDs = append(linkIDs, l.id)
 		}
 	}
+	b.topoMu.RUnlock()
 
 	return b.trigLinks(linkIDs...)
 }
@@ -143,31 +209,23 @@ func (b *BrainLite) EntryWithMemory(keysAndValues ...interface{}) error {
 }
 
 func (b *BrainLite) SetMemory(keysAndValues ...interface{}) error {
+	return b.setMemory("", keysAndValues...)
+}
+
+// setMemory sets memories written by neuron, neuronID is empty if memories are set outside of neurons.
+// The memories are set in one transaction, so the others never see a part of them.
+func (b *BrainLite) setMemory(neuronID string, keysAndValues ...interface{}) error {
 	if len(keysAndValues)%2 != 0 {
 		return fmt.Errorf("key and value are not paired")
 	}
-	if err := b.ensureMemoryInit(); err != nil {
-		return err
-	}
-
-	for i := 0; i < len(keysAndValues); i += 2 {
-		k := keysAndValues[i]
-		v := keysAndValues[i+1]
-		// TODO batch set
-		if err := b.BrainMemory.Set(k, v); err != nil {
-			return errors.Wrapf(err, "set memory failed")
-		}
-		b.logger.Debug().
-			Any("key", k).
-			Any("value", v).
-			Msg("set memory")
-	}
 
-	return nil
+	return b.updateMemory(neuronID, func(tx processor.MemoryTx) error {
+		return tx.SetMemory(keysAndValues...)
+	})
 }
 
 func (b *BrainLite) GetMemory(key any) any {
-	if b.BrainMemory.db == nil {
+	if !b.memoryOpened() {
 		return nil
 	}
 	v, err := b.BrainMemory.Get(key)
@@ -180,7 +238,7 @@ func (b *BrainLite) GetMemory(key any) any {
 }
 
 func (b *BrainLite) ExistMemory(key any) bool {
-	if b.BrainMemory.db == nil {
+	if !b.memoryOpened() {
 		return false
 	}
 
@@ -194,23 +252,105 @@ func (b *BrainLite) ExistMemory(key any) bool {
 }
 
 func (b *BrainLite) DeleteMemory(key any) {
-	if b.BrainMemory.db == nil {
+	b.deleteMemory("", key)
+}
+
+func (b *BrainLite) deleteMemory(neuronID string, key any) {
+	if !b.memoryOpened() {
 		return
 	}
 
-	if err := b.BrainMemory.Del(key); err != nil {
+	err := b.updateMemory(neuronID, func(tx processor.MemoryTx) error {
+		tx.DeleteMemory(key)
+		return nil
+	})
+	if err != nil {
 		b.logger.Error().Err(err).Msg("delete memory failed")
 	}
 }
 
 func (b *BrainLite) ClearMemory() {
-	if b.BrainMemory.db == nil {
+	b.clearMemory("")
+}
+
+func (b *BrainLite) clearMemory(neuronID string) {
+	if !b.memoryOpened() {
 		return
 	}
 
-	if err := b.BrainMemory.Clear(); err != nil {
+	b.BrainMemory.txMu.Lock()
+	err := b.BrainMemory.Clear()
+	b.BrainMemory.txMu.Unlock()
+	if err != nil {
 		b.logger.Error().Err(err).Msg("clear memory failed")
+		return
+	}
+	b.notifyMemory()
+	b.BrainMemory.watchers.Publish(processor.MemoryChange{Cleared: true, NeuronID: neuronID})
+}
+
+// WatchMemory returns a channel of changes of memories of keys, all memories are watched if keys is empty.
+// The channel is closed when ctx is done. Changes are queued for a slow watcher, it does not block the writers.
+func (b *BrainLite) WatchMemory(ctx context.Context, keys ...any) <-chan processor.MemoryChange {
+	return b.BrainMemory.watchers.Watch(ctx, keys...)
+}
+
+// ListMemoryKeys lists keys of all memories of brain, ordered by the types and the texts of keys.
+// Keys of named types are listed as the basic types, and the memories written by Python processors
+// or an older version without the original keys are not listed
+func (b *BrainLite) ListMemoryKeys() ([]any, error) {
+	if !b.memoryOpened() {
+		return []any{}, nil
+	}
+
+	entries, err := b.BrainMemory.listMemory(false)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]any, 0, len(entries))
+	for _, e := range entries {
+		keys = append(keys, e.key)
+	}
+
+	return keys, nil
+}
+
+// RangeMemory calls fn for every memory of brain in the order of ListMemoryKeys, until fn returns false.
+// fn sees the memories when RangeMemory is called, and it may call the memory methods of brain.
+func (b *BrainLite) RangeMemory(fn func(key, value any) bool) error {
+	if !b.memoryOpened() {
+		return nil
+	}
+
+	// memories are read before fn is called, so the read does not block the writes of fn
+	entries, err := b.BrainMemory.listMemory(true)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !fn(e.key, e.value) {
+			return nil
+		}
 	}
+
+	return nil
+}
+
+// DumpMemory copies all memories of brain listed by ListMemoryKeys, a key of bytes is a string in the map
+func (b *BrainLite) DumpMemory() (map[any]any, error) {
+	memories := make(map[any]any)
+	err := b.RangeMemory(func(key, value any) bool {
+		if bs, ok := key.([]byte); ok {
+			key = string(bs)
+		}
+		memories[key] = value
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return memories, nil
 }
 
 func (b *BrainLite) GetState() core.BrainState {
@@ -219,27 +359,78 @@ func (b *BrainLite) GetState() core.BrainState {
 
 func (b *BrainLite) Wait() {
 	// block when brain running
-	b.mu.Lock()
-	for b.state != core.BrainStateSleeping && b.state != core.BrainStateShutdown {
-		b.cond.Wait()
+	_ = b.waitUntil(context.Background(), b.isWaitDone)
+}
+
+// WaitContext blocks until brain is sleeping, shutdown or interrupted, or ctx is done
+func (b *BrainLite) WaitContext(ctx context.Context) error {
+	return b.waitUntil(ctx, b.isWaitDone)
+}
+
+// WaitFor blocks until the memory of key satisfies predicate, or ctx is done, and returns the memory.
+// It does not wait brain to sleep, so it works for a brain which keeps running.
+// predicate gets nil if the memory does not exist, it is checked again whenever memory is changed.
+func (b *BrainLite) WaitFor(ctx context.Context, key any, predicate func(value any) bool) (any, error) {
+	for {
+		b.mu.Lock()
+		version := b.memVersion
+		b.mu.Unlock()
+
+		value := b.GetMemory(key)
+		if predicate(value) {
+			return value, nil
+		}
+		err := b.waitUntil(ctx, func() bool {
+			return b.memVersion != version
+		})
+		if err != nil {
+			return value, err
+		}
 	}
+}
+
+// notifyMemory wakes up the goroutines waiting for memory
+func (b *BrainLite) notifyMemory() {
+	b.mu.Lock()
+	b.memVersion++
+	b.cond.Broadcast()
 	b.mu.Unlock()
 }
 
-func (b *BrainLite) Shutdown() {
-	b.logger.Info().Msg("brain local shutdown")
-	close(b.BrainMaintainer.nQueue)
-	close(b.BrainMaintainer.bQueue)
-	if err := b.BrainMemory.Close(); err != nil {
-		b.logger.Error().Err(err).Msg("close memory failed")
+// Shutdown shuts down brain gracefully: triggers are not accepted, the in-flight processors are waited,
+// the queued events are flushed and the checkpoint is saved, then the memory is closed.
+// When ctx is done before the processors return, their contexts are cancelled and the error of ctx is returned.
+// Shutdown can be called repeatedly, and brain is started again by Entry or TrigLinks after shutdown.
+func (b *BrainLite) Shutdown(ctx context.Context) error {
+	b.lifecycleMu.Lock()
+	defer b.lifecycleMu.Unlock()
+
+	var err error
+	if b.getState() != core.BrainStateShutdown {
+		b.logger.Info().Msg("brain lite shutdown")
+		err = b.stopMaintainer(ctx)
+		// the activations refused while shutting down are kept in checkpoint
+		b.topoMu.RLock()
+		b.saveCheckpoint()
+		b.topoMu.RUnlock()
 	}
-	b.setState(core.BrainStateShutdown)
+	b.BrainMemory.sweeper.Stop()
+	if b.BrainMemory.db != nil {
+		if closeErr := b.BrainMemory.Close(); closeErr != nil {
+			b.logger.Error().Err(closeErr).Msg("close memory failed")
+		}
+	}
+
+	return err
 }
 
 func (b *BrainLite) trigLinks(linkIDs ...string) error {
 	if len(linkIDs) == 0 {
 		return nil
 	}
+	if b.stopping.Load() {
+		return fmt.Errorf("brain %s is shutting down", b.id)
+	}
 
 	if err := b.ensureMemoryInit(); err != nil {
 		// TODO wrap error
@@ -249,46 +440,63 @@ func (b *BrainLite) trigLinks(linkIDs ...string) error {
 	// ensure brain maintainer start
 	b.ensureMaintainerStart()
 
-	// goroutine wait
-	var wg sync.WaitGroup
+	// link states are read by the maintainer step holding the read lock, e.g. for the checkpoint,
+	// so they are changed with the write lock
+	b.topoMu.Lock()
+	events := make([]maintainEvent, 0, len(linkIDs))
 	for _, linkID := range linkIDs {
 		l, ok := b.links[linkID]
-		if !ok {
+		if !ok || l.status.state == core.LinkStateReady {
 			continue
 		}
+		// change link state as ready
+		l.status.state = core.LinkStateReady
+		events = append(events, maintainEvent{
+			kind:   eventKindLink,
+			action: eventActionLinkReady,
+			id:     l.id,
+		})
+	}
+	b.topoMu.Unlock()
 
-		wg.Add(1)
-		go b.trigLink(&wg, l)
+	// the event queue may be full, and the maintainer waits for topoMu while Apply is waiting for it,
+	// so the events are sent without topoMu
+	for _, event := range events {
+		b.publishEvent(event)
 	}
-	wg.Wait()
 
+	b.topoMu.RLock()
 	b.refreshState()
+	b.topoMu.RUnlock()
 
 	return nil
 }
 
-func (b *BrainLite) trigLink(wg *sync.WaitGroup, l *link) {
-	defer wg.Done()
-
-	if l.status.state != core.LinkStateReady {
-		// change link state as ready
-		l.status.state = core.LinkStateReady
-
-		// send maintain event
-		b.publishEvent(maintainEvent{
-			kind:   eventKindLink,
-			action: eventActionLinkReady,
-			id:     l.id,
-		})
+// memoryOpened reports whether the memory is initialized. The memory is initialized by the first write,
+// and by the first read if a store is set by WithMemoryStore, as the store may hold memories already
+func (b *BrainLite) memoryOpened() bool {
+	if b.BrainMemory.db != nil {
+		return true
+	}
+	if b.BrainMemory.newStore == nil {
+		return false
+	}
+	if err := b.ensureMemoryInit(); err != nil {
+		b.logger.Error().Err(err).Msg("init memory failed")
+		return false
 	}
 
-	return
+	return true
 }
 
 func (b *BrainLite) ensureMemoryInit() error {
 	if b.BrainMemory.db != nil {
 		return nil
 	}
+	if err := b.BrainMemory.Init(); err != nil {
+		return err
+	}
+	b.watchStore()
 
-	return b.BrainMemory.Init()
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,252 @@
+package brainlite
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/errors"
+)
+
+const (
+	pendingQueueBrain  = "brain"
+	pendingQueueNeuron = "neuron"
+)
+
+// checkpoint is the runtime state of a brain, it is persisted after every maintainer step
+type checkpoint struct {
+	links   map[string]core.LinkState
+	neurons map[string]core.NeuronState
+	// pending maintain events, value is the count of same events in queue
+	events map[maintainEvent]int
+	// pending neuron activations, value is the count of same neuron in queue
+	activations map[string]int
+}
+
+// Resume rebuilds the brain brainID from the checkpoint in its database, and continues the run where it stopped.
+// The blueprint must describe the same topology as the brain was built from, use core.WithNeuronID and
+// core.WithLinkID to keep neuron and link IDs stable when the blueprint is rebuilt in a new process.
+// Neurons which were activated when the checkpoint was taken will be processed again.
+func Resume(blueprint core.Blueprint, brainID string, withOpts ...Option) (*BrainLite, error) {
+	if err := blueprint.Err(); err != nil {
+		return nil, errors.Wrapf(err, "invalid blueprint")
+	}
+	b := BuildBrain(blueprint, append(withOpts, WithID(brainID))...)
+	// the database file of brain opened by the check is removed if it did not exist,
+	// a database set by WithDatasource may be a URI, and it is checked by the checkpoint only
+	created := false
+	if !b.BrainMemory.shared {
+		_, err := os.Stat(b.BrainMemory.datasourceName)
+		created = os.IsNotExist(err)
+	}
+	if err := b.ensureMemoryInit(); err != nil {
+		return nil, err
+	}
+
+	cp, err := b.BrainMemory.loadCheckpoint()
+	if err == nil {
+		err = b.restoreCheckpoint(cp)
+	}
+	if err != nil {
+		b.keepMemory = !created
+		_ = b.BrainMemory.Close()
+		return nil, errors.Wrapf(err, "brain %s can not be resumed", brainID)
+	}
+	b.logger.Info().
+		Int("pendingEvents", len(cp.events)).
+		Int("pendingActivations", len(cp.activations)).
+		Msg("brain resumed from checkpoint")
+
+	b.continueRun(cp)
+
+	return b, nil
+}
+
+// restoreCheckpoint sets link and neuron states of brain as in checkpoint
+func (b *BrainLite) restoreCheckpoint(cp *checkpoint) error {
+	for id, state := range cp.links {
+		l, ok := b.links[id]
+		if !ok {
+			return errors.Wrapf(errors.ErrLinkNotFound(id), "checkpoint does not match blueprint")
+		}
+		l.status.state = state
+	}
+	for id, state := range cp.neurons {
+		n, ok := b.neurons[id]
+		if !ok {
+			return errors.Wrapf(errors.ErrNeuronNotFound(id), "checkpoint does not match blueprint")
+		}
+		n.status.state = state
+	}
+	for e := range cp.events {
+		if e.kind == eventKindNeuron && b.neurons[e.id] == nil {
+			return errors.Wrapf(errors.ErrNeuronNotFound(e.id), "checkpoint does not match blueprint")
+		}
+		if e.kind == eventKindLink && b.links[e.id] == nil {
+			return errors.Wrapf(errors.ErrLinkNotFound(e.id), "checkpoint does not match blueprint")
+		}
+	}
+	for id := range cp.activations {
+		if b.neurons[id] == nil {
+			return errors.Wrapf(errors.ErrNeuronNotFound(id), "checkpoint does not match blueprint")
+		}
+	}
+
+	return nil
+}
+
+// continueRun starts maintainer, and re-queues the activated neurons and pending queue entries of checkpoint
+func (b *BrainLite) continueRun(cp *checkpoint) {
+	b.ensureMaintainerStart()
+	b.topoMu.RLock()
+	for _, n := range b.neurons {
+		if n.status.state == core.NeuronStateActivated {
+			b.publishEventActivateNeuron(n.id)
+		}
+	}
+	for id, cnt := range cp.activations {
+		if b.neurons[id].status.state == core.NeuronStateActivated {
+			continue
+		}
+		for i := 0; i < cnt; i++ {
+			b.publishEventActivateNeuron(id)
+		}
+	}
+	// the maintainer may put brain to sleep once the state is refreshed, the states are not read after it
+	b.refreshState()
+	b.topoMu.RUnlock()
+
+	// the queues may be full, they are pushed without topoMu
+	b.flushActivations()
+	for e, cnt := range cp.events {
+		for i := 0; i < cnt; i++ {
+			b.publishEvent(e)
+		}
+	}
+}
+
+// saveCheckpoint persists current runtime state of brain, topoMu must be held
+func (b *BrainLite) saveCheckpoint() {
+	if b.BrainMemory.db == nil {
+		return
+	}
+
+	cp := &checkpoint{
+		links:   make(map[string]core.LinkState, len(b.links)),
+		neurons: make(map[string]core.NeuronState, len(b.neurons)),
+	}
+	for id, l := range b.links {
+		cp.links[id] = l.status.state
+	}
+	for id, n := range b.neurons {
+		cp.neurons[id] = n.status.state
+	}
+	cp.events, cp.activations = b.pending.Copy()
+
+	if err := b.BrainMemory.saveCheckpoint(cp); err != nil {
+		b.logger.Error().Err(err).Msg("save checkpoint failed")
+	}
+}
+
+func (m *BrainMemory) saveCheckpoint(cp *checkpoint) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("An error occurred while beginning transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		"DELETE FROM checkpoint_link WHERE brain_id = ?",
+		"DELETE FROM checkpoint_neuron WHERE brain_id = ?",
+		"DELETE FROM checkpoint_pending WHERE brain_id = ?",
+	} {
+		if _, err = tx.Exec(stmt, m.brainID); err != nil {
+			return fmt.Errorf("An error occurred while clearing checkpoint: %v", err)
+		}
+	}
+	for id, state := range cp.links {
+		if _, err = tx.Exec("INSERT INTO checkpoint_link (brain_id, id, state) VALUES (?, ?, ?)", m.brainID, id, string(state)); err != nil {
+			return fmt.Errorf("Error while storing link state: %v", err)
+		}
+	}
+	for id, state := range cp.neurons {
+		if _, err = tx.Exec("INSERT INTO checkpoint_neuron (brain_id, id, state) VALUES (?, ?, ?)", m.brainID, id, string(state)); err != nil {
+			return fmt.Errorf("Error while storing neuron state: %v", err)
+		}
+	}
+	for e, cnt := range cp.events {
+		if _, err = tx.Exec("INSERT INTO checkpoint_pending (brain_id, queue, kind, action, id, count) VALUES (?, ?, ?, ?, ?, ?)",
+			m.brainID, pendingQueueBrain, string(e.kind), string(e.action), e.id, cnt); err != nil {
+			return fmt.Errorf("Error while storing pending event: %v", err)
+		}
+	}
+	for id, cnt := range cp.activations {
+		if _, err = tx.Exec("INSERT INTO checkpoint_pending (brain_id, queue, kind, action, id, count) VALUES (?, ?, ?, ?, ?, ?)",
+			m.brainID, pendingQueueNeuron, "", "", id, cnt); err != nil {
+			return fmt.Errorf("Error while storing pending activation: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *BrainMemory) loadCheckpoint() (*checkpoint, error) {
+	cp := &checkpoint{
+		links:       make(map[string]core.LinkState),
+		neurons:     make(map[string]core.NeuronState),
+		events:      make(map[maintainEvent]int),
+		activations: make(map[string]int),
+	}
+
+	if err := m.queryStates("SELECT id, state FROM checkpoint_link WHERE brain_id = ?", func(id, state string) {
+		cp.links[id] = core.LinkState(state)
+	}); err != nil {
+		return nil, err
+	}
+	if err := m.queryStates("SELECT id, state FROM checkpoint_neuron WHERE brain_id = ?", func(id, state string) {
+		cp.neurons[id] = core.NeuronState(state)
+	}); err != nil {
+		return nil, err
+	}
+	if len(cp.links) == 0 && len(cp.neurons) == 0 {
+		return nil, fmt.Errorf("no checkpoint found")
+	}
+
+	rows, err := m.db.Query("SELECT queue, kind, action, id, count FROM checkpoint_pending WHERE brain_id = ?", m.brainID)
+	if err != nil {
+		return nil, fmt.Errorf("An error occurred while querying pending queue: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var queue, kind, action, id string
+		var cnt int
+		if err = rows.Scan(&queue, &kind, &action, &id, &cnt); err != nil {
+			return nil, fmt.Errorf("An error occurred while parsing pending queue: %v", err)
+		}
+		switch queue {
+		case pendingQueueBrain:
+			cp.events[maintainEvent{kind: eventKind(kind), action: eventAction(action), id: id}] += cnt
+		case pendingQueueNeuron:
+			cp.activations[id] += cnt
+		}
+	}
+
+	return cp, rows.Err()
+}
+
+func (m *BrainMemory) queryStates(query string, fn func(id, state string)) error {
+	rows, err := m.db.Query(query, m.brainID)
+	if err != nil {
+		return fmt.Errorf("An error occurred while querying checkpoint: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, state string
+		if err = rows.Scan(&id, &state); err != nil {
+			return fmt.Errorf("An error occurred while parsing checkpoint: %v", err)
+		}
+		fn(id, state)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,94 @@
+package brainlite
+
+import (
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/explain"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// Explain explains the state of brain, e.g. why the last run went to sleep without reaching END.
+// It reports the partially satisfied trigger groups and the last cast of every neuron,
+// and the trigger groups which can never become ready by the topology.
+func (b *BrainLite) Explain() core.Explanation {
+	b.mu.Lock()
+	last, state := b.explanation, b.state
+	b.mu.Unlock()
+	// states of links and neurons are reset when brain went to sleep, the explanation was taken before
+	if last != nil && state == core.BrainStateSleeping {
+		return *last
+	}
+
+	b.topoMu.RLock()
+	defer b.topoMu.RUnlock()
+
+	return b.explain()
+}
+
+// saveExplanation keeps the explanation of the run before brain goes to sleep, topoMu must be held
+func (b *BrainLite) saveExplanation() {
+	exp := b.explain()
+	exp.State = core.BrainStateSleeping
+	b.mu.Lock()
+	b.explanation = &exp
+	b.mu.Unlock()
+}
+
+// explain explains the current state of brain, topoMu must be held
+func (b *BrainLite) explain() core.Explanation {
+	t := b.topology()
+
+	return core.Explanation{
+		State:                b.getState(),
+		ReachedEnd:           b.reachedEnd.Load(),
+		Neurons:              explain.Neurons(t),
+		BlockedTriggerGroups: explain.BlockedTriggerGroups(t),
+	}
+}
+
+// topology takes links and neurons of brain with their states, topoMu must be held
+func (b *BrainLite) topology() explain.Topology {
+	t := explain.Topology{
+		Neurons: make(map[string]*explain.Neuron, len(b.neurons)),
+		Links:   make(map[string]*explain.Link, len(b.links)),
+	}
+	for id, l := range b.links {
+		t.Links[id] = &explain.Link{
+			ID:    l.id,
+			From:  l.spec.from,
+			To:    l.spec.to,
+			State: l.status.state,
+		}
+	}
+	for id, n := range b.neurons {
+		_, noSelector := n.spec.selector.(*processor.DefaultSelector)
+		en := &explain.Neuron{
+			ID:            n.id,
+			State:         n.status.state,
+			Cast:          n.status.cast,
+			TriggerGroups: make([][]string, 0, len(n.spec.triggerGroups)),
+			CastGroups:    n.castGroupIDs(),
+			HasSelector:   !noSelector,
+		}
+		for _, links := range n.spec.triggerGroups {
+			en.TriggerGroups = append(en.TriggerGroups, linkIDs(links))
+		}
+		t.Neurons[id] = en
+	}
+
+	return t
+}
+
+// castExplanation explains a cast of neuron with the selected cast group
+func castExplanation(n *neuron, selectedGroup string) *core.CastExplanation {
+	return explain.Cast(n.castGroupIDs(), selectedGroup)
+}
+
+// castGroupIDs maps name of cast group to IDs of its links
+func (n *neuron) castGroupIDs() map[string][]string {
+	groups := make(map[string][]string, len(n.spec.castGroups))
+	for name, links := range n.spec.castGroups {
+		groups[name] = linkIDs(links)
+	}
+
+	return groups
+}
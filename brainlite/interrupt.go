@@ -0,0 +1,120 @@
+package brainlite
+
+import (
+	"fmt"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/interrupt"
+)
+
+// ListInterrupts lists the interrupt points where the run is parked
+func (b *BrainLite) ListInterrupts() []core.Interrupt {
+	b.topoMu.RLock()
+	defer b.topoMu.RUnlock()
+	ret := make([]core.Interrupt, 0)
+	for _, n := range b.neurons {
+		point, ok := interrupt.PointOf(n.status.state)
+		if !ok {
+			continue
+		}
+		ret = append(ret, core.Interrupt{
+			RunID:    b.id,
+			NeuronID: n.id,
+			Point:    point,
+			Memory:   b.newBrainContext(n.id),
+		})
+	}
+
+	return ret
+}
+
+// Resume sets memory of the run, then the neurons interrupted before activation are activated,
+// and the neurons interrupted after processing cast. The run ID of BrainLite is its brain ID.
+func (b *BrainLite) Resume(runID string, keysAndValues ...any) error {
+	if err := b.takeInterrupts(runID); err != nil {
+		return err
+	}
+	if err := b.SetMemory(keysAndValues...); err != nil {
+		// nothing is resumed, the run can be resumed again
+		b.resolving.Release()
+		return err
+	}
+
+	b.logger.Info().Str("runID", runID).Msg("resume interrupted run")
+	b.setState(core.BrainStateRunning)
+	b.publishEvent(maintainEvent{
+		kind:   eventKindBrain,
+		action: eventActionBrainResume,
+		id:     runID,
+	})
+
+	return nil
+}
+
+// Reject drops the interrupt points of the run, the ready in-links of the neurons interrupted before activation
+// are consumed, and the neurons interrupted after processing do not cast.
+func (b *BrainLite) Reject(runID string) error {
+	if err := b.takeInterrupts(runID); err != nil {
+		return err
+	}
+
+	b.logger.Info().Str("runID", runID).Msg("reject interrupted run")
+	b.setState(core.BrainStateRunning)
+	b.publishEvent(maintainEvent{
+		kind:   eventKindBrain,
+		action: eventActionBrainReject,
+		id:     runID,
+	})
+
+	return nil
+}
+
+// takeInterrupts takes the interrupt points of the run of brain for Resume or Reject
+func (b *BrainLite) takeInterrupts(runID string) error {
+	if runID != b.id {
+		return fmt.Errorf("run %s not found in brain %s", runID, b.id)
+	}
+
+	return b.resolving.Take(runID, func() bool {
+		return len(b.ListInterrupts()) > 0
+	})
+}
+
+func (b *BrainLite) resumeInterrupts() {
+	for _, n := range b.neurons {
+		switch n.status.state {
+		case core.NeuronStateInterruptedBefore:
+			n.status.state = core.NeuronStateInactive
+			b.publishEventActivateNeuron(n.id)
+		case core.NeuronStateInterruptedAfter:
+			n.status.state = core.NeuronStateInactive
+			b.publishEvent(maintainEvent{
+				kind:   eventKindNeuron,
+				action: eventActionNeuronTryCast,
+				id:     n.id,
+			})
+		}
+	}
+}
+
+func (b *BrainLite) rejectInterrupts() {
+	for _, n := range b.neurons {
+		switch n.status.state {
+		case core.NeuronStateInterruptedBefore:
+			// consume the ready in-links, as if neuron was activated
+			for _, links := range n.spec.triggerGroups {
+				for _, l := range links {
+					l.status.state = core.LinkStateInit
+				}
+			}
+			n.status.state = core.NeuronStateInactive
+		case core.NeuronStateInterruptedAfter:
+			for _, links := range n.spec.castGroups {
+				for _, l := range links {
+					l.status.state = core.LinkStateInit
+				}
+			}
+			n.status.state = core.NeuronStateInactive
+		}
+	}
+}
@@ -46,3 +46,14 @@ func (l *link) isEntryLink() bool {
 
 	return false
 }
+
+// clone copies link with initial status
+func (l *link) clone() *link {
+	return &link{
+		id:   l.id,
+		spec: l.spec,
+		status: linkStatus{
+			state: core.LinkStateInit,
+		},
+	}
+}
@@ -1,15 +1,19 @@
 package brainlite
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/Rovanta/rmodel/core"
 	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/internal/queue"
 	"github.com/Rovanta/rmodel/processor"
 )
 
 func (b *BrainLite) ensureMaintainerStart() {
+	b.lifecycleMu.Lock()
+	defer b.lifecycleMu.Unlock()
 	if b.getState() == core.BrainStateShutdown {
 		b.maintainerStart()
 		b.setState(core.BrainStateSleeping)
@@ -23,29 +27,120 @@ func (b *BrainLite) maintainerStart() {
 		Int("neuronWorkerNum", b.nWorkerNum).
 		Int("neuronQueueLen", b.nQueueLen).
 		Msg("brain maintainer start")
-	if b.getState() != core.BrainStateShutdown {
-		b.Shutdown()
-	}
 
 	// new
-	b.nQueue = make(chan string, b.nQueueLen)
-	b.bQueue = make(chan maintainEvent, bQueueLen)
+	b.pending.Reset()
+	b.bQueue = queue.NewEvents[maintainEvent](bQueueLen)
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	b.mu.Lock()
+	b.runCtx, b.runCancel = ctx, cancel
+	b.mu.Unlock()
 
+	b.nQueue = queue.NewPriority[string](b.nQueueLen, b.nAging)
 	for i := 0; i < b.nWorkerNum; i++ {
-		go b.runNeuronWorker()
+		go b.runNeuronWorker(b.nQueue, b.stop)
 	}
-	go b.runBrainMaintainer()
+	go b.runBrainMaintainer(b.bQueue, b.stop, b.done)
 
 }
 
-func (b *BrainLite) runBrainMaintainer() {
-	for msg := range b.bQueue {
+func (b *BrainLite) runBrainMaintainer(bQueue *queue.Events[maintainEvent], stop, done chan struct{}) {
+	defer close(done)
+	bQueue.Run(stop, func(msg maintainEvent) {
+		b.pending.DoneEvent(msg)
 		b.maintain(msg)
+	})
+}
+
+// stopMaintainer stops accepting triggers, waits the in-flight processors, flushes the queued events,
+// then stops maintainer. When ctx is done before the processors return, their contexts are cancelled.
+func (b *BrainLite) stopMaintainer(ctx context.Context) error {
+	b.stopping.Store(true)
+	defer b.stopping.Store(false)
+
+	err := b.waitUntil(ctx, func() bool {
+		return b.processing == 0
+	})
+	if err != nil {
+		b.logger.Warn().Err(err).Msg("in-flight processors are cancelled")
 	}
+	b.runCancel()
+	close(b.stop)
+	<-b.done
+	b.setState(core.BrainStateShutdown)
+
+	return err
+}
+
+// waitUntil blocks until cond is true or ctx is done, cond is checked with mu held
+// when state of brain or number of processing neurons changes
+func (b *BrainLite) waitUntil(ctx context.Context, cond func() bool) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// wake up the waiting goroutine
+			b.mu.Lock()
+			b.cond.Broadcast()
+			b.mu.Unlock()
+		case <-done:
+		}
+	}()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for !cond() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b.cond.Wait()
+	}
+
+	return nil
+}
+
+// isWaitDone reports whether brain is sleeping, shutdown or interrupted, mu must be held
+func (b *BrainLite) isWaitDone() bool {
+	return b.state == core.BrainStateSleeping || b.state == core.BrainStateShutdown || b.state == core.BrainStateInterrupted
+}
+
+// runContext returns context of the run, it is cancelled when brain is shut down
+func (b *BrainLite) runContext() context.Context {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.runCtx == nil {
+		return context.Background()
+	}
+
+	return b.runCtx
+}
+
+func (b *BrainLite) startProcessing() {
+	b.mu.Lock()
+	b.processing++
+	b.mu.Unlock()
+}
+
+func (b *BrainLite) doneProcessing() {
+	b.mu.Lock()
+	b.processing--
+	b.cond.Broadcast()
+	b.mu.Unlock()
 }
 
 func (b *BrainLite) maintain(event maintainEvent) {
 	b.logger.Debug().Interface("event", event).Msg("got a maintain event")
+	// the activations of the step are pushed after topoMu is released
+	defer b.flushActivations()
+	// the topology is not changed in a maintainer step
+	b.topoMu.RLock()
+	defer b.topoMu.RUnlock()
+	// persist runtime state after every step, so the run can be resumed
+	defer b.takeSnapshot(event)
+	defer b.saveCheckpoint()
 
 	switch event.kind {
 	case eventKindLink:
@@ -59,7 +154,7 @@ func (b *BrainLite) maintain(event maintainEvent) {
 			return
 		}
 	case eventKindBrain:
-		if err := b.handleBrainEvent(event.action); err != nil {
+		if err := b.handleBrainEvent(event.action, event.id); err != nil {
 			b.logger.Error().Err(err).Msg("handle brain event error")
 			return
 		}
@@ -123,6 +218,9 @@ func (b *BrainLite) handleNeuronEvent(action eventAction, neuronID string) error
 		return b.neuronCast(n, false)
 	case eventActionNeuronCastAnyway:
 		return b.neuronCast(n, true)
+	case eventActionNeuronInterrupt:
+		// do nothing, neuron is parked by worker, and brain state will be refreshed
+		b.logger.Info().Str("neuronID", n.id).Msg("run interrupted after neuron")
 	default:
 		return fmt.Errorf("unsupported neuron action: %s", action)
 	}
@@ -130,13 +228,34 @@ func (b *BrainLite) handleNeuronEvent(action eventAction, neuronID string) error
 	return nil
 }
 
-func (b *BrainLite) handleBrainEvent(action eventAction) error {
+func (b *BrainLite) handleBrainEvent(action eventAction, id string) error {
 	switch action {
 	case eventActionBrainSleep:
+		b.saveExplanation()
 		b.ForceSleep()
 		return nil
 	case eventActionBrainShutdown:
-		b.Shutdown()
+		// shutdown waits the maintainer exits, it can not run in the maintainer
+		go func() {
+			if err := b.Shutdown(context.Background()); err != nil {
+				b.logger.Error().Err(err).Msg("shutdown error")
+			}
+		}()
+		return nil
+	case eventActionBrainResume:
+		b.resumeInterrupts()
+		b.resolving.Release()
+		b.refreshState()
+		return nil
+	case eventActionBrainReject:
+		b.rejectInterrupts()
+		b.resolving.Release()
+		b.refreshState()
+		return nil
+	case eventActionBrainTopologyChanged:
+		b.logger.Info().Str("patchID", id).Msg("topology changed")
+		b.tryActivateAll()
+		b.refreshState()
 		return nil
 	default:
 		return fmt.Errorf("unsupported brain action: %s", action)
@@ -148,6 +267,10 @@ func (b *BrainLite) tryActivateNeuron(n *neuron) error {
 		b.logger.Debug().Str("neuronID", n.id).Msg("neuron already activated")
 		return nil
 	}
+	if n.isInterrupted() {
+		b.logger.Debug().Str("neuronID", n.id).Msg("neuron is interrupted")
+		return nil
+	}
 
 	should := b.ifNeuronShouldActivate(n)
 	if !should {
@@ -158,6 +281,7 @@ func (b *BrainLite) tryActivateNeuron(n *neuron) error {
 	// should END, send brain sleep message
 	if n.id == core.EndNeuronID {
 		b.logger.Info().Msg("arrival at END neuron")
+		b.reachedEnd.Store(true)
 		b.publishEvent(maintainEvent{
 			kind:   eventKindBrain,
 			action: eventActionBrainSleep,
@@ -165,6 +289,13 @@ func (b *BrainLite) tryActivateNeuron(n *neuron) error {
 		return nil
 	}
 
+	// park the run before neuron activated
+	if n.hasLabel(core.NeuronLabelInterruptBefore) {
+		n.status.state = core.NeuronStateInterruptedBefore
+		b.logger.Info().Str("neuronID", n.id).Msg("run interrupted before neuron")
+		return nil
+	}
+
 	b.publishEventActivateNeuron(n.id)
 
 	return nil
@@ -184,14 +315,17 @@ func (b *BrainLite) neuronCast(n *neuron, isCastAnyway bool) error {
 
 	var selectedGroup string
 	if n.spec.selector != nil {
-		selectedGroup = n.spec.selector.Select(&brainContext{
-			b:               b,
-			currentNeuronID: n.id,
-		})
+		var err error
+		selectedGroup, err = processor.Select(n.spec.selector, b.newBrainContext(n.id))
+		// no link is cast if selector failed, and the out-links are reset as usual so the run can end
+		if err != nil {
+			b.logger.Error().Err(err).Str("neuronID", n.id).Msg("select cast group error")
+		}
 	} else {
 		selectedGroup = processor.DefaultCastGroupName
 	}
 
+	n.status.cast = castExplanation(n, selectedGroup)
 	selectedLinks := make(map[string]struct{})
 
 	for _, l := range n.spec.castGroups[selectedGroup] {
@@ -286,16 +420,22 @@ func (b *BrainLite) ifNeuronShouldActivate(neu *neuron) bool {
 }
 
 func (b *BrainLite) refreshState() {
-	inactiveCnt, activateCnt := b.getNeuronCountByState()
+	inactiveCnt, activateCnt, interruptedCnt := b.getNeuronCountByState()
 	initCnt, waitCnt, readyCnt := b.getLinkCountByState()
 
 	b.logger.Debug().
 		Int("neuronInactive", inactiveCnt).
 		Int("neuronActivated", activateCnt).
+		Int("neuronInterrupted", interruptedCnt).
 		Int("linkInit", initCnt).
 		Int("linkWait", waitCnt).
 		Int("linkReady", readyCnt).
 		Msg("refresh brain state by count")
+	// only interrupted neurons left, park the brain until it is resumed or rejected
+	if activateCnt+waitCnt+readyCnt == 0 && interruptedCnt > 0 {
+		b.setState(core.BrainStateInterrupted)
+		return
+	}
 	// send brain sleep message
 	if activateCnt+waitCnt+readyCnt == 0 {
 		b.publishEvent(maintainEvent{
@@ -308,23 +448,32 @@ func (b *BrainLite) refreshState() {
 	}
 }
 
-func (b *BrainLite) getNeuronCountByState() (int, int) {
-	var inactiveCnt, activateCnt int
+func (b *BrainLite) getNeuronCountByState() (int, int, int) {
+	var inactiveCnt, activateCnt, interruptedCnt int
 	for _, neu := range b.neurons {
 		switch neu.status.state {
 		case core.NeuronStateInactive:
 			inactiveCnt++
 		case core.NeuronStateActivated:
 			activateCnt++
+		case core.NeuronStateInterruptedBefore, core.NeuronStateInterruptedAfter:
+			interruptedCnt++
 		}
 	}
 
-	return inactiveCnt, activateCnt
+	return inactiveCnt, activateCnt, interruptedCnt
 }
 
 func (b *BrainLite) getLinkCountByState() (int, int, int) {
 	var initCnt, waitCnt, readyCnt int
 	for _, l := range b.links {
+		// links held by interrupted neurons are parked with them
+		if dest, ok := b.neurons[l.spec.to]; ok && dest.status.state == core.NeuronStateInterruptedBefore {
+			continue
+		}
+		if src, ok := b.neurons[l.spec.from]; ok && src.status.state == core.NeuronStateInterruptedAfter {
+			continue
+		}
 		switch l.status.state {
 		case core.LinkStateInit:
 			initCnt++
@@ -344,7 +493,10 @@ func (b *BrainLite) ForceSleep() {
 	}
 	for _, neu := range b.neurons {
 		neu.status.state = core.NeuronStateInactive
+		neu.status.cast = nil
 	}
+	b.reachedEnd.Store(false)
+	b.resolving.Release()
 	b.setState(core.BrainStateSleeping)
 }
 
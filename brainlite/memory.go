@@ -3,103 +3,263 @@ package brainlite
 import (
 	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"math"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/Rovanta/rmodel/artifact"
+	"github.com/Rovanta/rmodel/codec"
+	"github.com/Rovanta/rmodel/core"
 	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/internal/expiry"
+	"github.com/Rovanta/rmodel/internal/watch"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// busyTimeout is how long a writer waits for the write lock of database
+const busyTimeout = 5 * time.Second
+
+// liveMemory filters out the memories expired at the time of its argument in unix nanoseconds
+const liveMemory = "(expires_at IS NULL OR expires_at > ?)"
+
 type BrainMemory struct {
-	db             *sql.DB
+	db *sql.DB
+	// datasourceName is the SQLite database file or URI, default is ${dataDir}/${brain_id}.db
 	datasourceName string
-	keepMemory     bool
+	// shared is set if datasourceName is set by WithDatasource, the database may hold many brains
+	shared bool
+	// dataDir is the directory of the default database file
+	dataDir string
+	// brainID is the ID of brain, the rows of brain in the tables have it in column brain_id
+	brainID    string
+	keepMemory bool
+	// watchers of memory changes
+	watchers watch.Hub
+	// reducers of memory keys declared on blueprint
+	reducers map[any]core.MemoryReducer
+	// txMu serializes the memory transactions of brain
+	txMu sync.Mutex
+	// artifacts keeps the contents of artifacts, memory keeps their references
+	artifacts *artifact.Store
+	// codecs encodes the values of registered types, so they are read back as the original Go types
+	codecs *codec.Registry
+	// expired counts the memories deleted as their TTL passed
+	expired atomic.Uint64
+	// sweeper runs sweepMemory every sweepInterval after a memory is set with TTL, until brain is shut down
+	sweeper       expiry.Sweeper
+	sweepInterval time.Duration
+	// newStore creates the store keeping memories in place of the memory table, it is set by WithMemoryStore
+	newStore func() (core.MemoryStore, error)
+	store    core.MemoryStore
+	// storeMu is held for writing by the commits of transactions on store, so the reads never see a part of them
+	storeMu sync.RWMutex
+	// expires keeps the expiration times of the memories in store set with TTL
+	expires expiry.Times
+}
+
+// querier is *sql.DB or *sql.Tx, memory is read and written in a transaction by *sql.Tx
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
 }
 
 
 func (m *BrainMemory)Init() error {
-	db, err := sql.Open("sqlite3", m.datasourceName)
+	if !m.shared && m.dataDir != "" {
+		if err := os.MkdirAll(m.dataDir, 0o755); err != nil {
+			return errors.Wrapf(err, "init memory failed")
+		}
+	}
+
+	// transactions take the write lock when they begin, a deferred transaction fails with "database is locked"
+	// instead of waiting, if it upgrades to write while another transaction is writing.
+	// WAL lets the readers go on while a brain or a Python processor is writing,
+	// and the writers wait for each other up to the busy timeout
+	params := fmt.Sprintf("_txlock=immediate&_journal_mode=WAL&_busy_timeout=%d", busyTimeout.Milliseconds())
+	dsn := m.datasourceName + "?" + params
+	if strings.Contains(m.datasourceName, "?") {
+		dsn = m.datasourceName + "&" + params
+	}
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return errors.Wrapf(err, "init memory failed")
 	}
 	m.db = db
 
-	_, err = m.db.Exec(`CREATE TABLE IF NOT EXISTS memory (
-		key INTEGER PRIMARY KEY,
-		value JSON,
-		type TEXT
-	)`)
-	if err != nil {
-		return errors.Wrapf(err, "init memory table failed")
+	if err = m.migrate(); err != nil {
+		_ = m.db.Close()
+		m.db = nil
+		return err
+	}
+	if m.newStore != nil {
+		if m.store, err = m.newStore(); err != nil {
+			_ = m.db.Close()
+			m.db = nil
+			return errors.Wrapf(err, "init memory store failed")
+		}
 	}
 
 	return nil
 }
 
+// dbPath returns the path of the database file, datasourceName may be a URI, e.g. file:brains.db?cache=shared
+func (m *BrainMemory) dbPath() string {
+	path := strings.TrimPrefix(m.datasourceName, "file:")
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	return path
+}
+
 func (m *BrainMemory)Set(key, value any) error {
-	var valueType string
-	var valueJSON []byte
-	var err error
+	if m.store != nil {
+		tx := m.beginStore()
+		if err := tx.set(key, value, 0); err != nil {
+			return err
+		}
+		return tx.commit()
+	}
 
-	hashedKey, err := hashKey(key)
+	return m.set(m.db, key, value, 0)
+}
+
+// set stores value of key, the memory expires after ttl if ttl > 0. It fails if key collides with another key
+func (m *BrainMemory) set(q querier, key, value any, ttl time.Duration) error {
+	k, err := encodeKey(key)
 	if err != nil {
 		return fmt.Errorf("Unable to hash key: %v", err)
 	}
+	if err = m.checkCollision(q, key, k); err != nil {
+		return err
+	}
+	row, err := m.encodeRow(k, value)
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		row.expiresAt = sql.NullInt64{Int64: time.Now().Add(ttl).UnixNano(), Valid: true}
+	}
+
+	_, err = q.Exec(`INSERT OR REPLACE INTO memory (brain_id, key, key_type, key_text, value, type, type_name, encoding, data, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, m.brainID, row.key, row.keyType, row.keyText, row.value, row.valueType, row.typeName, row.encoding, row.data, row.expiresAt)
+	if err != nil {
+		return fmt.Errorf("Error while storing data: %v", err)
+	}
+	// the memory written without the original key by an older version is replaced
+	_, err = q.Exec("DELETE FROM memory WHERE brain_id = ? AND key = ? AND key_type IS NULL", m.brainID, k.legacyHash)
+	if err != nil {
+		return fmt.Errorf("Error while storing data: %v", err)
+	}
+
+	return nil
+}
+
+// encodeRow encodes value of key k to a row of memory table which never expires. The value of a type registered
+// in codecs is also encoded in its own encoding, and value column keeps its JSON view for Python processors
+func (m *BrainMemory) encodeRow(k memoryKey, value any) (memoryRow, error) {
+	row := memoryRow{
+		key:     k.hash,
+		keyType: sql.NullString{String: k.keyType, Valid: true},
+		keyText: sql.NullString{String: k.text, Valid: true},
+	}
 
 	switch value.(type) {
 	case string:
-		valueType = "string"
+		row.valueType = "string"
 	case int, int32, int64, uint32:
-		valueType = "int"
+		row.valueType = "int"
 	case float64:
-		valueType = "float"
+		row.valueType = "float"
 	case bool:
-		valueType = "bool"
+		row.valueType = "bool"
 	default:
-		valueType = "json"
+		row.valueType = "json"
 	}
 
-	valueJSON, err = json.Marshal(value)
-	if err != nil {
-		return fmt.Errorf("Unable to serialize value: %v", err)
+	var err error
+	if row.value, err = json.Marshal(value); err != nil {
+		return row, fmt.Errorf("Unable to serialize value: %v", err)
 	}
 
-	_, err = m.db.Exec("INSERT OR REPLACE INTO memory (key, value, type) VALUES (?, ?, ?)",
-		hashedKey, valueJSON, valueType)
-	if err != nil {
-		return fmt.Errorf("Error while storing data: %v", err)
+	if m.codecs != nil {
+		encoded, ok, err := m.codecs.Encode(value)
+		if err != nil {
+			return row, err
+		}
+		if ok {
+			row.typeName = sql.NullString{String: encoded.TypeName, Valid: true}
+			row.encoding = sql.NullString{String: string(encoded.Encoding), Valid: true}
+			row.data = encoded.Data
+		}
 	}
 
-	return nil
+	return row, nil
 }
 
 func (m *BrainMemory)Get(key any) (any, error) {
-	hashedKey, err 	:= hashKey(key)
+	if m.store != nil {
+		return m.getStore(key)
+	}
+
+	return m.get(m.db, key)
+}
+
+// get reads value of key, it is not found if the stored key collides with key or it is expired
+func (m *BrainMemory) get(q querier, key any) (any, error) {
+	k, err := encodeKey(key)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to hash key: %v", err)
 	}
 
-	var valueJSON []byte
-	var valueType string
-	err = m.db.QueryRow("SELECT value, type FROM memory WHERE key = ?", hashedKey).Scan(&valueJSON, &valueType)
+	// a memory written without the original key by an older version is found by its legacy hash
+	var row memoryRow
+	err = q.QueryRow(`SELECT key_type, key_text, value, type, type_name, encoding, data FROM memory
+		WHERE brain_id = ? AND (key = ? OR key = ? AND key_type IS NULL) AND `+liveMemory+`
+		ORDER BY key_type IS NULL LIMIT 1`, m.brainID, k.hash, k.legacyHash, time.Now().UnixNano()).
+		Scan(&row.keyType, &row.keyText, &row.value, &row.valueType, &row.typeName, &row.encoding, &row.data)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("key not found '%v'", key)
 		}
 		return nil, fmt.Errorf("An error occurred while querying data: %v", err)
 	}
+	if !k.matches(row.keyType, row.keyText) {
+		return nil, fmt.Errorf("key not found '%v', it collides with key %s:%s", key, row.keyType.String, row.keyText.String)
+	}
+
+	return decodeValue(m.codecs, row)
+}
+
+// decodeValue decodes the value of row, the value stored with a type name registered in codecs is decoded
+// to its Go type, otherwise the JSON view is decoded, e.g. a value written by Python processors
+func decodeValue(codecs *codec.Registry, row memoryRow) (any, error) {
+	if row.typeName.Valid && codecs != nil {
+		value, err := codecs.Decode(codec.Encoded{TypeName: row.typeName.String, Encoding: codec.Encoding(row.encoding.String), Data: row.data})
+		if err == nil {
+			return value, nil
+		}
+		// the type may not be registered in this process, the JSON view is returned
+	}
 
 	var value any
-	switch valueType {
+	var err error
+	switch row.valueType {
 	case "string":
-		err = json.Unmarshal(valueJSON, &value)
+		err = json.Unmarshal(row.value, &value)
 	case "int":
 		var intValue int64
-		err = json.Unmarshal(valueJSON, &intValue)
+		err = json.Unmarshal(row.value, &intValue)
 		if err != nil {
 			return nil, err
 		}
@@ -111,14 +271,14 @@ func (m *BrainMemory)Get(key any) (any, error) {
 		}
 	case "float":
 		var floatValue float64
-		err = json.Unmarshal(valueJSON, &floatValue)
+		err = json.Unmarshal(row.value, &floatValue)
 		value = floatValue
 	case "bool":
 		var boolValue bool
-		err = json.Unmarshal(valueJSON, &boolValue)
+		err = json.Unmarshal(row.value, &boolValue)
 		value = boolValue
 	case "json":
-		err = json.Unmarshal(valueJSON, &value)
+		err = json.Unmarshal(row.value, &value)
 	}
 
 	if err != nil {
@@ -128,13 +288,77 @@ func (m *BrainMemory)Get(key any) (any, error) {
 	return value, nil
 }
 
+// memoryEntry is a memory with its original key
+type memoryEntry struct {
+	key   any
+	value any
+}
+
+// listMemory reads the memories with their original keys, ordered by the types and the texts of keys.
+// The memories written without the original keys, by an older version or Python processors, are skipped
+func (m *BrainMemory) listMemory(withValues bool) ([]memoryEntry, error) {
+	if m.store != nil {
+		return m.listStore(withValues)
+	}
+
+	columns := "key_type, key_text"
+	if withValues {
+		columns += ", value, type, type_name, encoding, data"
+	}
+	rows, err := m.db.Query(fmt.Sprintf(`SELECT %s FROM memory WHERE brain_id = ? AND key_type IS NOT NULL AND %s
+		ORDER BY key_type, key_text`, columns, liveMemory), m.brainID, time.Now().UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("An error occurred while querying data: %v", err)
+	}
+	defer rows.Close()
+
+	entries := make([]memoryEntry, 0)
+	for rows.Next() {
+		var row memoryRow
+		dest := []any{&row.keyType, &row.keyText}
+		if withValues {
+			dest = append(dest, &row.value, &row.valueType, &row.typeName, &row.encoding, &row.data)
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("An error occurred while querying data: %v", err)
+		}
+
+		var entry memoryEntry
+		if entry.key, err = decodeKey(row.keyType.String, row.keyText.String); err != nil {
+			return nil, err
+		}
+		if withValues {
+			if entry.value, err = decodeValue(m.codecs, row); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
 func (m *BrainMemory)Del(key any) error {
-	hashedKey, err := hashKey(key)
+	if m.store != nil {
+		tx := m.beginStore()
+		if err := tx.del(key); err != nil {
+			return err
+		}
+		return tx.commit()
+	}
+
+	return m.del(m.db, key)
+}
+
+func (m *BrainMemory) del(q querier, key any) error {
+	k, err := encodeKey(key)
 	if err != nil {
 		return fmt.Errorf("Unable to hash key: %v", err)
 	}
 
-	_, err = m.db.Exec("DELETE FROM memory WHERE key = ?", hashedKey)
+	// the memory of a colliding key is kept
+	_, err = q.Exec(`DELETE FROM memory WHERE brain_id = ? AND (key = ? AND (key_type IS NULL OR (key_type = ? AND key_text = ?))
+		OR key = ? AND key_type IS NULL)`, m.brainID, k.hash, k.keyType, k.text, k.legacyHash)
 	if err != nil {
 		return fmt.Errorf("An error occurred while deleting data: %v", err)
 	}
@@ -143,7 +367,11 @@ func (m *BrainMemory)Del(key any) error {
 }
 
 func (m *BrainMemory)Clear() error {
-	_, err := m.db.Exec("DELETE FROM memory")
+	if m.store != nil {
+		return m.clearStore()
+	}
+
+	_, err := m.db.Exec("DELETE FROM memory WHERE brain_id = ?", m.brainID)
 	if err != nil {
 		return fmt.Errorf("An error occurred while clearing data: %v", err)
 	}
@@ -151,31 +379,176 @@ func (m *BrainMemory)Clear() error {
 	return nil
 }
 
+// Close closes the database and the store. Unless keepMemory is set, the store is cleared, and the rows of brain
+// are deleted from a shared database, and the database file of brain is deleted otherwise
 func (m *BrainMemory)Close() error {
-	if err := m.db.Close(); err != nil {
-		return err
+	var err error
+	if m.store != nil {
+		err = m.closeStore()
+	}
+	if !m.keepMemory && m.shared && err == nil {
+		err = m.deleteBrain()
+	}
+	if closeErr := m.db.Close(); closeErr != nil {
+		return closeErr
 	}
 	m.db = nil
+	if err != nil {
+		return err
+	}
 
-	if !m.keepMemory {
+	if !m.keepMemory && !m.shared {
 		if err := os.Remove(m.datasourceName); err != nil {
 			return fmt.Errorf("Error while deleting database file: %v", err)
 		}
+		// files of WAL are removed by the last connection closed, they may be left by a crashed process
+		for _, suffix := range []string{"-wal", "-shm"} {
+			if err := os.Remove(m.datasourceName + suffix); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("Error while deleting database file: %v", err)
+			}
+		}
 	}
 
-
 	return nil
+}
+
+// deleteBrain deletes the rows of brain from all tables
+func (m *BrainMemory) deleteBrain() error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("An error occurred while beginning transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		"DELETE FROM memory WHERE brain_id = ?",
+		"DELETE FROM checkpoint_link WHERE brain_id = ?",
+		"DELETE FROM checkpoint_neuron WHERE brain_id = ?",
+		"DELETE FROM checkpoint_pending WHERE brain_id = ?",
+		"DELETE FROM snapshot_memory WHERE snapshot_id IN (SELECT id FROM snapshot WHERE brain_id = ?)",
+		"DELETE FROM snapshot WHERE brain_id = ?",
+	} {
+		if _, err = tx.Exec(stmt, m.brainID); err != nil {
+			return fmt.Errorf("An error occurred while deleting brain %s: %v", m.brainID, err)
+		}
+	}
+
+	return tx.Commit()
 }	
 
-func hashKey(key any) (int64, error) {
-    switch key.(type) {
-    case int, int32, int64, uint32, uint64, float64, string, []byte, byte:
-    default:
-        return 0, fmt.Errorf("unsupported key type %T", key)
-    }
-
-    h := sha256.New()
-    h.Write([]byte(fmt.Sprintf("%v", key)))
-    hashBytes := h.Sum(nil)
-    return int64(binary.BigEndian.Uint64(hashBytes[:8])), nil
-}
\ No newline at end of file
+// tags of the original keys stored in key_type
+const (
+	keyTypeString = "string"
+	keyTypeInt    = "int"
+	keyTypeUint   = "uint"
+	keyTypeFloat  = "float"
+	keyTypeBool   = "bool"
+	keyTypeBytes  = "bytes"
+)
+
+// memoryKey is a key of memory, keyType and text are hashed to the primary key, so keys of different types
+// with the same text, e.g. 1 and "1", are different memories. They are stored to decode the original key
+type memoryKey struct {
+	hash    int64
+	keyType string
+	text    string
+	// legacyHash is the hash of fmt.Sprint of key, the primary key of the memories written by older versions
+	legacyHash int64
+}
+
+// matches reports whether a stored key is k, a key written without the original key always matches
+func (k memoryKey) matches(keyType, text sql.NullString) bool {
+	return !keyType.Valid || (keyType.String == k.keyType && text.String == k.text)
+}
+
+// encodeKey encodes key of the types based on strings, integers, floats, bools and byte slices.
+// The text of a byte slice is in base64, and the texts of the others are the same as fmt.Sprint of the basic types
+func encodeKey(key any) (memoryKey, error) {
+	var k memoryKey
+	if key == nil {
+		return k, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.String:
+		k.keyType, k.text = keyTypeString, v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		k.keyType, k.text = keyTypeInt, strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		k.keyType, k.text = keyTypeInt, strconv.FormatUint(v.Uint(), 10)
+	case reflect.Uint, reflect.Uint64:
+		k.keyType, k.text = keyTypeUint, strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float64:
+		k.keyType, k.text = keyTypeFloat, strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case reflect.Bool:
+		k.keyType, k.text = keyTypeBool, strconv.FormatBool(v.Bool())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return k, fmt.Errorf("unsupported key type %T", key)
+		}
+		k.keyType, k.text = keyTypeBytes, base64.StdEncoding.EncodeToString(v.Bytes())
+	default:
+		return k, fmt.Errorf("unsupported key type %T", key)
+	}
+	k.hash = hashKeyText(k.keyType + ":" + k.text)
+	k.legacyHash = hashKeyText(fmt.Sprintf("%v", key))
+
+	return k, nil
+}
+
+// hashKeyText hashes text of key to the primary key of memory, Python processors hash keys the same way
+func hashKeyText(text string) int64 {
+	h := sha256.New()
+	h.Write([]byte(text))
+
+	return int64(binary.BigEndian.Uint64(h.Sum(nil)[:8]))
+}
+
+// decodeKey decodes the original key stored by encodeKey, keys of named types are decoded to the basic types
+func decodeKey(keyType, text string) (any, error) {
+	var key any
+	var err error
+	switch keyType {
+	case keyTypeString:
+		key = text
+	case keyTypeInt:
+		var i int64
+		i, err = strconv.ParseInt(text, 10, 64)
+		key = int(i)
+	case keyTypeUint:
+		key, err = strconv.ParseUint(text, 10, 64)
+	case keyTypeFloat:
+		key, err = strconv.ParseFloat(text, 64)
+	case keyTypeBool:
+		key, err = strconv.ParseBool(text)
+	case keyTypeBytes:
+		key, err = base64.StdEncoding.DecodeString(text)
+	default:
+		return nil, fmt.Errorf("unknown key type %q", keyType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %s:%s: %v", keyType, text, err)
+	}
+
+	return key, nil
+}
+
+// checkCollision checks whether the stored key of the hash of k is another key, an expired key is replaced
+func (m *BrainMemory) checkCollision(q querier, key any, k memoryKey) error {
+	var keyType, text sql.NullString
+	err := q.QueryRow("SELECT key_type, key_text FROM memory WHERE brain_id = ? AND key = ? AND "+liveMemory,
+		m.brainID, k.hash, time.Now().UnixNano()).
+		Scan(&keyType, &text)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("An error occurred while querying data: %v", err)
+	}
+	if !k.matches(keyType, text) {
+		return fmt.Errorf("key %v collides with key %s:%s", key, keyType.String, text.String)
+	}
+
+	return nil
+}
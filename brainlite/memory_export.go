@@ -0,0 +1,51 @@
+package brainlite
+
+import (
+	"io"
+
+	"github.com/Rovanta/rmodel/codec"
+	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// ExportMemory writes the memories of brain listed by ListMemoryKeys to w as a portable JSON document,
+// see codec.Document. The values of the types registered in the codec registry of brain keep their type names.
+func (b *BrainLite) ExportMemory(w io.Writer) error {
+	memories, err := b.DumpMemory()
+	if err != nil {
+		return err
+	}
+	if err = b.documentCodecs().WriteMemory(w, memories); err != nil {
+		return errors.Wrapf(err, "export memory failed")
+	}
+
+	return nil
+}
+
+// ImportMemory sets the memories of a document written by ExportMemory in one transaction, the reducers of keys
+// are not applied and the other memories are kept. A value of a type registered in the codec registry of brain
+// is decoded to the type, and the others are stored as their JSON views.
+func (b *BrainLite) ImportMemory(r io.Reader) error {
+	memories, err := b.documentCodecs().ReadMemory(r)
+	if err != nil {
+		return errors.Wrapf(err, "import memory failed")
+	}
+
+	return b.updateMemory("", func(tx processor.MemoryTx) error {
+		for k, v := range memories {
+			if err := tx.(*memoryTx).put(k, v, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// documentCodecs returns the codec registry of brain, an empty one if it is unset by WithCodecRegistry(nil)
+func (b *BrainLite) documentCodecs() *codec.Registry {
+	if b.BrainMemory.codecs == nil {
+		return codec.NewRegistry()
+	}
+
+	return b.BrainMemory.codecs
+}
@@ -0,0 +1,330 @@
+package brainlite
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/internal/memtx"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// storeKey returns the key in store of memory key, keys of named types are kept as the basic types,
+// the same as the keys of memory table
+func storeKey(key any) (any, error) {
+	k, err := encodeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to hash key: %v", err)
+	}
+
+	return decodeKey(k.keyType, k.text)
+}
+
+// getStore reads value of key in store, it is not found if it is expired
+func (m *BrainMemory) getStore(key any) (any, error) {
+	sk, err := storeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	m.storeMu.RLock()
+	defer m.storeMu.RUnlock()
+
+	return m.readStore(sk)
+}
+
+// readStore reads value of the key in store, storeMu is held
+func (m *BrainMemory) readStore(sk any) (any, error) {
+	if m.expires.Expired(sk, time.Now()) {
+		return nil, fmt.Errorf("key not found '%v'", sk)
+	}
+	value, ok, err := m.store.Get(sk)
+	if err != nil {
+		return nil, fmt.Errorf("An error occurred while querying data: %v", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("key not found '%v'", sk)
+	}
+
+	return value, nil
+}
+
+func (m *BrainMemory) clearStore() error {
+	m.storeMu.Lock()
+	defer m.storeMu.Unlock()
+	if err := m.store.Clear(); err != nil {
+		return fmt.Errorf("An error occurred while clearing data: %v", err)
+	}
+	m.expires.Reset()
+
+	return nil
+}
+
+// closeStore closes the store, it is cleared first unless keepMemory is set
+func (m *BrainMemory) closeStore() error {
+	var err error
+	if !m.keepMemory {
+		err = m.clearStore()
+	}
+	if closeErr := m.store.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("Error while closing memory store: %v", closeErr)
+	}
+	m.store = nil
+	m.expires.Reset()
+
+	return err
+}
+
+// listStore reads the memories in store, ordered by the types and the texts of keys as listMemory.
+// The keys which are not written by brain, e.g. keys of other types, are skipped
+func (m *BrainMemory) listStore(withValues bool) ([]memoryEntry, error) {
+	m.storeMu.RLock()
+	defer m.storeMu.RUnlock()
+	storeKeys, err := m.store.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("An error occurred while querying data: %v", err)
+	}
+
+	type sortedEntry struct {
+		memoryEntry
+		k memoryKey
+	}
+	now := time.Now()
+	sorted := make([]sortedEntry, 0, len(storeKeys))
+	for _, sk := range storeKeys {
+		k, err := encodeKey(sk)
+		if err != nil || m.expires.Expired(sk, now) {
+			continue
+		}
+		e := sortedEntry{k: k}
+		if e.key, err = decodeKey(k.keyType, k.text); err != nil {
+			continue
+		}
+		if withValues {
+			value, ok, err := m.store.Get(sk)
+			if err != nil {
+				return nil, fmt.Errorf("An error occurred while querying data: %v", err)
+			}
+			// it may be dropped by the store meanwhile
+			if !ok {
+				continue
+			}
+			e.value = value
+		}
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].k.keyType != sorted[j].k.keyType {
+			return sorted[i].k.keyType < sorted[j].k.keyType
+		}
+		return sorted[i].k.text < sorted[j].k.text
+	})
+
+	entries := make([]memoryEntry, 0, len(sorted))
+	for _, e := range sorted {
+		entries = append(entries, e.memoryEntry)
+	}
+
+	return entries, nil
+}
+
+// deleteExpiredStore deletes the memories in store expired at now, and returns them.
+// The value of a memory dropped by the store already is nil
+func (m *BrainMemory) deleteExpiredStore(now time.Time) ([]memoryEntry, error) {
+	m.storeMu.Lock()
+	defer m.storeMu.Unlock()
+	expired := make([]memoryEntry, 0)
+	for _, sk := range m.expires.List(now) {
+		value, _, err := m.store.Get(sk)
+		if err == nil {
+			err = m.store.Delete(sk)
+		}
+		if err != nil {
+			return expired, fmt.Errorf("An error occurred while deleting data: %v", err)
+		}
+		// it may be reported by the eviction of store already
+		if m.expires.Unset(sk) {
+			expired = append(expired, memoryEntry{key: sk, value: value})
+		}
+	}
+
+	return expired, nil
+}
+
+// storeRows encodes the memories in store to the rows of memory table, e.g. for snapshots
+func (m *BrainMemory) storeRows() ([]memoryRow, error) {
+	entries, err := m.listStore(true)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]memoryRow, 0, len(entries))
+	for _, e := range entries {
+		k, err := encodeKey(e.key)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to hash key: %v", err)
+		}
+		row, err := m.encodeRow(k, e.value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "encode memory %v failed", e.key)
+		}
+		if at, ok := m.expires.At(e.key); ok {
+			row.expiresAt = sql.NullInt64{Int64: at.UnixNano(), Valid: true}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// restoreStore sets the memories of rows in store, the expired memories and the memories written without
+// the original keys are skipped
+func (m *BrainMemory) restoreStore(rows []memoryRow) error {
+	tx := m.beginStore()
+	for _, row := range rows {
+		if !row.keyType.Valid {
+			continue
+		}
+		var ttl time.Duration
+		if row.expiresAt.Valid {
+			if ttl = time.Until(time.Unix(0, row.expiresAt.Int64)); ttl <= 0 {
+				continue
+			}
+		}
+		key, err := decodeKey(row.keyType.String, row.keyText.String)
+		if err != nil {
+			return err
+		}
+		value, err := decodeValue(m.codecs, row)
+		if err != nil {
+			return err
+		}
+		if err = tx.set(key, value, ttl); err != nil {
+			return err
+		}
+	}
+
+	return tx.commit()
+}
+
+// storeTx keeps the writes of a transaction on store until it is committed, by the keys in store
+type storeTx struct {
+	m      *BrainMemory
+	writes memtx.Writes
+}
+
+func (m *BrainMemory) beginStore() *storeTx {
+	return &storeTx{m: m}
+}
+
+func (tx *storeTx) get(key any) (any, error) {
+	sk, err := storeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if w, ok := tx.writes.Get(sk); ok {
+		if w.Deleted {
+			return nil, fmt.Errorf("key not found '%v'", key)
+		}
+		return w.Value, nil
+	}
+	tx.m.storeMu.RLock()
+	defer tx.m.storeMu.RUnlock()
+
+	return tx.m.readStore(sk)
+}
+
+func (tx *storeTx) set(key, value any, ttl time.Duration) error {
+	return tx.write(memtx.Write{Key: key, Value: value, TTL: ttl})
+}
+
+func (tx *storeTx) del(key any) error {
+	return tx.write(memtx.Write{Key: key, Deleted: true})
+}
+
+func (tx *storeTx) write(w memtx.Write) error {
+	sk, err := storeKey(w.Key)
+	if err != nil {
+		return err
+	}
+	w.Key = sk
+	tx.writes.Put(w)
+
+	return nil
+}
+
+// commit applies the writes to store with storeMu held, all or nothing
+func (tx *storeTx) commit() error {
+	tx.m.storeMu.Lock()
+	defer tx.m.storeMu.Unlock()
+
+	return memtx.Commit(tx.m.store, &tx.m.expires, tx.writes.List())
+}
+
+// rollback drops the writes, nothing is written to store before commit
+func (tx *storeTx) rollback() {
+	tx.writes.Reset()
+}
+
+// watchStore reports the memories dropped by the store and the changes made by the other users of a shared store
+func (b *BrainLite) watchStore() {
+	store := b.BrainMemory.store
+	if evicting, ok := store.(core.EvictingMemoryStore); ok {
+		evicting.OnEvict(b.onStoreEvicted)
+	}
+	if shared, ok := store.(core.SharedMemoryStore); ok {
+		shared.OnChange(b.onStoreChanged)
+	}
+}
+
+// onStoreEvicted reports a memory dropped by the store, the memory dropped after its TTL passed is expired.
+// It may be called by the goroutines of the store with the store locked, so neither the store nor storeMu can be taken
+func (b *BrainLite) onStoreEvicted(sk, value any) {
+	key, err := storeKey(sk)
+	if err != nil {
+		return
+	}
+	if b.BrainMemory.expires.Expired(key, time.Now()) {
+		// it may be deleted by the sweeper already
+		if !b.BrainMemory.expires.Unset(key) {
+			return
+		}
+		b.BrainMemory.expired.Add(1)
+		b.logger.Info().
+			Any("key", key).
+			Msg("memory expired")
+		b.notifyMemory()
+		b.BrainMemory.watchers.Publish(processor.MemoryChange{Key: key, OldValue: value, Expired: true})
+		return
+	}
+	b.BrainMemory.expires.Unset(key)
+	b.logger.Warn().
+		Any("key", key).
+		Msg("memory evicted")
+	b.notifyMemory()
+	b.BrainMemory.watchers.Publish(processor.MemoryChange{Key: key, OldValue: value, Evicted: true})
+}
+
+// onStoreChanged reports a change of memory made by the other users of a shared store
+func (b *BrainLite) onStoreChanged(change core.MemoryStoreChange) {
+	memChange := processor.MemoryChange{Cleared: change.Cleared}
+	switch {
+	case change.Cleared:
+		b.BrainMemory.expires.Reset()
+	default:
+		key, err := storeKey(change.Key)
+		if err != nil {
+			return
+		}
+		// the memory is set or deleted by the other without TTL
+		b.BrainMemory.expires.Unset(key)
+		memChange.Key = key
+		if !change.Deleted {
+			memChange.NewValue = change.Value
+		}
+	}
+	b.notifyMemory()
+	b.BrainMemory.watchers.Publish(memChange)
+}
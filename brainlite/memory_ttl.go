@@ -0,0 +1,120 @@
+package brainlite
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Rovanta/rmodel/processor"
+)
+
+const defaultSweepInterval = 10 * time.Second
+
+// SetMemoryWithTTL sets memory of key with its expiration time, kept in expires_at of the memory table or by
+// the store set by WithMemoryStore. The sweeper of brain deletes it after ttl, see core.Brain.
+func (b *BrainLite) SetMemoryWithTTL(key, value any, ttl time.Duration) error {
+	return b.setMemoryWithTTL("", key, value, ttl)
+}
+
+func (b *BrainLite) setMemoryWithTTL(neuronID string, key, value any, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("ttl %s of memory %v is not positive", ttl, key)
+	}
+
+	return b.updateMemory(neuronID, func(tx processor.MemoryTx) error {
+		return tx.(*memoryTx).set(key, value, ttl)
+	})
+}
+
+// ExpiredMemoryCount counts the memories deleted by the sweeper or dropped by the store after their TTL passed
+func (b *BrainLite) ExpiredMemoryCount() uint64 {
+	return b.BrainMemory.expired.Load()
+}
+
+// sweepMemory deletes the memories whose expiration time passed, then logs, counts and publishes them
+func (b *BrainLite) sweepMemory() {
+	b.BrainMemory.txMu.Lock()
+	if b.BrainMemory.db == nil {
+		b.BrainMemory.txMu.Unlock()
+		return
+	}
+	expired, n, err := b.BrainMemory.deleteExpired(time.Now())
+	b.BrainMemory.txMu.Unlock()
+	if err != nil {
+		b.logger.Error().Err(err).Msg("delete expired memory failed")
+		return
+	}
+	if n == 0 {
+		return
+	}
+
+	b.BrainMemory.expired.Add(uint64(n))
+	for _, e := range expired {
+		b.logger.Info().
+			Any("key", e.key).
+			Msg("memory expired")
+	}
+	// the memories written without the original keys, e.g. by Python processors, are counted only
+	if skipped := n - len(expired); skipped > 0 {
+		b.logger.Info().
+			Int("count", skipped).
+			Msg("memory expired")
+	}
+	b.notifyMemory()
+	for _, e := range expired {
+		b.BrainMemory.watchers.Publish(processor.MemoryChange{Key: e.key, OldValue: e.value, Expired: true})
+	}
+}
+
+// deleteExpired deletes the memories of brain expired at now, it returns the deleted memories with the original keys
+// and the number of all deleted memories
+func (m *BrainMemory) deleteExpired(now time.Time) ([]memoryEntry, int, error) {
+	if m.store != nil {
+		expired, err := m.deleteExpiredStore(now)
+		return expired, len(expired), err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, 0, fmt.Errorf("An error occurred while beginning transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT key_type, key_text, value, type, type_name, encoding, data FROM memory
+		WHERE brain_id = ? AND expires_at <= ?`, m.brainID, now.UnixNano())
+	if err != nil {
+		return nil, 0, fmt.Errorf("An error occurred while querying data: %v", err)
+	}
+	expired := make([]memoryEntry, 0)
+	n := 0
+	for rows.Next() {
+		var row memoryRow
+		if err = rows.Scan(&row.keyType, &row.keyText, &row.value, &row.valueType, &row.typeName, &row.encoding, &row.data); err != nil {
+			_ = rows.Close()
+			return nil, 0, fmt.Errorf("An error occurred while querying data: %v", err)
+		}
+		n++
+		if !row.keyType.Valid {
+			continue
+		}
+		key, err := decodeKey(row.keyType.String, row.keyText.String)
+		if err != nil {
+			continue
+		}
+		// the value is reported only, a value which can not be decoded is reported as nil
+		value, _ := decodeValue(m.codecs, row)
+		expired = append(expired, memoryEntry{key: key, value: value})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("An error occurred while querying data: %v", err)
+	}
+	_ = rows.Close()
+
+	if _, err = tx.Exec("DELETE FROM memory WHERE brain_id = ? AND expires_at <= ?", m.brainID, now.UnixNano()); err != nil {
+		return nil, 0, fmt.Errorf("An error occurred while deleting data: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("An error occurred while committing transaction: %v", err)
+	}
+
+	return expired, n, nil
+}
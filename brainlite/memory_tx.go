@@ -0,0 +1,200 @@
+package brainlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/internal/memtx"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// UpdateMemory runs fn in a SQLite transaction of the memory table, or in a transaction buffered until commit on
+// the store set by WithMemoryStore. It is committed only if fn returns nil, see core.Brain.
+// txMu serializes the transactions, so fn should be short and must not call the memory methods of brain.
+func (b *BrainLite) UpdateMemory(fn func(tx processor.MemoryTx) error) error {
+	return b.updateMemory("", fn)
+}
+
+// CompareAndSwapMemory swaps memory of key to new in one transaction if it deeply equals old, see core.Brain
+func (b *BrainLite) CompareAndSwapMemory(key, old, new any) (bool, error) {
+	return b.compareAndSwapMemory("", key, old, new)
+}
+
+func (b *BrainLite) compareAndSwapMemory(neuronID string, key, old, new any) (bool, error) {
+	return memtx.CompareAndSwap(func(fn func(tx processor.MemoryTx) error) error {
+		return b.updateMemory(neuronID, fn)
+	}, key, old, new)
+}
+
+// updateMemory runs fn in a transaction, the changes are reported as written by neuron of neuronID if it is not empty
+func (b *BrainLite) updateMemory(neuronID string, fn func(tx processor.MemoryTx) error) error {
+	if err := b.ensureMemoryInit(); err != nil {
+		return err
+	}
+
+	b.BrainMemory.txMu.Lock()
+	mem, err := b.BrainMemory.begin()
+	if err != nil {
+		b.BrainMemory.txMu.Unlock()
+		return errors.Wrapf(err, "begin memory transaction failed")
+	}
+
+	tx := &memoryTx{b: b, mem: mem, neuronID: neuronID}
+	if err = fn(tx); err == nil {
+		err = tx.err
+	}
+	if err != nil {
+		mem.rollback()
+		b.BrainMemory.txMu.Unlock()
+		return err
+	}
+	err = mem.commit()
+	if err == nil && tx.expiring {
+		b.BrainMemory.sweeper.Start(b.BrainMemory.sweepInterval, b.sweepMemory)
+	}
+	b.BrainMemory.txMu.Unlock()
+	if err != nil {
+		return errors.Wrapf(err, "commit memory transaction failed")
+	}
+
+	if tx.written {
+		b.notifyMemory()
+	}
+	for _, c := range tx.changes {
+		b.BrainMemory.watchers.Publish(c)
+	}
+
+	return nil
+}
+
+// txMemory reads and writes the memories of a transaction, in the memory table by a SQLite transaction,
+// or in the store set by WithMemoryStore
+type txMemory interface {
+	get(key any) (any, error)
+	set(key, value any, ttl time.Duration) error
+	del(key any) error
+	commit() error
+	rollback()
+}
+
+// begin begins a transaction of memory, txMu is held until it is committed or rolled back
+func (m *BrainMemory) begin() (txMemory, error) {
+	if m.store != nil {
+		return m.beginStore(), nil
+	}
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlTx{m: m, tx: tx}, nil
+}
+
+// sqlTx reads and writes the memory table in a SQLite transaction
+type sqlTx struct {
+	m  *BrainMemory
+	tx *sql.Tx
+}
+
+func (t *sqlTx) get(key any) (any, error) {
+	return t.m.get(t.tx, key)
+}
+
+func (t *sqlTx) set(key, value any, ttl time.Duration) error {
+	return t.m.set(t.tx, key, value, ttl)
+}
+
+func (t *sqlTx) del(key any) error {
+	return t.m.del(t.tx, key)
+}
+
+func (t *sqlTx) commit() error {
+	return t.tx.Commit()
+}
+
+func (t *sqlTx) rollback() {
+	_ = t.tx.Rollback()
+}
+
+// memoryTx reads and writes memory in a transaction
+type memoryTx struct {
+	b        *BrainLite
+	mem      txMemory
+	neuronID string
+	// err is the first error of DeleteMemory, the transaction is rolled back if it is not nil
+	err     error
+	written bool
+	// expiring is set if a memory is set with TTL, the sweeper is started after commit
+	expiring bool
+	// changes for watchers, they are published after commit
+	changes []processor.MemoryChange
+}
+
+func (tx *memoryTx) GetMemory(key any) any {
+	v, _ := tx.mem.get(key)
+
+	return v
+}
+
+func (tx *memoryTx) ExistMemory(key any) bool {
+	_, err := tx.mem.get(key)
+
+	return err == nil
+}
+
+func (tx *memoryTx) SetMemory(keysAndValues ...interface{}) error {
+	return memtx.SetPairs(keysAndValues, func(key, value any) error {
+		return tx.set(key, value, 0)
+	})
+}
+
+// set writes the value of key after its reducer, with ttl if it is positive
+func (tx *memoryTx) set(key, value any, ttl time.Duration) error {
+	value, err := memtx.Reduce(tx.b.BrainMemory.reducers, key, value, tx.GetMemory)
+	if err != nil {
+		return err
+	}
+
+	return tx.put(key, value, ttl)
+}
+
+// put writes value of key skipping its reducer, e.g. for imported memories
+func (tx *memoryTx) put(key, value any, ttl time.Duration) error {
+	// old value is read for watchers only
+	watched := tx.b.BrainMemory.watchers.Watching(key)
+	var old any
+	if watched {
+		old = tx.GetMemory(key)
+	}
+	if err := tx.mem.set(key, value, ttl); err != nil {
+		return errors.Wrapf(err, "set memory failed")
+	}
+	tx.written = true
+	tx.expiring = tx.expiring || ttl > 0
+	if watched {
+		tx.changes = append(tx.changes, processor.MemoryChange{Key: key, OldValue: old, NewValue: value, NeuronID: tx.neuronID})
+	}
+	tx.b.logger.Debug().
+		Any("key", key).
+		Any("value", value).
+		Msg("set memory")
+
+	return nil
+}
+
+func (tx *memoryTx) DeleteMemory(key any) {
+	if tx.err != nil {
+		return
+	}
+
+	old, getErr := tx.mem.get(key)
+	if err := tx.mem.del(key); err != nil {
+		tx.err = errors.Wrapf(err, "delete memory failed")
+		return
+	}
+	tx.written = true
+	if getErr == nil {
+		tx.changes = append(tx.changes, processor.MemoryChange{Key: key, OldValue: old, NeuronID: tx.neuronID})
+	}
+}
@@ -0,0 +1,278 @@
+package brainlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Rovanta/rmodel/internal/errors"
+)
+
+// migration upgrades the schema of database to version
+type migration struct {
+	version int
+	name    string
+	// up runs in the transaction of migration, brainID is the ID of the brain opening the database
+	up func(tx *sql.Tx, brainID string) error
+}
+
+// migrations of the schema in version order, the version of a database is kept in PRAGMA user_version.
+// A migration must never be changed once released, a new column or table is added by a new migration.
+var migrations = []migration{
+	{version: 1, name: "create tables", up: createTables},
+	{version: 2, name: "add brain_id", up: addBrainID},
+	{version: 3, name: "add expires_at", up: addExpiresAt},
+	{version: 4, name: "hash keys with type", up: hashKeysWithType},
+}
+
+// migrate upgrades the schema of database to the latest version. Every migration runs in its own immediate
+// transaction which checks and sets user_version, so it is applied once when many brains open the database together
+func (m *BrainMemory) migrate() error {
+	for _, mg := range migrations {
+		if err := m.applyMigration(mg); err != nil {
+			return errors.Wrapf(err, "migrate memory schema to version %d (%s) failed", mg.version, mg.name)
+		}
+	}
+
+	return nil
+}
+
+func (m *BrainMemory) applyMigration(mg migration) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err = tx.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if latest := migrations[len(migrations)-1].version; version > latest {
+		return fmt.Errorf("schema version %d of database is newer than %d", version, latest)
+	}
+	if version >= mg.version {
+		return nil
+	}
+
+	if err = mg.up(tx, m.brainID); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", mg.version)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// createTables creates the tables of one brain per database, the tables of an older version without
+// user_version may exist, the columns added since then are added to them
+func createTables(tx *sql.Tx, _ string) error {
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS memory (
+			key INTEGER PRIMARY KEY,
+			key_type TEXT,
+			key_text TEXT,
+			value JSON,
+			type TEXT,
+			type_name TEXT,
+			encoding TEXT,
+			data BLOB
+		)`,
+		`CREATE TABLE IF NOT EXISTS checkpoint_link (
+			id TEXT PRIMARY KEY,
+			state TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS checkpoint_neuron (
+			id TEXT PRIMARY KEY,
+			state TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS checkpoint_pending (
+			queue TEXT,
+			kind TEXT,
+			action TEXT,
+			id TEXT,
+			count INTEGER
+		)`,
+		`CREATE TABLE IF NOT EXISTS snapshot (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			brain_id TEXT,
+			step TEXT,
+			created_at INTEGER,
+			links JSON,
+			neurons JSON,
+			pending JSON
+		)`,
+		`CREATE TABLE IF NOT EXISTS snapshot_memory (
+			snapshot_id INTEGER,
+			key INTEGER,
+			key_type TEXT,
+			key_text TEXT,
+			value JSON,
+			type TEXT,
+			type_name TEXT,
+			encoding TEXT,
+			data BLOB,
+			PRIMARY KEY (snapshot_id, key)
+		)`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range []string{"memory", "snapshot_memory"} {
+		if err := addMemoryColumns(tx, table); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addBrainID adds brain_id to the tables, so a database holds many brains. The rows of an older database
+// belong to the brain opening it, as a database held only one brain before
+func addBrainID(tx *sql.Tx, brainID string) error {
+	for _, stmt := range []string{
+		`CREATE TABLE memory_v2 (
+			brain_id TEXT NOT NULL,
+			key INTEGER NOT NULL,
+			key_type TEXT,
+			key_text TEXT,
+			value JSON,
+			type TEXT,
+			type_name TEXT,
+			encoding TEXT,
+			data BLOB,
+			PRIMARY KEY (brain_id, key)
+		)`,
+		`INSERT INTO memory_v2 (brain_id, key, key_type, key_text, value, type, type_name, encoding, data)
+			SELECT ?, key, key_type, key_text, value, type, type_name, encoding, data FROM memory`,
+		`DROP TABLE memory`,
+		`ALTER TABLE memory_v2 RENAME TO memory`,
+
+		`CREATE TABLE checkpoint_link_v2 (
+			brain_id TEXT NOT NULL,
+			id TEXT NOT NULL,
+			state TEXT,
+			PRIMARY KEY (brain_id, id)
+		)`,
+		`INSERT INTO checkpoint_link_v2 (brain_id, id, state) SELECT ?, id, state FROM checkpoint_link`,
+		`DROP TABLE checkpoint_link`,
+		`ALTER TABLE checkpoint_link_v2 RENAME TO checkpoint_link`,
+
+		`CREATE TABLE checkpoint_neuron_v2 (
+			brain_id TEXT NOT NULL,
+			id TEXT NOT NULL,
+			state TEXT,
+			PRIMARY KEY (brain_id, id)
+		)`,
+		`INSERT INTO checkpoint_neuron_v2 (brain_id, id, state) SELECT ?, id, state FROM checkpoint_neuron`,
+		`DROP TABLE checkpoint_neuron`,
+		`ALTER TABLE checkpoint_neuron_v2 RENAME TO checkpoint_neuron`,
+
+		`ALTER TABLE checkpoint_pending ADD COLUMN brain_id TEXT`,
+		`UPDATE checkpoint_pending SET brain_id = ?`,
+		`CREATE INDEX checkpoint_pending_brain_id ON checkpoint_pending (brain_id)`,
+
+		`UPDATE snapshot SET brain_id = ? WHERE brain_id IS NULL`,
+		`CREATE INDEX snapshot_brain_id ON snapshot (brain_id)`,
+	} {
+		args := []any{}
+		if strings.Contains(stmt, "?") {
+			args = append(args, brainID)
+		}
+		if _, err := tx.Exec(stmt, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addExpiresAt adds the expiration time of memories in unix nanoseconds, it is NULL for the memories never expire
+func addExpiresAt(tx *sql.Tx, _ string) error {
+	for _, stmt := range []string{
+		`ALTER TABLE memory ADD COLUMN expires_at INTEGER`,
+		`ALTER TABLE snapshot_memory ADD COLUMN expires_at INTEGER`,
+		`CREATE INDEX memory_expires_at ON memory (brain_id, expires_at)`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hashKeysWithType rehashes the memories with their original keys by the type and the text of key,
+// the memories written without the original keys keep the hashes of their texts
+func hashKeysWithType(tx *sql.Tx, _ string) error {
+	for _, table := range []string{"memory", "snapshot_memory"} {
+		rows, err := tx.Query(fmt.Sprintf("SELECT rowid, key_type, key_text FROM %s WHERE key_type IS NOT NULL", table))
+		if err != nil {
+			return err
+		}
+		hashes := make(map[int64]int64)
+		for rows.Next() {
+			var rowID int64
+			var keyType, text string
+			if err = rows.Scan(&rowID, &keyType, &text); err != nil {
+				rows.Close()
+				return err
+			}
+			hashes[rowID] = hashKeyText(keyType + ":" + text)
+		}
+		if err = rows.Err(); err != nil {
+			rows.Close()
+			return err
+		}
+		rows.Close()
+
+		for rowID, hash := range hashes {
+			if _, err = tx.Exec(fmt.Sprintf("UPDATE %s SET key = ? WHERE rowid = ?", table), hash, rowID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// addMemoryColumns adds the columns of original keys and codecs to table if they are missing
+func addMemoryColumns(tx *sql.Tx, table string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var dflt sql.NullString
+		if err = rows.Scan(&cid, &name, &columnType, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		columns[name] = true
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, c := range []struct{ name, typ string }{
+		{"key_type", "TEXT"}, {"key_text", "TEXT"},
+		{"type_name", "TEXT"}, {"encoding", "TEXT"}, {"data", "BLOB"},
+	} {
+		if columns[c.name] {
+			continue
+		}
+		if _, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, c.name, c.typ)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
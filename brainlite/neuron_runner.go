@@ -5,26 +5,63 @@ import (
 
 	"github.com/Rovanta/rmodel/core"
 	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/internal/queue"
 )
 
+// publishEventActivateNeuron adds a pending activation of neuron, topoMu must be held. It is deferred to
+// flushActivations, as pushing to a full neuron queue under topoMu would block the workers waiting for topoMu
 func (b *BrainLite) publishEventActivateNeuron(neuronID string) {
 	if b.getState() == core.BrainStateShutdown || b.nQueue == nil {
 		return
 	}
 	b.logger.Debug().Interface("neuronID", neuronID).Msg("publish activate neuron event")
 
-	b.nQueue <- neuronID
+	b.pending.AddActivation(neuronID)
+	// brain is shutting down, the activation is kept in pending queue
+	if b.stopping.Load() {
+		return
+	}
+	priority := 0
+	if neu, ok := b.neurons[neuronID]; ok {
+		priority = neu.priority()
+	}
+	b.deferred.Add(neuronID, priority)
 }
 
-func (b *BrainLite) runNeuronWorker() {
-	for neuronID := range b.nQueue {
+// flushActivations pushes the deferred activations by their priorities, after topoMu is released
+func (b *BrainLite) flushActivations() {
+	b.deferred.Flush(func(neuronID string, priority int) bool {
+		// the activations left are kept in pending queue while brain is shutting down
+		if b.stopping.Load() || b.nQueue == nil {
+			return false
+		}
+		b.nQueue.Push(neuronID, priority, b.stop)
+		return true
+	})
+}
+
+func (b *BrainLite) runNeuronWorker(nQueue *queue.Priority[string], stop chan struct{}) {
+	for {
+		neuronID, ok := nQueue.Pop(stop)
+		if !ok {
+			return
+		}
+		// brain is shutting down, the activation is kept in pending queue
+		if b.stopping.Load() {
+			continue
+		}
+		b.pending.DoneActivation(neuronID)
+		b.topoMu.RLock()
 		neu, ok := b.neurons[neuronID]
+		b.topoMu.RUnlock()
 		if !ok {
 			b.logger.Error().Str("neuronID", neuronID).Msg("neuron not found")
 			continue
 		}
 
+		b.startProcessing()
 		err := b.activateNeuron(neu)
+		b.doneProcessing()
 		if err != nil {
 			b.logger.Error().Err(err).Str("neuronID", neuronID).Msg("activate neuron error")
 		}
@@ -37,6 +74,8 @@ func (b *BrainLite) activateNeuron(neu *neuron) error {
 	}
 
 	b.logger.Debug().Interface("neuronID", neu.id).Msg("start activate neuron")
+	// the states are read by the maintainer step holding the read lock, so they are changed with the write lock
+	b.topoMu.Lock()
 	neu.status.state = core.NeuronStateActivated
 	// in-link set init
 	for _, links := range neu.spec.triggerGroups {
@@ -51,21 +90,37 @@ func (b *BrainLite) activateNeuron(neu *neuron) error {
 			l.status.state = core.LinkStateWait
 		}
 	}
-
 	neu.status.count.process++
+	b.topoMu.Unlock()
+
 	// block process
-	err := neu.spec.processor.Process(&brainContext{
-		b:               b,
-		currentNeuronID: neu.id,
-	})
+	err := neu.spec.processor.Process(b.newBrainContext(neu.id))
+	interrupted := err == nil && neu.hasLabel(core.NeuronLabelInterruptAfter)
+	b.topoMu.Lock()
 	neu.status.state = core.NeuronStateInactive
 	if err != nil {
 		neu.status.count.failed++
+	} else {
+		// SucceedCount++
+		neu.status.count.succeed++
+	}
+	if interrupted {
+		neu.status.state = core.NeuronStateInterruptedAfter
+	}
+	b.topoMu.Unlock()
+	if err != nil {
 		return fmt.Errorf("process neuron error: %w", err)
 	}
 
-	// SucceedCount++
-	neu.status.count.succeed++
+	// park the run before neuron cast
+	if interrupted {
+		b.publishEvent(maintainEvent{
+			kind:   eventKindNeuron,
+			action: eventActionNeuronInterrupt,
+			id:     neu.id,
+		})
+		return nil
+	}
 
 	// cast
 	b.publishEvent(maintainEvent{
@@ -1,6 +1,11 @@
 package brainlite
 
 import (
+	"time"
+
+	"github.com/Rovanta/rmodel/artifact"
+	"github.com/Rovanta/rmodel/codec"
+	"github.com/Rovanta/rmodel/core"
 	"github.com/rs/zerolog"
 )
 
@@ -30,6 +35,14 @@ func WithNeuronQueueLen(nQueueLen int) Option {
 	})
 }
 
+// WithPriorityAging raises the priority of a queued neuron activation by one for every interval it waits,
+// so the neurons with low priority are not starved. Aging is disabled if interval <= 0, by default.
+func WithPriorityAging(interval time.Duration) Option {
+	return optionFunc(func(brain *BrainLite) {
+		brain.nAging = interval
+	})
+}
+
 // WithLoggerLevel sets the default logger with specific level
 func WithLoggerLevel(level zerolog.Level) Option {
 	return optionFunc(func(brain *BrainLite) {
@@ -50,3 +63,73 @@ func WithID(brainID string) Option {
 		brain.id = brainID
 	})
 }
+
+// WithSnapshots enables snapshots of memory and graph state after every maintainer step,
+// the latest limit snapshots are kept, all snapshots are kept if limit <= 0
+func WithSnapshots(limit int) Option {
+	return optionFunc(func(brain *BrainLite) {
+		brain.snapshots.enabled = true
+		brain.snapshots.limit = limit
+	})
+}
+
+// WithMemorySweepInterval sets the interval the expired memories are deleted and reported to the watchers,
+// an expired memory is not read even before it is deleted. The default interval is 10 seconds.
+func WithMemorySweepInterval(interval time.Duration) Option {
+	return optionFunc(func(brain *BrainLite) {
+		if interval > 0 {
+			brain.BrainMemory.sweepInterval = interval
+		}
+	})
+}
+
+// WithArtifactDir sets the directory of the content-addressed artifact store of PutArtifact,
+// the default is artifacts in the directory set by WithDataDir
+func WithArtifactDir(dir string) Option {
+	return optionFunc(func(brain *BrainLite) {
+		brain.BrainMemory.artifacts = artifact.NewStore(dir)
+	})
+}
+
+// WithCodecRegistry sets the registry of types whose memories are read back as the original Go types,
+// codec.Default is used by default
+func WithCodecRegistry(registry *codec.Registry) Option {
+	return optionFunc(func(brain *BrainLite) {
+		brain.BrainMemory.codecs = registry
+	})
+}
+
+// WithMemoryStore keeps the memories of brain in the store created by newStore in place of the memory table,
+// e.g. a memstore.Redis shared by processes. The database still keeps the checkpoints and the snapshots of brain.
+// The store is created when the memory is initialized, and it should keep the memories of this brain only,
+// as it is cleared by Shutdown unless WithKeepMemory is set, then closed.
+func WithMemoryStore(newStore func() (core.MemoryStore, error)) Option {
+	return optionFunc(func(brain *BrainLite) {
+		brain.BrainMemory.newStore = newStore
+	})
+}
+
+// WithDatasource sets the SQLite database file or URI, e.g. brains.db or file:brains.db?cache=shared.
+// The database may hold many brains, the rows of a brain are kept by their brain IDs,
+// so the database is never deleted, and the rows of brain are deleted by Shutdown unless WithKeepMemory is set
+func WithDatasource(dsn string) Option {
+	return optionFunc(func(brain *BrainLite) {
+		brain.BrainMemory.datasourceName = dsn
+		brain.BrainMemory.shared = dsn != ""
+	})
+}
+
+// WithDataDir sets the directory of the database file ${brain_id}.db, it is the working directory by default.
+// It is not used if WithDatasource is set
+func WithDataDir(dir string) Option {
+	return optionFunc(func(brain *BrainLite) {
+		brain.BrainMemory.dataDir = dir
+	})
+}
+
+// WithKeepMemory keeps the memory and the checkpoint of brain after Shutdown, so the brain can be resumed by Resume
+func WithKeepMemory() Option {
+	return optionFunc(func(brain *BrainLite) {
+		brain.keepMemory = true
+	})
+}
@@ -0,0 +1,188 @@
+package brainlite
+
+import (
+	"fmt"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/internal/patch"
+	"github.com/Rovanta/rmodel/internal/utils"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// Apply validates patch and changes the topology of brain by it between two maintainer steps, see core.Brain.
+// The patch is not persisted, a brain is resumed with the blueprint which includes the patch.
+func (b *BrainLite) Apply(patch core.Patch) error {
+	if patch == nil {
+		return fmt.Errorf("patch is nil")
+	}
+
+	b.topoMu.Lock()
+	err := b.validatePatch(patch)
+	if err == nil {
+		b.applyPatch(patch)
+	}
+	b.topoMu.Unlock()
+	if err != nil {
+		return errors.Wrapf(err, "invalid patch")
+	}
+
+	patchID := utils.GenIDShort()
+	b.logger.Info().
+		Str("patchID", patchID).
+		Int("addNeurons", len(patch.ListNeurons())).
+		Int("addLinks", len(patch.ListLinks())).
+		Int("removeNeurons", len(patch.ListRemovedNeurons())).
+		Int("removeLinks", len(patch.ListRemovedLinks())).
+		Int("groupChanges", len(patch.ListGroupChanges())).
+		Msg("topology patch applied")
+	b.publishEvent(maintainEvent{
+		kind:   eventKindBrain,
+		action: eventActionBrainTopologyChanged,
+		id:     patchID,
+	})
+
+	return nil
+}
+
+// validatePatch validates p against the neurons and links of brain and the queued activations, topoMu must be held
+func (b *BrainLite) validatePatch(p core.Patch) error {
+	_, activations := b.pending.Copy()
+
+	return patch.Validate(b.topology(), p, activations)
+}
+
+// applyPatch changes the neurons and links of brain by a validated patch, topoMu must be held
+func (b *BrainLite) applyPatch(patch core.Patch) {
+	for _, id := range patch.ListRemovedNeurons() {
+		delete(b.neurons, id)
+	}
+	for id, l := range b.links {
+		_, srcOK := b.neurons[l.spec.from]
+		_, destOK := b.neurons[l.spec.to]
+		if !srcOK && !l.isEntryLink() || !destOK {
+			b.removeLink(id)
+		}
+	}
+	for _, id := range patch.ListRemovedLinks() {
+		b.removeLink(id)
+	}
+
+	for _, l := range patch.ListLinks() {
+		lk := newLink(l)
+		b.links[lk.id] = lk
+	}
+	for _, n := range patch.ListNeurons() {
+		neu := newNeuron(n, b.links)
+		b.neurons[neu.id] = neu
+	}
+	if _, ok := b.neurons[core.EndNeuronID]; !ok {
+		for _, l := range patch.ListLinks() {
+			if l.GetDestNeuronID() == core.EndNeuronID {
+				b.neurons[core.EndNeuronID] = newEndNeuron()
+				break
+			}
+		}
+	}
+	// links between neurons of brain and new neurons, the new neurons have their groups already
+	newNeurons := make(map[string]bool)
+	for _, n := range patch.ListNeurons() {
+		newNeurons[n.GetID()] = true
+	}
+	for _, l := range patch.ListLinks() {
+		lk := b.links[l.GetID()]
+		if src, ok := b.neurons[lk.spec.from]; ok && !newNeurons[src.id] {
+			src.spec.castGroups[processor.DefaultCastGroupName] = append(src.spec.castGroups[processor.DefaultCastGroupName], lk)
+			// the processing neuron casts to its new out-links too
+			if src.status.state == core.NeuronStateActivated {
+				lk.status.state = core.LinkStateWait
+			}
+		}
+		if dest, ok := b.neurons[lk.spec.to]; ok && !newNeurons[dest.id] {
+			dest.spec.triggerGroups[utils.GenIDShort()] = []*link{lk}
+		}
+	}
+
+	for _, c := range patch.ListGroupChanges() {
+		n := b.neurons[c.NeuronID]
+		group := make([]*link, 0, len(c.LinkIDs))
+		for _, id := range c.LinkIDs {
+			group = append(group, b.links[id])
+		}
+		switch c.Kind {
+		case core.GroupChangeAddTrigger:
+			n.triggers().AddTrigger(group)
+		case core.GroupChangeRemoveTrigger:
+			n.triggers().RemoveTrigger(group)
+		case core.GroupChangeAddCast:
+			n.casts().AddCast(c.GroupName, group)
+		case core.GroupChangeRemoveCast:
+			n.casts().RemoveCast(c.GroupName)
+		}
+	}
+}
+
+// removeLink drops link from brain, and from the trigger group and the cast groups holding it
+func (b *BrainLite) removeLink(linkID string) {
+	l, ok := b.links[linkID]
+	if !ok {
+		return
+	}
+	delete(b.links, linkID)
+
+	if src, ok := b.neurons[l.spec.from]; ok {
+		src.casts().RemoveCastLink(linkID)
+	}
+	if dest, ok := b.neurons[l.spec.to]; ok {
+		dest.triggers().RemoveTriggerLink(linkID)
+	}
+}
+
+// tryActivateAll publishes a try-activate event for every neuron with ready in-links, after the topology changed
+func (b *BrainLite) tryActivateAll() {
+	for _, n := range b.neurons {
+		for _, links := range n.spec.triggerGroups {
+			if hasReadyLink(links) {
+				b.publishEvent(maintainEvent{
+					kind:   eventKindNeuron,
+					action: eventActionNeuronTryActivate,
+					id:     n.id,
+				})
+				break
+			}
+		}
+	}
+}
+
+// triggers returns the trigger groups of neuron, a patch changes them in place
+func (n *neuron) triggers() patch.Groups[*link] {
+	return patch.Groups[*link]{Groups: n.spec.triggerGroups, ID: linkID}
+}
+
+// casts returns the cast groups of neuron, a patch changes them in place
+func (n *neuron) casts() patch.Groups[*link] {
+	return patch.Groups[*link]{Groups: n.spec.castGroups, ID: linkID}
+}
+
+func linkID(l *link) string {
+	return l.id
+}
+
+func linkIDs(links []*link) []string {
+	ids := make([]string, 0, len(links))
+	for _, l := range links {
+		ids = append(ids, l.id)
+	}
+
+	return ids
+}
+
+func hasReadyLink(links []*link) bool {
+	for _, l := range links {
+		if l.status.state == core.LinkStateReady {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,327 @@
+package brainlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/errors"
+)
+
+type snapshotSetting struct {
+	enabled bool
+	// keep the latest limit snapshots, keep all if limit <= 0
+	limit int
+}
+
+// pendingEntry is the stored form of a pending queue entry
+type pendingEntry struct {
+	Queue  string `json:"queue"`
+	Kind   string `json:"kind,omitempty"`
+	Action string `json:"action,omitempty"`
+	ID     string `json:"id"`
+	Count  int    `json:"count"`
+}
+
+// memoryRow is a row of memory table
+type memoryRow struct {
+	key       int64
+	keyType   sql.NullString
+	keyText   sql.NullString
+	value     []byte
+	valueType string
+	typeName  sql.NullString
+	encoding  sql.NullString
+	data      []byte
+	expiresAt sql.NullInt64
+}
+
+// ListSnapshots lists snapshots of brain in the order they are taken, snapshots are enabled by WithSnapshots
+func (b *BrainLite) ListSnapshots() ([]core.Snapshot, error) {
+	if err := b.ensureMemoryInit(); err != nil {
+		return nil, err
+	}
+
+	rows, err := b.BrainMemory.db.Query(
+		"SELECT id, brain_id, step, created_at, links, neurons FROM snapshot WHERE brain_id = ? ORDER BY id", b.id)
+	if err != nil {
+		return nil, fmt.Errorf("An error occurred while querying snapshots: %v", err)
+	}
+	defer rows.Close()
+
+	ret := make([]core.Snapshot, 0)
+	for rows.Next() {
+		var snap core.Snapshot
+		var createdAt int64
+		var linksJSON, neuronsJSON []byte
+		if err = rows.Scan(&snap.ID, &snap.BrainID, &snap.Step, &createdAt, &linksJSON, &neuronsJSON); err != nil {
+			return nil, fmt.Errorf("An error occurred while parsing snapshot: %v", err)
+		}
+		snap.CreatedAt = time.Unix(0, createdAt)
+		if err = json.Unmarshal(linksJSON, &snap.Links); err != nil {
+			return nil, fmt.Errorf("An error occurred while parsing snapshot: %v", err)
+		}
+		if err = json.Unmarshal(neuronsJSON, &snap.Neurons); err != nil {
+			return nil, fmt.Errorf("An error occurred while parsing snapshot: %v", err)
+		}
+		ret = append(ret, snap)
+	}
+
+	return ret, rows.Err()
+}
+
+// Fork builds a new brain with a new ID, in the shared database of brain set by WithDatasource or a new database,
+// and restores the memory and graph state
+// of the snapshot into it. The forked brain inherits settings of the brain, withOpts override them.
+// Memory of forked brain can be edited before the run is continued by Continue.
+// The store set by WithMemoryStore is not inherited, as it keeps the memories of brain, so a forked brain keeps
+// its memories in the database unless a store of its own is set by withOpts.
+func (b *BrainLite) Fork(snapshotID int, withOpts ...Option) (*BrainLite, error) {
+	if err := b.ensureMemoryInit(); err != nil {
+		return nil, err
+	}
+	cp, memory, err := b.BrainMemory.loadSnapshot(snapshotID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load snapshot %d failed", snapshotID)
+	}
+
+	nb := newBrain(b.labels)
+	b.topoMu.RLock()
+	for id, l := range b.links {
+		nb.links[id] = l.clone()
+	}
+	for id, n := range b.neurons {
+		nb.neurons[id] = n.clone(nb.links)
+	}
+	b.topoMu.RUnlock()
+	nb.init(append([]Option{b.inheritedOption()}, withOpts...)...)
+
+	if err = nb.ensureMemoryInit(); err != nil {
+		return nil, err
+	}
+	if err = nb.restoreCheckpoint(cp); err != nil {
+		return nil, err
+	}
+	if err = nb.BrainMemory.restoreRows(memory); err != nil {
+		return nil, errors.Wrapf(err, "restore memory failed")
+	}
+	nb.restored = cp
+	nb.logger.Info().
+		Str("forkedFrom", b.id).
+		Int("snapshotID", snapshotID).
+		Msg("brain forked from snapshot")
+
+	return nb, nil
+}
+
+// Continue continues the run restored by Fork, the activated neurons and pending queue entries
+// of the snapshot are queued again.
+func (b *BrainLite) Continue() error {
+	cp := b.restored
+	if cp == nil {
+		return fmt.Errorf("brain %s is not restored from a snapshot", b.id)
+	}
+	b.restored = nil
+
+	if err := b.ensureMemoryInit(); err != nil {
+		return err
+	}
+	b.continueRun(cp)
+
+	return nil
+}
+
+// takeSnapshot stores a snapshot after maintainer step, if snapshots are enabled
+func (b *BrainLite) takeSnapshot(step maintainEvent) {
+	if !b.snapshots.enabled || b.BrainMemory.db == nil {
+		return
+	}
+
+	links := make(map[string]core.LinkState, len(b.links))
+	for id, l := range b.links {
+		links[id] = l.status.state
+	}
+	neurons := make(map[string]core.NeuronState, len(b.neurons))
+	for id, n := range b.neurons {
+		neurons[id] = n.status.state
+	}
+	events, activations := b.pending.Copy()
+	pending := make([]pendingEntry, 0, len(events)+len(activations))
+	for e, cnt := range events {
+		pending = append(pending, pendingEntry{
+			Queue:  pendingQueueBrain,
+			Kind:   string(e.kind),
+			Action: string(e.action),
+			ID:     e.id,
+			Count:  cnt,
+		})
+	}
+	for id, cnt := range activations {
+		pending = append(pending, pendingEntry{Queue: pendingQueueNeuron, ID: id, Count: cnt})
+	}
+
+	err := b.BrainMemory.saveSnapshot(b.id, fmt.Sprintf("%s %s %s", step.kind, step.action, step.id),
+		links, neurons, pending, b.snapshots.limit)
+	if err != nil {
+		b.logger.Error().Err(err).Msg("take snapshot failed")
+	}
+}
+
+// inheritedOption copies settings of brain to a forked brain
+func (b *BrainLite) inheritedOption() Option {
+	return optionFunc(func(brain *BrainLite) {
+		brain.nWorkerNum = b.nWorkerNum
+		brain.nQueueLen = b.nQueueLen
+		brain.nAging = b.nAging
+		brain.BrainMemory.reducers = b.BrainMemory.reducers
+		brain.BrainMemory.codecs = b.BrainMemory.codecs
+		brain.BrainMemory.artifacts = b.BrainMemory.artifacts
+		brain.BrainMemory.sweepInterval = b.BrainMemory.sweepInterval
+		brain.keepMemory = b.keepMemory
+		brain.BrainMemory.dataDir = b.BrainMemory.dataDir
+		// a fork is kept in the shared database of brain, and in its own database file otherwise
+		if b.BrainMemory.shared {
+			brain.BrainMemory.datasourceName = b.BrainMemory.datasourceName
+			brain.BrainMemory.shared = true
+		}
+		brain.snapshots = b.snapshots
+		brain.logger = brain.logger.Level(b.logger.GetLevel())
+	})
+}
+
+func (m *BrainMemory) saveSnapshot(brainID, step string, links map[string]core.LinkState,
+	neurons map[string]core.NeuronState, pending []pendingEntry, limit int) error {
+	linksJSON, err := json.Marshal(links)
+	if err != nil {
+		return fmt.Errorf("Unable to serialize links: %v", err)
+	}
+	neuronsJSON, err := json.Marshal(neurons)
+	if err != nil {
+		return fmt.Errorf("Unable to serialize neurons: %v", err)
+	}
+	pendingJSON, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("Unable to serialize pending queue: %v", err)
+	}
+
+	// the memories in store are copied to the snapshot as the rows of memory table
+	var memory []memoryRow
+	if m.store != nil {
+		if memory, err = m.storeRows(); err != nil {
+			return err
+		}
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("An error occurred while beginning transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO snapshot (brain_id, step, created_at, links, neurons, pending) VALUES (?, ?, ?, ?, ?, ?)",
+		brainID, step, time.Now().UnixNano(), linksJSON, neuronsJSON, pendingJSON)
+	if err != nil {
+		return fmt.Errorf("Error while storing snapshot: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("Error while storing snapshot: %v", err)
+	}
+	if m.store != nil {
+		for _, row := range memory {
+			if _, err = tx.Exec(`INSERT INTO snapshot_memory (snapshot_id, key, key_type, key_text, value, type, type_name, encoding, data, expires_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, id, row.key, row.keyType, row.keyText, row.value, row.valueType, row.typeName, row.encoding, row.data, row.expiresAt); err != nil {
+				return fmt.Errorf("Error while storing snapshot memory: %v", err)
+			}
+		}
+	} else if _, err = tx.Exec(`INSERT INTO snapshot_memory (snapshot_id, key, key_type, key_text, value, type, type_name, encoding, data, expires_at)
+		SELECT ?, key, key_type, key_text, value, type, type_name, encoding, data, expires_at FROM memory WHERE brain_id = ?`, id, brainID); err != nil {
+		return fmt.Errorf("Error while storing snapshot memory: %v", err)
+	}
+
+	if limit > 0 {
+		// snapshots of the other brains in database are kept
+		latest := "SELECT id FROM snapshot WHERE brain_id = ? ORDER BY id DESC LIMIT ?"
+		if _, err = tx.Exec(`DELETE FROM snapshot_memory WHERE snapshot_id IN
+			(SELECT id FROM snapshot WHERE brain_id = ? AND id NOT IN (`+latest+`))`, brainID, brainID, limit); err != nil {
+			return fmt.Errorf("An error occurred while deleting snapshots: %v", err)
+		}
+		if _, err = tx.Exec("DELETE FROM snapshot WHERE brain_id = ? AND id NOT IN ("+latest+")", brainID, brainID, limit); err != nil {
+			return fmt.Errorf("An error occurred while deleting snapshots: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *BrainMemory) loadSnapshot(id int) (*checkpoint, []memoryRow, error) {
+	var linksJSON, neuronsJSON, pendingJSON []byte
+	err := m.db.QueryRow("SELECT links, neurons, pending FROM snapshot WHERE id = ? AND brain_id = ?", id, m.brainID).
+		Scan(&linksJSON, &neuronsJSON, &pendingJSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf("An error occurred while querying snapshot: %v", err)
+	}
+
+	cp := &checkpoint{
+		events:      make(map[maintainEvent]int),
+		activations: make(map[string]int),
+	}
+	var pending []pendingEntry
+	if err = json.Unmarshal(linksJSON, &cp.links); err != nil {
+		return nil, nil, fmt.Errorf("An error occurred while parsing snapshot: %v", err)
+	}
+	if err = json.Unmarshal(neuronsJSON, &cp.neurons); err != nil {
+		return nil, nil, fmt.Errorf("An error occurred while parsing snapshot: %v", err)
+	}
+	if err = json.Unmarshal(pendingJSON, &pending); err != nil {
+		return nil, nil, fmt.Errorf("An error occurred while parsing snapshot: %v", err)
+	}
+	for _, p := range pending {
+		switch p.Queue {
+		case pendingQueueBrain:
+			cp.events[maintainEvent{kind: eventKind(p.Kind), action: eventAction(p.Action), id: p.ID}] += p.Count
+		case pendingQueueNeuron:
+			cp.activations[p.ID] += p.Count
+		}
+	}
+
+	rows, err := m.db.Query(`SELECT key, key_type, key_text, value, type, type_name, encoding, data, expires_at
+		FROM snapshot_memory WHERE snapshot_id = ?`, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("An error occurred while querying snapshot memory: %v", err)
+	}
+	defer rows.Close()
+	memory := make([]memoryRow, 0)
+	for rows.Next() {
+		var row memoryRow
+		if err = rows.Scan(&row.key, &row.keyType, &row.keyText, &row.value, &row.valueType, &row.typeName, &row.encoding, &row.data, &row.expiresAt); err != nil {
+			return nil, nil, fmt.Errorf("An error occurred while parsing snapshot memory: %v", err)
+		}
+		memory = append(memory, row)
+	}
+
+	return cp, memory, rows.Err()
+}
+
+func (m *BrainMemory) restoreRows(rows []memoryRow) error {
+	if m.store != nil {
+		return m.restoreStore(rows)
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("An error occurred while beginning transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		if _, err = tx.Exec(`INSERT OR REPLACE INTO memory (brain_id, key, key_type, key_text, value, type, type_name, encoding, data, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, m.brainID, row.key, row.keyType, row.keyText, row.value, row.valueType, row.typeName, row.encoding, row.data, row.expiresAt); err != nil {
+			return fmt.Errorf("Error while storing data: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
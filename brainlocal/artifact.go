@@ -0,0 +1,27 @@
+package brainlocal
+
+import (
+	"io"
+
+	"github.com/Rovanta/rmodel/internal/errors"
+)
+
+// PutArtifact keeps the content of r in the artifact store of brain, and sets memory name to its artifact.Ref,
+// so a large binary value, e.g. an image or a document, is not kept in memory
+func (b *BrainLocal) PutArtifact(name string, r io.Reader) error {
+	return b.putArtifact("", name, r)
+}
+
+func (b *BrainLocal) putArtifact(neuronID, name string, r io.Reader) error {
+	ref, err := b.BrainMemory.artifacts.Put(name, r)
+	if err != nil {
+		return errors.Wrapf(err, "put artifact %s failed", name)
+	}
+
+	return b.setMemory(neuronID, name, ref)
+}
+
+// OpenArtifact opens the content of the artifact referenced by memory name, the caller closes it
+func (b *BrainLocal) OpenArtifact(name string) (io.ReadCloser, error) {
+	return b.BrainMemory.artifacts.OpenMemory(name, b.GetMemory(name))
+}
@@ -1,12 +1,27 @@
 package brainlocal
 
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/Rovanta/rmodel/processor"
+	"github.com/rs/zerolog"
+)
+
 type brainContext struct {
+	// context of the run
+	context.Context
 	b               *BrainLocal
 	currentNeuronID string
 }
 
 func (c *brainContext) SetMemory(keysAndValues ...interface{}) error {
-	return c.b.SetMemory(keysAndValues...)
+	return c.b.setMemory(c.currentNeuronID, keysAndValues...)
+}
+
+func (c *brainContext) SetMemoryWithTTL(key, value interface{}, ttl time.Duration) error {
+	return c.b.setMemoryWithTTL(c.currentNeuronID, key, value, ttl)
 }
 
 func (c *brainContext) GetMemory(key interface{}) interface{} {
@@ -18,11 +33,53 @@ func (c *brainContext) ExistMemory(key interface{}) bool {
 }
 
 func (c *brainContext) DeleteMemory(key interface{}) {
-	c.b.DeleteMemory(key)
+	c.b.deleteMemory(c.currentNeuronID, key)
 }
 
 func (c *brainContext) ClearMemory() {
-	c.b.ClearMemory()
+	c.b.clearMemory(c.currentNeuronID)
+}
+
+func (c *brainContext) WatchMemory(ctx context.Context, keys ...interface{}) <-chan processor.MemoryChange {
+	return c.b.WatchMemory(ctx, keys...)
+}
+
+func (c *brainContext) UpdateMemory(fn func(tx processor.MemoryTx) error) error {
+	return c.b.updateMemory(c.currentNeuronID, fn)
+}
+
+func (c *brainContext) CompareAndSwapMemory(key, old, new interface{}) (bool, error) {
+	return c.b.compareAndSwapMemory(c.currentNeuronID, key, old, new)
+}
+
+func (c *brainContext) ListMemoryKeys() ([]interface{}, error) {
+	return c.b.ListMemoryKeys()
+}
+
+func (c *brainContext) RangeMemory(fn func(key, value interface{}) bool) error {
+	return c.b.RangeMemory(fn)
+}
+
+func (c *brainContext) DumpMemory() (map[interface{}]interface{}, error) {
+	return c.b.DumpMemory()
+}
+
+func (c *brainContext) PutArtifact(name string, r io.Reader) error {
+	return c.b.putArtifact(c.currentNeuronID, name, r)
+}
+
+func (c *brainContext) OpenArtifact(name string) (io.ReadCloser, error) {
+	return c.b.OpenArtifact(name)
+}
+
+// GetArtifactDir returns the directory of the artifact store of brain, Python processors open it
+func (c *brainContext) GetArtifactDir() string {
+	return c.b.BrainMemory.artifacts.Dir()
+}
+
+// GetLogger returns the logger of brain for the current neuron, Python processors log their output by it
+func (c *brainContext) GetLogger() zerolog.Logger {
+	return c.b.logger.With().Str("neuronID", c.currentNeuronID).Logger()
 }
 
 func (c *brainContext) GetCurrentNeuronID() string {
@@ -53,3 +110,11 @@ func (c *brainContext) ContinueCast() {
 		id:     c.currentNeuronID,
 	})
 }
+
+func (b *BrainLocal) newBrainContext(neuronID string) *brainContext {
+	return &brainContext{
+		Context:         b.runContext(),
+		b:               b,
+		currentNeuronID: neuronID,
+	}
+}
@@ -1,16 +1,27 @@
 package brainlocal
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/dgraph-io/ristretto"
 	"github.com/rs/zerolog"
+	"github.com/Rovanta/rmodel/artifact"
 	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/internal/expiry"
+	"github.com/Rovanta/rmodel/internal/interrupt"
+	"github.com/Rovanta/rmodel/internal/memkey"
+	"github.com/Rovanta/rmodel/internal/queue"
 	"github.com/Rovanta/rmodel/internal/utils"
+	"github.com/Rovanta/rmodel/internal/watch"
+	"github.com/Rovanta/rmodel/memstore"
+	"github.com/Rovanta/rmodel/processor"
 )
 
 const (
@@ -20,21 +31,10 @@ const (
 	defaultNQueueLen = 10
 	// default number of neuron process workers
 	defaultNWorkerNum = 4
-	// default number of keys to track frequency of (10M)
-	defaultMemNumCounters = 1e7
-	// default maximum cost of cache (1GB)
-	defaultMemMaxCost = 1 << 30
 )
 
 func BuildBrain(blueprint core.Blueprint, withOpts ...Option) *BrainLocal {
-	b := &BrainLocal{
-		id:      utils.GenID(),
-		labels:  utils.LabelsDeepCopy(blueprint.GetLabels()),
-		state:   core.BrainStateShutdown,
-		neurons: make(map[string]*neuron),
-		links:   make(map[string]*link),
-	}
-	b.cond = sync.NewCond(&b.mu)
+	b := newBrain(blueprint.GetLabels())
 
 	for _, l := range blueprint.ListLinks() {
 		lk := newLink(l)
@@ -45,7 +45,32 @@ func BuildBrain(blueprint core.Blueprint, withOpts ...Option) *BrainLocal {
 		b.neurons[neu.id] = neu
 	}
 
-	// init config
+	b.BrainMemory.reducers = blueprint.ListMemoryReducers()
+	b.init(withOpts...)
+
+	if err := blueprint.Err(); err != nil {
+		b.logger.Error().Err(err).Interface("blueprint", blueprint).Msg("brain build with invalid blueprint")
+	} else {
+		b.logger.Info().Interface("blueprint", blueprint).Msg("brain build success")
+	}
+	return b
+}
+
+func newBrain(labels map[string]string) *BrainLocal {
+	b := &BrainLocal{
+		id:      utils.GenID(),
+		labels:  utils.LabelsDeepCopy(labels),
+		state:   core.BrainStateShutdown,
+		neurons: make(map[string]*neuron),
+		links:   make(map[string]*link),
+	}
+	b.cond = sync.NewCond(&b.mu)
+
+	return b
+}
+
+// init sets default config of brain, then applies options
+func (b *BrainLocal) init(withOpts ...Option) {
 	b.logger = zerolog.New(zerolog.ConsoleWriter{
 		Out:        os.Stdout,
 		TimeFormat: time.RFC3339,
@@ -68,17 +93,25 @@ func BuildBrain(blueprint core.Blueprint, withOpts ...Option) *BrainLocal {
 	}).With().Caller().Timestamp().Logger().Level(zerolog.InfoLevel)
 	b.BrainMaintainer.nQueueLen = defaultNQueueLen
 	b.BrainMaintainer.nWorkerNum = defaultNWorkerNum
-	b.BrainMemory.numCounters = defaultMemNumCounters
-	b.BrainMemory.maxCost = defaultMemMaxCost
+	b.BrainMemory.newStore = func() (core.MemoryStore, error) {
+		return memstore.NewMap(), nil
+	}
+	b.BrainMemory.sweepInterval = defaultSweepInterval
 
 	for _, opt := range withOpts {
 		opt.apply(b)
 	}
 
+	if b.BrainMemory.artifacts == nil {
+		b.BrainMemory.artifacts = artifact.NewStore(filepath.Join(os.TempDir(), "rmodel-artifacts"))
+	}
 	b.logger = b.logger.With().Str("brainID", b.id).Logger()
+}
 
-	b.logger.Info().Interface("blueprint", blueprint).Msg("brain build success")
-	return b
+// BuildMultiLangBrain builds a brain with the processors of other languages, e.g. Python processors,
+// which read and write its memory through the worker running them
+func BuildMultiLangBrain(blueprint core.MultiLangBlueprint, withOpts ...Option) *BrainLocal {
+	return BuildBrain(blueprint, withOpts...)
 }
 
 type BrainLocal struct {
@@ -87,6 +120,9 @@ type BrainLocal struct {
 
 	neurons map[string]*neuron
 	links   map[string]*link
+	// topoMu guards the topology and the states, a maintainer step holds read lock, Apply holds write lock,
+	// and the states changed out of the maintainer, by the triggers and the neuron workers, are written with write lock
+	topoMu sync.RWMutex
 
 	// brain is in the Running state when there are 1 or more Activate neuron or 1 or more StandBy link.
 	state core.BrainState
@@ -94,27 +130,82 @@ type BrainLocal struct {
 	BrainMemory
 	BrainMaintainer
 
+	// threads of brain, they are started by Run
+	threads   map[string]*BrainLocal
+	threadsMu sync.Mutex
+	// thread is the thread ID and root is the brain, if b is a thread
+	thread string
+	root   *BrainLocal
+
 	logger zerolog.Logger
 	mu     sync.Mutex
 	cond   *sync.Cond
 }
 
 type BrainMemory struct {
-	cache       *ristretto.Cache
-	numCounters int64
-	maxCost     int64
+	store core.MemoryStore
+	// newStore creates store when memory is initialized, the default store is memstore.Map
+	newStore func() (core.MemoryStore, error)
+	// keys of memories of brain or thread, maps the key in store, made by memkey.Of, to the key of memory
+	keys   map[any]any
+	keysMu sync.Mutex
+	// expires are the expiration times of the memories set with TTL, by the keys in store
+	expires expiry.Times
+	// expired counts the memories deleted as their TTL passed
+	expired atomic.Uint64
+	// sweeper deletes the expired memories every sweepInterval, it is started by the first memory set with TTL
+	sweeper       expiry.Sweeper
+	sweepInterval time.Duration
+	// watchers of memory changes
+	watchers watch.Hub
+	// reducers of memory keys declared on blueprint
+	reducers map[any]core.MemoryReducer
+	// artifacts keeps the contents of artifacts, memory keeps their references
+	artifacts *artifact.Store
+	// mu guards the memories, writes are committed with it held, so readers never see a part of a transaction
+	mu sync.RWMutex
 }
 type BrainMaintainer struct {
-	bQueue chan maintainEvent
-	stop   chan struct{}
+	bQueue *queue.Events[maintainEvent]
+	// stop is closed when brain is shutting down, the maintainer flushes the queued events and exits
+	stop chan struct{}
+	// done is closed when the maintainer exits
+	done chan struct{}
+	// stopping is set when brain is shutting down, triggers and neuron activations are not accepted
+	stopping atomic.Bool
+	// lifecycleMu serializes start and shutdown of maintainer
+	lifecycleMu sync.Mutex
+	// number of neurons in process, guarded by mu
+	processing int
+	// memVersion is increased when memory is changed, guarded by mu
+	memVersion uint64
+	// context of the run, it is cancelled when brain is shut down
+	runCtx    context.Context
+	runCancel context.CancelFunc
+	// queued but not handled entries, they are kept in snapshots
+	pending queue.Pending[maintainEvent]
+	// activations published while topoMu is held, they are pushed to the neuron queue by flushActivations
+	deferred queue.Deferred[string]
+	// snapshots taken after maintainer steps
+	snapshots snapshots
+	// explanation of the last run, it is taken before brain goes to sleep, guarded by mu
+	explanation *core.Explanation
+	// reachedEnd is set when the run reaches END neuron
+	reachedEnd atomic.Bool
+	// resolving lets one Resume or Reject at a time take the interrupt points
+	resolving interrupt.Gate
+	// state restored by Fork, it is continued by Continue
+	restored *snapshot
 
 	NeuronRunner
 }
 
 type NeuronRunner struct {
-	nQueue     chan string
+	nQueue     *queue.Priority[activation]
 	nQueueLen  int
 	nWorkerNum int
+	// nAging is the aging interval of neuron priority
+	nAging time.Duration
 }
 
 func (b *BrainLocal) TrigLinks(links ...core.Link) error {
@@ -131,11 +222,13 @@ func (b *BrainLocal) TrigLinks(links ...core.Link) error {
 func (b *BrainLocal) Entry() error {
 	// get all entry links
 	linkIDs := make([]string, 0)
+	b.topoMu.RLock()
 	for _, l := range b.links {
 		if l.isEntryLink() {
 			linkIDs = append(linkIDs, l.id)
 		}
 	}
+	b.topoMu.RUnlock()
 
 	return b.trigLinks(linkIDs...)
 }
@@ -149,61 +242,192 @@ func (b *BrainLocal) EntryWithMemory(keysAndValues ...interface{}) error {
 }
 
 func (b *BrainLocal) SetMemory(keysAndValues ...interface{}) error {
+	return b.setMemory("", keysAndValues...)
+}
+
+// setMemory sets memories written by neuron, neuronID is empty if memories are set outside of neurons.
+// The memories are set in one transaction, so the others never see a part of them.
+func (b *BrainLocal) setMemory(neuronID string, keysAndValues ...interface{}) error {
 	if len(keysAndValues)%2 != 0 {
 		return fmt.Errorf("key and value are not paired")
 	}
-	if err := b.ensureMemoryInit(); err != nil {
-		// TODO wrap error
-		return err
-	}
-
-	for i := 0; i < len(keysAndValues); i += 2 {
-		k := keysAndValues[i]
-		v := keysAndValues[i+1]
-		b.BrainMemory.cache.Set(k, v, 1) // TODO maybe calculate cost
-		b.logger.Debug().
-			Any("key", k).
-			Any("value", v).
-			Msg("set memory")
-	}
-	b.BrainMemory.cache.Wait()
 
-	return nil
+	return b.updateMemory(neuronID, func(tx processor.MemoryTx) error {
+		return tx.SetMemory(keysAndValues...)
+	})
 }
 
 func (b *BrainLocal) GetMemory(key any) any {
-	if b.BrainMemory.cache == nil {
-		return nil
-	}
-	v, _ := b.BrainMemory.cache.Get(key)
+	b.rlockMemory()
+	defer b.BrainMemory.mu.RUnlock()
+	v, _ := b.getMemory(key)
 
 	return v
 }
 
 func (b *BrainLocal) ExistMemory(key any) bool {
-	if b.BrainMemory.cache == nil {
-		return false
+	b.rlockMemory()
+	defer b.BrainMemory.mu.RUnlock()
+	_, ok := b.getMemory(key)
+
+	return ok
+}
+
+// getMemory gets memory without lock
+func (b *BrainLocal) getMemory(key any) (any, bool) {
+	if b.BrainMemory.store == nil {
+		return nil, false
 	}
 
-	_, ok := b.BrainMemory.cache.Get(key)
+	storeKey := b.memKey(key)
+	// the expired memory may be not deleted by the sweeper yet
+	if b.BrainMemory.expires.Expired(storeKey, time.Now()) {
+		return nil, false
+	}
+	v, ok, err := b.BrainMemory.store.Get(storeKey)
+	if err != nil {
+		b.logger.Error().Err(err).Any("key", key).Msg("get memory failed")
+		return nil, false
+	}
 
-	return ok
+	return v, ok
 }
 
 func (b *BrainLocal) DeleteMemory(key any) {
-	if b.BrainMemory.cache == nil {
+	b.deleteMemory("", key)
+}
+
+func (b *BrainLocal) deleteMemory(neuronID string, key any) {
+	if b.BrainMemory.store == nil {
 		return
 	}
 
-	b.BrainMemory.cache.Del(key)
+	_ = b.updateMemory(neuronID, func(tx processor.MemoryTx) error {
+		tx.DeleteMemory(key)
+		return nil
+	})
 }
 
 func (b *BrainLocal) ClearMemory() {
-	if b.BrainMemory.cache == nil {
+	b.clearMemory("")
+}
+
+func (b *BrainLocal) clearMemory(neuronID string) {
+	b.BrainMemory.mu.Lock()
+	if b.BrainMemory.store == nil {
+		b.BrainMemory.mu.Unlock()
 		return
 	}
 
-	b.BrainMemory.cache.Clear()
+	// the store is shared by brain and its threads, only the memories of the owner are deleted
+	storeKeys, err := b.BrainMemory.store.Keys()
+	if err != nil {
+		b.logger.Error().Err(err).Msg("clear memory failed")
+	}
+	for _, storeKey := range storeKeys {
+		if !b.ownsStoreKey(storeKey) {
+			continue
+		}
+		if err = b.BrainMemory.store.Delete(storeKey); err != nil {
+			b.logger.Error().Err(err).Any("key", storeKey).Msg("delete memory failed")
+		}
+	}
+	b.BrainMemory.clearKeys()
+	b.BrainMemory.mu.Unlock()
+
+	b.notifyMemory()
+	b.BrainMemory.watchers.Publish(processor.MemoryChange{Cleared: true, NeuronID: neuronID})
+}
+
+// WatchMemory returns a channel of changes of memories of keys, all memories are watched if keys is empty.
+// The channel is closed when ctx is done. Changes are queued for a slow watcher, it does not block the writers.
+func (b *BrainLocal) WatchMemory(ctx context.Context, keys ...any) <-chan processor.MemoryChange {
+	return b.BrainMemory.watchers.Watch(ctx, keys...)
+}
+
+// ListMemoryKeys lists keys of all memories of brain, in no particular order
+func (b *BrainLocal) ListMemoryKeys() ([]any, error) {
+	b.rlockMemory()
+	defer b.BrainMemory.mu.RUnlock()
+
+	now := time.Now()
+	keys := make([]any, 0)
+	for _, k := range b.BrainMemory.listKeys() {
+		if !b.BrainMemory.expires.Expired(b.memKey(k), now) {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys, nil
+}
+
+// RangeMemory calls fn for every memory of brain in no particular order, until fn returns false.
+// fn sees the memories when RangeMemory is called, and it may call the memory methods of brain.
+func (b *BrainLocal) RangeMemory(fn func(key, value any) bool) error {
+	// memories are read before fn is called, so the read does not block the writes of fn
+	entries, err := b.listMemory()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !fn(e.key, e.value) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// DumpMemory copies all memories of brain, a key of bytes is a string in the map
+func (b *BrainLocal) DumpMemory() (map[any]any, error) {
+	entries, err := b.listMemory()
+	if err != nil {
+		return nil, err
+	}
+	memories := make(map[any]any, len(entries))
+	for _, e := range entries {
+		key := e.key
+		if bs, ok := key.([]byte); ok {
+			key = string(bs)
+		}
+		memories[key] = e.value
+	}
+
+	return memories, nil
+}
+
+// memoryEntry is a memory with its key
+type memoryEntry struct {
+	key   any
+	value any
+}
+
+// listMemory reads all memories of brain
+func (b *BrainLocal) listMemory() ([]memoryEntry, error) {
+	b.rlockMemory()
+	defer b.BrainMemory.mu.RUnlock()
+
+	entries := make([]memoryEntry, 0)
+	if b.BrainMemory.store == nil {
+		return entries, nil
+	}
+	now := time.Now()
+	for _, k := range b.BrainMemory.listKeys() {
+		storeKey := b.memKey(k)
+		if b.BrainMemory.expires.Expired(storeKey, now) {
+			continue
+		}
+		v, ok, err := b.BrainMemory.store.Get(storeKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "dump memory %v failed", k)
+		}
+		// it may be evicted after listed
+		if ok {
+			entries = append(entries, memoryEntry{key: k, value: v})
+		}
+	}
+
+	return entries, nil
 }
 
 func (b *BrainLocal) GetState() core.BrainState {
@@ -212,25 +436,83 @@ func (b *BrainLocal) GetState() core.BrainState {
 
 func (b *BrainLocal) Wait() {
 	// block when brain running
-	b.mu.Lock()
-	for b.state != core.BrainStateSleeping && b.state != core.BrainStateShutdown {
-		b.cond.Wait()
+	_ = b.waitUntil(context.Background(), b.isWaitDone)
+}
+
+// WaitContext blocks until brain is sleeping, shutdown or interrupted, or ctx is done
+func (b *BrainLocal) WaitContext(ctx context.Context) error {
+	return b.waitUntil(ctx, b.isWaitDone)
+}
+
+// WaitFor blocks until the memory of key satisfies predicate, or ctx is done, and returns the memory.
+// It does not wait brain to sleep, so it works for a brain which keeps running.
+// predicate gets nil if the memory does not exist, it is checked again whenever memory is changed.
+func (b *BrainLocal) WaitFor(ctx context.Context, key any, predicate func(value any) bool) (any, error) {
+	for {
+		b.mu.Lock()
+		version := b.memVersion
+		b.mu.Unlock()
+
+		value := b.GetMemory(key)
+		if predicate(value) {
+			return value, nil
+		}
+		err := b.waitUntil(ctx, func() bool {
+			return b.memVersion != version
+		})
+		if err != nil {
+			return value, err
+		}
 	}
+}
+
+// notifyMemory wakes up the goroutines waiting for memory
+func (b *BrainLocal) notifyMemory() {
+	b.mu.Lock()
+	b.memVersion++
+	b.cond.Broadcast()
 	b.mu.Unlock()
 }
 
-func (b *BrainLocal) Shutdown() {
-	b.logger.Info().Msg("brain local shutdown")
-	close(b.BrainMaintainer.nQueue)
-	close(b.BrainMaintainer.bQueue)
-	b.BrainMemory.cache.Close()
-	b.setState(core.BrainStateShutdown)
+// Shutdown shuts down brain gracefully: triggers are not accepted, the in-flight processors are waited,
+// the queued events are flushed, then the memory is closed. When ctx is done before the processors return,
+// their contexts are cancelled, and the memory is closed after they return, the error of ctx is returned.
+// Shutdown can be called repeatedly, and brain is started again by Entry or TrigLinks after shutdown.
+func (b *BrainLocal) Shutdown(ctx context.Context) error {
+	if b.root != nil {
+		return b.shutdownThread(ctx)
+	}
+	err := b.shutdownThreads(ctx)
+
+	b.lifecycleMu.Lock()
+	defer b.lifecycleMu.Unlock()
+	if b.getState() != core.BrainStateShutdown {
+		b.logger.Info().Msg("brain local shutdown")
+		if stopErr := b.stopMaintainer(ctx); stopErr != nil {
+			err = stopErr
+		}
+	}
+	b.BrainMemory.sweeper.Stop()
+	b.BrainMemory.mu.Lock()
+	if b.BrainMemory.store != nil {
+		if closeErr := b.BrainMemory.store.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		b.BrainMemory.store = nil
+		b.BrainMemory.clearKeys()
+	}
+	b.BrainMemory.mu.Unlock()
+
+	return err
 }
 
 func (b *BrainLocal) trigLinks(linkIDs ...string) error {
 	if len(linkIDs) == 0 {
 		return nil
 	}
+	if b.stopping.Load() {
+		return fmt.Errorf("brain %s is shutting down", b.id)
+	}
 
 	if err := b.ensureMemoryInit(); err != nil {
 		// TODO wrap error
@@ -240,44 +522,56 @@ func (b *BrainLocal) trigLinks(linkIDs ...string) error {
 	// ensure brain maintainer start
 	b.ensureMaintainerStart()
 
-	// goroutine wait
-	var wg sync.WaitGroup
+	// link states are read by the maintainer step holding the read lock, so they are changed with the write lock
+	b.topoMu.Lock()
+	events := make([]maintainEvent, 0, len(linkIDs))
 	for _, linkID := range linkIDs {
 		l, ok := b.links[linkID]
-		if !ok {
+		if !ok || l.status.state == core.LinkStateReady {
 			continue
 		}
+		// change link state as ready
+		l.status.state = core.LinkStateReady
+		events = append(events, maintainEvent{
+			kind:   eventKindLink,
+			action: eventActionLinkReady,
+			id:     l.id,
+		})
+	}
+	b.topoMu.Unlock()
 
-		wg.Add(1)
-		go b.trigLink(&wg, l)
+	// the event queue may be full, and the maintainer waits for topoMu while Apply is waiting for it,
+	// so the events are sent without topoMu
+	for _, event := range events {
+		b.publishEvent(event)
 	}
-	wg.Wait()
 
+	b.topoMu.RLock()
 	b.refreshState()
+	b.topoMu.RUnlock()
 
 	return nil
 }
 
-func (b *BrainLocal) trigLink(wg *sync.WaitGroup, l *link) {
-	defer wg.Done()
-
-	if l.status.state != core.LinkStateReady {
-		// change link state as ready
-		l.status.state = core.LinkStateReady
-
-		// send maintain event
-		b.publishEvent(maintainEvent{
-			kind:   eventKindLink,
-			action: eventActionLinkReady,
-			id:     l.id,
-		})
+// rlockMemory read locks the memory, the store is opened first if it is not,
+// so the memories kept by a persistent store before brain is built are read
+func (b *BrainLocal) rlockMemory() {
+	b.BrainMemory.mu.RLock()
+	if b.BrainMemory.store != nil || b.root != nil {
+		return
 	}
+	b.BrainMemory.mu.RUnlock()
 
-	return
+	b.BrainMemory.mu.Lock()
+	if err := b.ensureMemoryInit(); err != nil {
+		b.logger.Error().Err(err).Msg("init memory failed")
+	}
+	b.BrainMemory.mu.Unlock()
+	b.BrainMemory.mu.RLock()
 }
 
 func (b *BrainLocal) ensureMemoryInit() error {
-	if b.BrainMemory.cache != nil {
+	if b.BrainMemory.store != nil {
 		return nil
 	}
 
@@ -285,16 +579,173 @@ func (b *BrainLocal) ensureMemoryInit() error {
 }
 
 func (b *BrainLocal) initMemory() error {
-	cache, err := ristretto.NewCache(&ristretto.Config{
-		NumCounters: b.BrainMemory.numCounters,
-		MaxCost:     b.BrainMemory.maxCost,
-		BufferItems: 64, // number of keys per Get buffer.
-	})
+	store, err := b.BrainMemory.newStore()
 	if err != nil {
-		// TODO Wrap error
+		return errors.Wrapf(err, "init memory store failed")
+	}
+	if evicting, ok := store.(core.EvictingMemoryStore); ok {
+		evicting.OnEvict(b.onStoreEvicted)
+	}
+	if shared, ok := store.(core.SharedMemoryStore); ok {
+		shared.OnChange(b.onStoreChanged)
+	}
+	if err = b.loadKeys(store); err != nil {
+		_ = store.Close()
 		return err
 	}
-	b.BrainMemory.cache = cache
+	b.BrainMemory.store = store
+
+	return nil
+}
+
+// loadKeys indexes the memories of brain or thread kept by store before it is opened, e.g. by a persistent store
+func (b *BrainLocal) loadKeys(store core.MemoryStore) error {
+	storeKeys, err := store.Keys()
+	if err != nil {
+		return errors.Wrapf(err, "list keys of memory store failed")
+	}
+	for _, storeKey := range storeKeys {
+		if key, ok := b.keyOf(storeKey); ok {
+			b.BrainMemory.addKey(storeKey, key)
+		}
+	}
 
 	return nil
 }
+
+// onStoreEvicted finds the brain or the thread which owns the memory dropped by the store.
+// It may be called by the goroutines of the store with the store locked,
+// so neither the store nor BrainMemory.mu can be taken
+func (b *BrainLocal) onStoreEvicted(storeKey, value any) {
+	if key, ok := b.BrainMemory.lookupKey(storeKey); ok {
+		b.onMemoryEvicted(key, storeKey, value)
+		return
+	}
+	for _, t := range b.listThreads() {
+		if key, ok := t.BrainMemory.lookupKey(storeKey); ok {
+			t.onMemoryEvicted(key, storeKey, value)
+			return
+		}
+	}
+}
+
+// onStoreChanged reports a change of memory made by the other users of a shared store to the owner of the memory,
+// a change in the namespace of a thread not running in this process is ignored
+func (b *BrainLocal) onStoreChanged(change core.MemoryStoreChange) {
+	owner, key := b, change.Key
+	if change.Cleared {
+		// the whole store is cleared, the memories of threads are gone too
+		b.BrainMemory.clearKeys()
+		for _, t := range b.listThreads() {
+			t.BrainMemory.clearKeys()
+			t.notifyMemory()
+			t.BrainMemory.watchers.Publish(processor.MemoryChange{Cleared: true})
+		}
+	} else if k, ok := b.BrainMemory.lookupKey(change.Key); ok {
+		key = k
+	} else if k, ok = rootKey(change.Key); ok {
+		key = k
+	} else {
+		owner = nil
+		for _, t := range b.listThreads() {
+			if k, ok := t.BrainMemory.lookupKey(change.Key); ok {
+				owner, key = t, k
+				break
+			}
+		}
+		// the thread is not running in this process
+		if owner == nil {
+			return
+		}
+	}
+
+	memChange := processor.MemoryChange{Key: key, Cleared: change.Cleared}
+	switch {
+	case change.Cleared:
+	case change.Deleted:
+		owner.BrainMemory.delKey(change.Key)
+	default:
+		// the memory is set by the other without TTL
+		owner.BrainMemory.expires.Unset(change.Key)
+		owner.BrainMemory.addKey(change.Key, key)
+		memChange.NewValue = change.Value
+	}
+	owner.notifyMemory()
+	owner.BrainMemory.watchers.Publish(memChange)
+}
+
+// onMemoryEvicted reports a memory dropped by the store, the memory dropped after its TTL passed is expired
+func (b *BrainLocal) onMemoryEvicted(key, storeKey, value any) {
+	expired := b.BrainMemory.expires.Expired(storeKey, time.Now())
+	// it may be deleted by the sweeper already
+	if !b.BrainMemory.delKey(storeKey) {
+		return
+	}
+	if expired {
+		b.onMemoryExpired(key, value)
+		return
+	}
+	b.logger.Warn().
+		Any("key", key).
+		Msg("memory evicted")
+	b.notifyMemory()
+	b.BrainMemory.watchers.Publish(processor.MemoryChange{Key: key, OldValue: value, Evicted: true})
+}
+
+func (m *BrainMemory) addKey(storeKey, key any) {
+	k, ok := memkey.Of(storeKey)
+	if !ok {
+		return
+	}
+	m.keysMu.Lock()
+	defer m.keysMu.Unlock()
+	if m.keys == nil {
+		m.keys = make(map[any]any)
+	}
+	m.keys[k] = key
+}
+
+// delKey deletes storeKey from the keys, and returns whether it was there
+func (m *BrainMemory) delKey(storeKey any) bool {
+	k, ok := memkey.Of(storeKey)
+	if !ok {
+		return false
+	}
+	m.expires.Unset(storeKey)
+	m.keysMu.Lock()
+	defer m.keysMu.Unlock()
+	_, ok = m.keys[k]
+	delete(m.keys, k)
+
+	return ok
+}
+
+func (m *BrainMemory) lookupKey(storeKey any) (any, bool) {
+	k, ok := memkey.Of(storeKey)
+	if !ok {
+		return nil, false
+	}
+	m.keysMu.Lock()
+	defer m.keysMu.Unlock()
+	key, ok := m.keys[k]
+
+	return key, ok
+}
+
+func (m *BrainMemory) clearKeys() {
+	m.keysMu.Lock()
+	defer m.keysMu.Unlock()
+	m.keys = nil
+	m.expires.Reset()
+}
+
+func (m *BrainMemory) listKeys() []any {
+	m.keysMu.Lock()
+	defer m.keysMu.Unlock()
+	keys := make([]any, 0, len(m.keys))
+	for _, k := range m.keys {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
@@ -26,8 +26,13 @@ const (
 	eventActionNeuronTryInactive eventAction = "try_inactive_neuron"
 	eventActionNeuronTryCast     eventAction = "try_cast"
 	eventActionNeuronCastAnyway  eventAction = "cast_anyway"
+	eventActionNeuronInterrupt   eventAction = "interrupt_neuron"
 	eventActionBrainSleep        eventAction = "brain_sleep"
 	eventActionBrainShutdown     eventAction = "brain_shutdown"
+	eventActionBrainResume       eventAction = "brain_resume"
+	eventActionBrainReject       eventAction = "brain_reject"
+	// topology of brain is changed by Apply
+	eventActionBrainTopologyChanged eventAction = "topology_changed"
 )
 
 func (m maintainEvent) MarshalZerologObject(e *zerolog.Event) {
@@ -42,5 +47,16 @@ func (b *BrainLocal) publishEvent(event maintainEvent) {
 	}
 	b.logger.Debug().Interface("event", event).Msg("publish maintain event")
 
-	b.bQueue <- event
+	b.pending.AddEvent(event)
+	select {
+	case <-b.stop:
+		// maintainer is flushing, the event is handled if the queue has room
+		if !b.bQueue.TryPush(event) {
+			// the dropped event is never handled, it is not pending
+			b.pending.DoneEvent(event)
+		}
+		return
+	default:
+	}
+	b.bQueue.Push(event)
 }
@@ -0,0 +1,135 @@
+package brainlocal
+
+import (
+	"fmt"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/interrupt"
+)
+
+// ListInterrupts lists the interrupt points where the run is parked, interrupt points of threads are included
+func (b *BrainLocal) ListInterrupts() []core.Interrupt {
+	ret := b.listInterrupts()
+	for _, t := range b.listThreads() {
+		ret = append(ret, t.listInterrupts()...)
+	}
+
+	return ret
+}
+
+func (b *BrainLocal) listInterrupts() []core.Interrupt {
+	b.topoMu.RLock()
+	defer b.topoMu.RUnlock()
+	ret := make([]core.Interrupt, 0)
+	for _, n := range b.neurons {
+		point, ok := interrupt.PointOf(n.status.state)
+		if !ok {
+			continue
+		}
+		ret = append(ret, core.Interrupt{
+			RunID:    b.runID(),
+			NeuronID: n.id,
+			Point:    point,
+			Memory:   b.newBrainContext(n.id),
+		})
+	}
+
+	return ret
+}
+
+// Resume updates memory of the run, then continues all interrupt points of the run.
+// The neurons parked before activated are activated, the neurons parked after processed cast.
+func (b *BrainLocal) Resume(runID string, keysAndValues ...any) error {
+	if t := b.Thread(runID); t != nil {
+		return t.Resume(runID, keysAndValues...)
+	}
+	if err := b.takeInterrupts(runID); err != nil {
+		return err
+	}
+	if err := b.SetMemory(keysAndValues...); err != nil {
+		// the interrupt points are given back, so the run can be resumed again
+		b.resolving.Release()
+		return err
+	}
+
+	b.logger.Info().Str("runID", runID).Msg("resume interrupted run")
+	b.setState(core.BrainStateRunning)
+	b.publishEvent(maintainEvent{
+		kind:   eventKindBrain,
+		action: eventActionBrainResume,
+		id:     runID,
+	})
+
+	return nil
+}
+
+// Reject drops all interrupt points of the run.
+// The neurons parked before activated are not activated, the neurons parked after processed do not cast.
+func (b *BrainLocal) Reject(runID string) error {
+	if t := b.Thread(runID); t != nil {
+		return t.Reject(runID)
+	}
+	if err := b.takeInterrupts(runID); err != nil {
+		return err
+	}
+
+	b.logger.Info().Str("runID", runID).Msg("reject interrupted run")
+	b.setState(core.BrainStateRunning)
+	b.publishEvent(maintainEvent{
+		kind:   eventKindBrain,
+		action: eventActionBrainReject,
+		id:     runID,
+	})
+
+	return nil
+}
+
+// takeInterrupts takes the interrupt points of the run for Resume or Reject, threads take their own
+func (b *BrainLocal) takeInterrupts(runID string) error {
+	if runID != b.runID() {
+		return fmt.Errorf("run %s not found in brain %s", runID, b.id)
+	}
+
+	return b.resolving.Take(runID, func() bool {
+		return len(b.listInterrupts()) > 0
+	})
+}
+
+func (b *BrainLocal) resumeInterrupts() {
+	for _, n := range b.neurons {
+		switch n.status.state {
+		case core.NeuronStateInterruptedBefore:
+			n.status.state = core.NeuronStateInactive
+			b.publishEventActivateNeuron(n.id)
+		case core.NeuronStateInterruptedAfter:
+			n.status.state = core.NeuronStateInactive
+			b.publishEvent(maintainEvent{
+				kind:   eventKindNeuron,
+				action: eventActionNeuronTryCast,
+				id:     n.id,
+			})
+		}
+	}
+}
+
+func (b *BrainLocal) rejectInterrupts() {
+	for _, n := range b.neurons {
+		switch n.status.state {
+		case core.NeuronStateInterruptedBefore:
+			// consume the ready in-links, as if neuron was activated
+			for _, links := range n.spec.triggerGroups {
+				for _, l := range links {
+					l.status.state = core.LinkStateInit
+				}
+			}
+			n.status.state = core.NeuronStateInactive
+		case core.NeuronStateInterruptedAfter:
+			for _, links := range n.spec.castGroups {
+				for _, l := range links {
+					l.status.state = core.LinkStateInit
+				}
+			}
+			n.status.state = core.NeuronStateInactive
+		}
+	}
+}
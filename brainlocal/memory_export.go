@@ -0,0 +1,41 @@
+package brainlocal
+
+import (
+	"io"
+
+	"github.com/Rovanta/rmodel/codec"
+	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// ExportMemory writes all memories of brain to w as a portable JSON document, see codec.Document.
+// Keys are strings, integers, floats or bools, and the values of the types registered in codec.Default
+// keep their type names. The memories of the threads are exported by the threads.
+func (b *BrainLocal) ExportMemory(w io.Writer) error {
+	memories, err := b.DumpMemory()
+	if err != nil {
+		return err
+	}
+	if err = codec.Default.WriteMemory(w, memories); err != nil {
+		return errors.Wrapf(err, "export memory failed")
+	}
+
+	return nil
+}
+
+// ImportMemory sets the memories of a document written by ExportMemory in one transaction, the reducers of keys
+// are not applied and the other memories are kept. The values are read as BrainLite reads them: a value of a type
+// registered in codec.Default is decoded to the type, and the others from their JSON, e.g. a struct to map[string]any.
+func (b *BrainLocal) ImportMemory(r io.Reader) error {
+	memories, err := codec.Default.ReadMemory(r)
+	if err != nil {
+		return errors.Wrapf(err, "import memory failed")
+	}
+
+	return b.updateMemory("", func(tx processor.MemoryTx) error {
+		for k, v := range memories {
+			tx.(*memoryTx).put(k, v, 0)
+		}
+		return nil
+	})
+}
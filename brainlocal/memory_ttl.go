@@ -0,0 +1,79 @@
+package brainlocal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Rovanta/rmodel/processor"
+)
+
+const defaultSweepInterval = 10 * time.Second
+
+// SetMemoryWithTTL sets memory of key which expires after ttl. An expired memory is not read any more, and it is
+// deleted by the sweeper of brain, which logs and counts it, and sends it to the watchers with Expired set.
+// The memory set again without TTL never expires.
+func (b *BrainLocal) SetMemoryWithTTL(key, value any, ttl time.Duration) error {
+	return b.setMemoryWithTTL("", key, value, ttl)
+}
+
+func (b *BrainLocal) setMemoryWithTTL(neuronID string, key, value any, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("ttl %s of memory %v is not positive", ttl, key)
+	}
+
+	return b.updateMemory(neuronID, func(tx processor.MemoryTx) error {
+		return tx.(*memoryTx).set(key, value, ttl)
+	})
+}
+
+// ExpiredMemoryCount returns the number of memories of brain deleted as their TTL passed
+func (b *BrainLocal) ExpiredMemoryCount() uint64 {
+	return b.BrainMemory.expired.Load()
+}
+
+// sweepMemory deletes the expired memories of brain, then reports them
+func (b *BrainLocal) sweepMemory() {
+	type expiredMemory struct {
+		key, value any
+	}
+
+	b.BrainMemory.mu.Lock()
+	if b.BrainMemory.store == nil {
+		b.BrainMemory.mu.Unlock()
+		return
+	}
+	expired := make([]expiredMemory, 0)
+	for _, storeKey := range b.BrainMemory.expires.List(time.Now()) {
+		key, ok := b.BrainMemory.lookupKey(storeKey)
+		if !ok {
+			continue
+		}
+		// the store supporting TTL may have dropped it already
+		value, _, err := b.BrainMemory.store.Get(storeKey)
+		if err == nil {
+			err = b.BrainMemory.store.Delete(storeKey)
+		}
+		if err != nil {
+			b.logger.Error().Err(err).Any("key", key).Msg("delete expired memory failed")
+			continue
+		}
+		if b.BrainMemory.delKey(storeKey) {
+			expired = append(expired, expiredMemory{key: key, value: value})
+		}
+	}
+	b.BrainMemory.mu.Unlock()
+
+	for _, e := range expired {
+		b.onMemoryExpired(e.key, e.value)
+	}
+}
+
+// onMemoryExpired reports a memory deleted as its TTL passed
+func (b *BrainLocal) onMemoryExpired(key, value any) {
+	b.BrainMemory.expired.Add(1)
+	b.logger.Info().
+		Any("key", key).
+		Msg("memory expired")
+	b.notifyMemory()
+	b.BrainMemory.watchers.Publish(processor.MemoryChange{Key: key, OldValue: value, Expired: true})
+}
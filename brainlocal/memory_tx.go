@@ -0,0 +1,185 @@
+package brainlocal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Rovanta/rmodel/internal/memkey"
+	"github.com/Rovanta/rmodel/internal/memtx"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// UpdateMemory runs fn in a memory transaction. The reads of tx see the memories when the transaction began
+// and its own writes, and the writes are committed together only if fn returns nil.
+// The transaction is isolated in the process only, a store shared by processes may be written by the others.
+// The transactions of brain are serialized, so fn should be short and must not call the memory methods of brain.
+func (b *BrainLocal) UpdateMemory(fn func(tx processor.MemoryTx) error) error {
+	return b.updateMemory("", fn)
+}
+
+// CompareAndSwapMemory sets key to new if its value deeply equals old, old is nil for a key not existing.
+// It returns whether the value is swapped.
+func (b *BrainLocal) CompareAndSwapMemory(key, old, new any) (bool, error) {
+	return b.compareAndSwapMemory("", key, old, new)
+}
+
+func (b *BrainLocal) compareAndSwapMemory(neuronID string, key, old, new any) (bool, error) {
+	return memtx.CompareAndSwap(func(fn func(tx processor.MemoryTx) error) error {
+		return b.updateMemory(neuronID, fn)
+	}, key, old, new)
+}
+
+// updateMemory runs fn in a memory transaction written by neuron, neuronID is empty outside of neurons
+func (b *BrainLocal) updateMemory(neuronID string, fn func(tx processor.MemoryTx) error) error {
+	tx, changes, err := b.commitMemory(neuronID, fn)
+	if tx == nil {
+		return err
+	}
+
+	if tx.writes.Len() > 0 {
+		b.notifyMemory()
+	}
+	for _, c := range changes {
+		b.BrainMemory.watchers.Publish(c)
+	}
+
+	return err
+}
+
+// commitMemory runs fn in a memory transaction and commits it with BrainMemory.mu held,
+// tx is nil if memory failed to initialize or fn failed
+func (b *BrainLocal) commitMemory(neuronID string, fn func(tx processor.MemoryTx) error) (*memoryTx, []processor.MemoryChange, error) {
+	b.BrainMemory.mu.Lock()
+	defer b.BrainMemory.mu.Unlock()
+	if err := b.ensureMemoryInit(); err != nil {
+		// TODO wrap error
+		return nil, nil, err
+	}
+
+	tx := &memoryTx{b: b}
+	if err := fn(tx); err != nil {
+		return nil, nil, err
+	}
+	changes, err := tx.commit(neuronID)
+
+	return tx, changes, err
+}
+
+// memoryTx keeps the writes of a transaction until it is committed, BrainMemory.mu is held during its life
+type memoryTx struct {
+	b *BrainLocal
+	// writes are kept by the keys of memories, they are written to store by the keys in store
+	writes memtx.Writes
+}
+
+func (tx *memoryTx) GetMemory(key any) any {
+	v, _ := tx.get(key)
+
+	return v
+}
+
+func (tx *memoryTx) ExistMemory(key any) bool {
+	_, ok := tx.get(key)
+
+	return ok
+}
+
+func (tx *memoryTx) SetMemory(keysAndValues ...interface{}) error {
+	return memtx.SetPairs(keysAndValues, func(key, value any) error {
+		return tx.set(key, value, 0)
+	})
+}
+
+// set writes memory of key reduced by the reducer of key, the memory expires after ttl if ttl > 0
+func (tx *memoryTx) set(key, value any, ttl time.Duration) error {
+	if _, ok := memkey.Of(key); !ok {
+		return fmt.Errorf("key type %T is not comparable", key)
+	}
+	value, err := memtx.Reduce(tx.b.BrainMemory.reducers, key, value, tx.GetMemory)
+	if err != nil {
+		return err
+	}
+	tx.put(key, value, ttl)
+
+	return nil
+}
+
+// DeleteMemory deletes memory of key, a key which is not comparable is never set, so deleting it does nothing
+func (tx *memoryTx) DeleteMemory(key any) {
+	tx.writes.Put(memtx.Write{Key: key, Deleted: true})
+}
+
+func (tx *memoryTx) get(key any) (any, bool) {
+	if _, ok := memkey.Of(key); !ok {
+		return nil, false
+	}
+	if w, ok := tx.writes.Get(key); ok {
+		return w.Value, !w.Deleted
+	}
+
+	return tx.b.getMemory(key)
+}
+
+// put writes memory of key as it is, without the reducer of key
+func (tx *memoryTx) put(key, value any, ttl time.Duration) {
+	tx.writes.Put(memtx.Write{Key: key, Value: value, TTL: ttl})
+}
+
+// commit writes the memories to store all or nothing, and returns the changes for watchers.
+// The keys are indexed before the store may evict them, and they are unindexed if the commit failed
+func (tx *memoryTx) commit(neuronID string) ([]processor.MemoryChange, error) {
+	// before is a memory before the transaction
+	type before struct {
+		value   any
+		existed bool
+		indexed bool
+	}
+
+	b := tx.b
+	writes := tx.writes.List()
+	befores := make([]before, 0, len(writes))
+	storeWrites := make([]memtx.Write, 0, len(writes))
+	for _, w := range writes {
+		storeKey := b.memKey(w.Key)
+		var bf before
+		bf.value, bf.existed = b.getMemory(w.Key)
+		_, bf.indexed = b.BrainMemory.lookupKey(storeKey)
+		befores = append(befores, bf)
+		if !w.Deleted {
+			b.BrainMemory.addKey(storeKey, w.Key)
+		}
+		sw := w
+		sw.Key = storeKey
+		storeWrites = append(storeWrites, sw)
+	}
+	if err := memtx.Commit(b.BrainMemory.store, &b.BrainMemory.expires, storeWrites); err != nil {
+		for i, sw := range storeWrites {
+			if !befores[i].indexed {
+				b.BrainMemory.delKey(sw.Key)
+			}
+		}
+		return nil, err
+	}
+
+	changes := make([]processor.MemoryChange, 0, len(writes))
+	for i, w := range writes {
+		bf := befores[i]
+		if w.Deleted {
+			b.BrainMemory.delKey(storeWrites[i].Key)
+			if bf.existed {
+				changes = append(changes, processor.MemoryChange{Key: w.Key, OldValue: bf.value, NeuronID: neuronID})
+			}
+			continue
+		}
+		if w.TTL > 0 {
+			b.BrainMemory.sweeper.Start(b.BrainMemory.sweepInterval, b.sweepMemory)
+		}
+		changes = append(changes, processor.MemoryChange{Key: w.Key, OldValue: bf.value, NewValue: w.Value, NeuronID: neuronID})
+		b.logger.Debug().
+			Any("key", w.Key).
+			Any("value", w.Value).
+			Msg("set memory")
+	}
+
+	return changes, nil
+}
@@ -1,6 +1,8 @@
 package brainlocal
 
 import (
+	"strconv"
+
 	"github.com/Rovanta/rmodel/core"
 	"github.com/Rovanta/rmodel/internal/utils"
 	"github.com/Rovanta/rmodel/processor"
@@ -22,6 +24,8 @@ type neuronSpec struct {
 
 type neuronStatus struct {
 	state core.NeuronState
+	// cast is the last cast of neuron in the run
+	cast  *core.CastExplanation
 	count struct {
 		process int
 		succeed int
@@ -29,6 +33,20 @@ type neuronStatus struct {
 	}
 }
 
+func (n *neuron) hasLabel(key string) bool {
+	return n.labels[key] == "true"
+}
+
+// priority is the priority label of neuron, an invalid priority is 0
+func (n *neuron) priority() int {
+	p, _ := strconv.Atoi(n.labels[core.NeuronLabelPriority])
+	return p
+}
+
+func (n *neuron) isInterrupted() bool {
+	return n.status.state == core.NeuronStateInterruptedBefore || n.status.state == core.NeuronStateInterruptedAfter
+}
+
 func newNeuron(n core.Neuron, linkMap map[string]*link) *neuron {
 	neu := &neuron{
 		id:     n.GetID(),
@@ -60,3 +78,52 @@ func newNeuron(n core.Neuron, linkMap map[string]*link) *neuron {
 
 	return neu
 }
+
+// clone copies neuron with initial status, the groups refer to links of linkMap
+func (n *neuron) clone(linkMap map[string]*link) *neuron {
+	neu := &neuron{
+		id:     n.id,
+		labels: utils.LabelsDeepCopy(n.labels),
+		spec: neuronSpec{
+			processor:     n.spec.processor,
+			selector:      n.spec.selector,
+			triggerGroups: make(map[string][]*link),
+			castGroups:    make(map[string][]*link),
+		},
+		status: neuronStatus{
+			state: core.NeuronStateInactive,
+		},
+	}
+
+	for gName, links := range n.spec.triggerGroups {
+		neu.spec.triggerGroups[gName] = make([]*link, len(links))
+		for i, l := range links {
+			neu.spec.triggerGroups[gName][i] = linkMap[l.id]
+		}
+	}
+
+	for gName, links := range n.spec.castGroups {
+		neu.spec.castGroups[gName] = make([]*link, len(links))
+		for i, l := range links {
+			neu.spec.castGroups[gName][i] = linkMap[l.id]
+		}
+	}
+
+	return neu
+}
+
+// newEndNeuron creates the END neuron, it is added by patch when brain has no end link
+func newEndNeuron() *neuron {
+	return &neuron{
+		id:     core.EndNeuronID,
+		labels: make(map[string]string),
+		spec: neuronSpec{
+			processor:     &processor.EmptyProcessor{},
+			triggerGroups: make(map[string][]*link),
+			castGroups:    make(map[string][]*link),
+		},
+		status: neuronStatus{
+			state: core.NeuronStateInactive,
+		},
+	}
+}
@@ -5,38 +5,95 @@ import (
 
 	"github.com/Rovanta/rmodel/core"
 	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/internal/queue"
 )
 
+// activation is an entry of neuron process queue, b is the brain or the thread which the neuron belongs to
+type activation struct {
+	b        *BrainLocal
+	neuronID string
+}
+
+// publishEventActivateNeuron queues the activation of neuron by its priority, topoMu must be held.
+// The neuron queue may be full, and its workers wait for topoMu while Apply is waiting for it,
+// so the activation is pushed by flushActivations after topoMu is released
 func (b *BrainLocal) publishEventActivateNeuron(neuronID string) {
-	if b.getState() == core.BrainStateShutdown || b.nQueue == nil {
+	runner := b.runner()
+	if b.getState() == core.BrainStateShutdown || runner.nQueue == nil {
 		return
 	}
 	b.logger.Debug().Interface("neuronID", neuronID).Msg("publish activate neuron event")
 
-	b.nQueue <- neuronID
+	b.pending.AddActivation(neuronID)
+	// brain is shutting down, the activation is kept in pending queue
+	if b.stopping.Load() {
+		return
+	}
+	priority := 0
+	if neu, ok := b.neurons[neuronID]; ok {
+		priority = neu.priority()
+	}
+	b.deferred.Add(neuronID, priority)
+}
+
+// flushActivations pushes the activations published while topoMu was held, topoMu must not be held
+func (b *BrainLocal) flushActivations() {
+	runner := b.runner()
+	b.deferred.Flush(func(neuronID string, priority int) bool {
+		// brain is shutting down, the activation is kept in pending queue
+		if b.stopping.Load() || runner.nQueue == nil {
+			return false
+		}
+		runner.nQueue.Push(activation{b: b, neuronID: neuronID}, priority, runner.stop)
+		return true
+	})
 }
 
-func (b *BrainLocal) runNeuronWorker() {
-	for neuronID := range b.nQueue {
-		neu, ok := b.neurons[neuronID]
+func (b *BrainLocal) runNeuronWorker(nQueue *queue.Priority[activation], stop chan struct{}) {
+	for {
+		act, ok := nQueue.Pop(stop)
+		if !ok {
+			return
+		}
+		// brain or thread is shutting down, the activation is kept in pending queue
+		if act.b.stopping.Load() {
+			continue
+		}
+		act.b.pending.DoneActivation(act.neuronID)
+		act.b.topoMu.RLock()
+		neu, ok := act.b.neurons[act.neuronID]
+		act.b.topoMu.RUnlock()
 		if !ok {
-			b.logger.Error().Str("neuronID", neuronID).Msg("neuron not found")
+			act.b.logger.Error().Str("neuronID", act.neuronID).Msg("neuron not found")
 			continue
 		}
 
-		err := b.activateNeuron(neu)
+		act.b.startProcessing()
+		err := act.b.activateNeuron(neu)
+		act.b.doneProcessing()
 		if err != nil {
-			b.logger.Error().Err(err).Str("neuronID", neuronID).Msg("activate neuron error")
+			act.b.logger.Error().Err(err).Str("neuronID", act.neuronID).Msg("activate neuron error")
 		}
 	}
 }
 
+// runner returns the brain whose workers process neurons of b, threads share workers of their brain
+func (b *BrainLocal) runner() *BrainLocal {
+	if b.root != nil {
+		return b.root
+	}
+
+	return b
+}
+
 func (b *BrainLocal) activateNeuron(neu *neuron) error {
 	if neu == nil {
 		return errors.ErrNeuronNotFound("nil")
 	}
 
 	b.logger.Debug().Interface("neuronID", neu.id).Msg("start activate neuron")
+	// the states are read by the maintainer step holding the read lock, so they are changed with the write lock
+	b.topoMu.Lock()
 	neu.status.state = core.NeuronStateActivated
 	// in-link set init
 	for _, links := range neu.spec.triggerGroups {
@@ -51,21 +108,37 @@ func (b *BrainLocal) activateNeuron(neu *neuron) error {
 			l.status.state = core.LinkStateWait
 		}
 	}
-
 	neu.status.count.process++
+	b.topoMu.Unlock()
+
 	// block process
-	err := neu.spec.processor.Process(&brainContext{
-		b:               b,
-		currentNeuronID: neu.id,
-	})
+	err := neu.spec.processor.Process(b.newBrainContext(neu.id))
+	interrupted := err == nil && neu.hasLabel(core.NeuronLabelInterruptAfter)
+	b.topoMu.Lock()
 	neu.status.state = core.NeuronStateInactive
 	if err != nil {
 		neu.status.count.failed++
+	} else {
+		// SucceedCount++
+		neu.status.count.succeed++
+	}
+	if interrupted {
+		neu.status.state = core.NeuronStateInterruptedAfter
+	}
+	b.topoMu.Unlock()
+	if err != nil {
 		return fmt.Errorf("process neuron error: %w", err)
 	}
 
-	// SucceedCount++
-	neu.status.count.succeed++
+	// park the run before neuron cast
+	if interrupted {
+		b.publishEvent(maintainEvent{
+			kind:   eventKindNeuron,
+			action: eventActionNeuronInterrupt,
+			id:     neu.id,
+		})
+		return nil
+	}
 
 	// cast
 	b.publishEvent(maintainEvent{
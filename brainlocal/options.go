@@ -1,6 +1,11 @@
 package brainlocal
 
 import (
+	"time"
+
+	"github.com/Rovanta/rmodel/artifact"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/memstore"
 	"github.com/rs/zerolog"
 )
 
@@ -30,14 +35,58 @@ func WithNeuronQueueLen(nQueueLen int) Option {
 	})
 }
 
-// WithMemorySetting sets the memory setting
-func WithMemorySetting(memoryNumCounters, memoryMaxCost int64) Option {
+// WithPriorityAging raises the priority of a queued neuron activation by one for every interval it waits,
+// so the neurons with low priority are not starved. Aging is disabled if interval <= 0, by default.
+func WithPriorityAging(interval time.Duration) Option {
+	return optionFunc(func(brain *BrainLocal) {
+		brain.nAging = interval
+	})
+}
+
+// WithMemoryStore sets the func creating the memory store, it is called when memory is initialized,
+// after the brain is built or restarted after shutdown. The store is shared by the threads of brain,
+// and closed when brain is shut down. The default store is memstore.Map, which never evicts memories.
+func WithMemoryStore(newStore func() (core.MemoryStore, error)) Option {
+	return optionFunc(func(brain *BrainLocal) {
+		brain.BrainMemory.newStore = newStore
+	})
+}
+
+// WithMemorySweepInterval sets the interval the expired memories are deleted and reported to the watchers,
+// an expired memory is not read even before it is deleted. The default interval is 10 seconds.
+func WithMemorySweepInterval(interval time.Duration) Option {
+	return optionFunc(func(brain *BrainLocal) {
+		if interval > 0 {
+			brain.BrainMemory.sweepInterval = interval
+		}
+	})
+}
+
+// WithArtifactDir sets the directory of the content-addressed artifact store of PutArtifact,
+// the default is rmodel-artifacts in the temporary directory of the system
+func WithArtifactDir(dir string) Option {
 	return optionFunc(func(brain *BrainLocal) {
-		brain.numCounters = memoryNumCounters
-		brain.maxCost = memoryMaxCost
+		brain.BrainMemory.artifacts = artifact.NewStore(dir)
 	})
 }
 
+// WithMemoryCache keeps memories in a memstore.Cache instead of the default map, which never evicts memories.
+// The cost of a memory is the estimated size of its value in bytes, and memories are evicted when the total cost
+// exceeds maxCost. numCounters is the number of keys to track frequency of, 10 times of the expected memories.
+// An evicted memory is sent to the watchers of WatchMemory as a change with Evicted set.
+func WithMemoryCache(numCounters, maxCost int64) Option {
+	return WithMemoryStore(func() (core.MemoryStore, error) {
+		return memstore.NewCache(numCounters, maxCost)
+	})
+}
+
+// WithMemorySetting sets the memory setting
+//
+// Deprecated: use WithMemoryCache, memories are kept in a cache only if it is enabled
+func WithMemorySetting(memoryNumCounters, memoryMaxCost int64) Option {
+	return WithMemoryCache(memoryNumCounters, memoryMaxCost)
+}
+
 // WithLoggerLevel sets the default logger with specific level
 func WithLoggerLevel(level zerolog.Level) Option {
 	return optionFunc(func(brain *BrainLocal) {
@@ -58,3 +107,12 @@ func WithID(brainID string) Option {
 		brain.id = brainID
 	})
 }
+
+// WithSnapshots enables snapshots of memory and graph state after every maintainer step,
+// the latest limit snapshots are kept, all snapshots are kept if limit <= 0
+func WithSnapshots(limit int) Option {
+	return optionFunc(func(brain *BrainLocal) {
+		brain.snapshots.enabled = true
+		brain.snapshots.limit = limit
+	})
+}
@@ -0,0 +1,214 @@
+package brainlocal
+
+import (
+	"fmt"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/internal/patch"
+	"github.com/Rovanta/rmodel/internal/utils"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// Apply applies patch to the topology of brain. When brain is running, the patch is applied between two
+// maintainer steps. The patch is validated before applied, the activated, queued or interrupted neurons can not be removed.
+// A topology changed event is emitted after the patch is applied, the neurons with ready in-links are tried to activate again.
+// The patch is applied to all threads of brain too, it is applied to none of them if it is invalid for brain or any thread.
+func (b *BrainLocal) Apply(patch core.Patch) error {
+	if patch == nil {
+		return fmt.Errorf("patch is nil")
+	}
+
+	// no thread is created until the patch is applied to all, threadsMu is taken before topoMu as getOrNewThread does
+	b.threadsMu.Lock()
+	brains := []*BrainLocal{b}
+	for _, t := range b.threads {
+		brains = append(brains, t)
+	}
+	for _, br := range brains {
+		br.topoMu.Lock()
+	}
+	var err error
+	for _, br := range brains {
+		if err = br.validatePatch(patch); err != nil {
+			if br != b {
+				err = errors.Wrapf(err, "thread %s", br.thread)
+			}
+			break
+		}
+	}
+	if err == nil {
+		for _, br := range brains {
+			br.applyPatch(patch)
+		}
+	}
+	for _, br := range brains {
+		br.topoMu.Unlock()
+	}
+	b.threadsMu.Unlock()
+	if err != nil {
+		return errors.Wrapf(err, "invalid patch")
+	}
+
+	patchID := utils.GenIDShort()
+	b.logger.Info().
+		Str("patchID", patchID).
+		Int("addNeurons", len(patch.ListNeurons())).
+		Int("addLinks", len(patch.ListLinks())).
+		Int("removeNeurons", len(patch.ListRemovedNeurons())).
+		Int("removeLinks", len(patch.ListRemovedLinks())).
+		Int("groupChanges", len(patch.ListGroupChanges())).
+		Int("threads", len(brains)-1).
+		Msg("topology patch applied")
+	for _, br := range brains {
+		br.publishEvent(maintainEvent{
+			kind:   eventKindBrain,
+			action: eventActionBrainTopologyChanged,
+			id:     patchID,
+		})
+	}
+
+	return nil
+}
+
+// validatePatch checks p against the topology of brain, topoMu must be held
+func (b *BrainLocal) validatePatch(p core.Patch) error {
+	_, activations := b.pending.Copy()
+
+	return patch.Validate(b.topology(), p, activations)
+}
+
+// applyPatch applies a validated patch, topoMu must be held
+func (b *BrainLocal) applyPatch(patch core.Patch) {
+	for _, id := range patch.ListRemovedNeurons() {
+		delete(b.neurons, id)
+	}
+	for id, l := range b.links {
+		_, srcOK := b.neurons[l.spec.from]
+		_, destOK := b.neurons[l.spec.to]
+		if !srcOK && !l.isEntryLink() || !destOK {
+			b.removeLink(id)
+		}
+	}
+	for _, id := range patch.ListRemovedLinks() {
+		b.removeLink(id)
+	}
+
+	for _, l := range patch.ListLinks() {
+		lk := newLink(l)
+		b.links[lk.id] = lk
+	}
+	for _, n := range patch.ListNeurons() {
+		neu := newNeuron(n, b.links)
+		b.neurons[neu.id] = neu
+	}
+	if _, ok := b.neurons[core.EndNeuronID]; !ok {
+		for _, l := range patch.ListLinks() {
+			if l.GetDestNeuronID() == core.EndNeuronID {
+				b.neurons[core.EndNeuronID] = newEndNeuron()
+				break
+			}
+		}
+	}
+	// links between neurons of brain and new neurons, the new neurons have their groups already
+	newNeurons := make(map[string]bool)
+	for _, n := range patch.ListNeurons() {
+		newNeurons[n.GetID()] = true
+	}
+	for _, l := range patch.ListLinks() {
+		lk := b.links[l.GetID()]
+		if src, ok := b.neurons[lk.spec.from]; ok && !newNeurons[src.id] {
+			src.spec.castGroups[processor.DefaultCastGroupName] = append(src.spec.castGroups[processor.DefaultCastGroupName], lk)
+			// the processing neuron casts to its new out-links too
+			if src.status.state == core.NeuronStateActivated {
+				lk.status.state = core.LinkStateWait
+			}
+		}
+		if dest, ok := b.neurons[lk.spec.to]; ok && !newNeurons[dest.id] {
+			dest.spec.triggerGroups[utils.GenIDShort()] = []*link{lk}
+		}
+	}
+
+	for _, c := range patch.ListGroupChanges() {
+		n := b.neurons[c.NeuronID]
+		group := make([]*link, 0, len(c.LinkIDs))
+		for _, id := range c.LinkIDs {
+			group = append(group, b.links[id])
+		}
+		switch c.Kind {
+		case core.GroupChangeAddTrigger:
+			n.triggers().AddTrigger(group)
+		case core.GroupChangeRemoveTrigger:
+			n.triggers().RemoveTrigger(group)
+		case core.GroupChangeAddCast:
+			n.casts().AddCast(c.GroupName, group)
+		case core.GroupChangeRemoveCast:
+			n.casts().RemoveCast(c.GroupName)
+		}
+	}
+}
+
+// removeLink removes link from brain and from groups of its neurons
+func (b *BrainLocal) removeLink(linkID string) {
+	l, ok := b.links[linkID]
+	if !ok {
+		return
+	}
+	delete(b.links, linkID)
+
+	if src, ok := b.neurons[l.spec.from]; ok {
+		src.casts().RemoveCastLink(linkID)
+	}
+	if dest, ok := b.neurons[l.spec.to]; ok {
+		dest.triggers().RemoveTriggerLink(linkID)
+	}
+}
+
+// tryActivateAll tries to activate the neurons with ready in-links after topology changed
+func (b *BrainLocal) tryActivateAll() {
+	for _, n := range b.neurons {
+		for _, links := range n.spec.triggerGroups {
+			if hasReadyLink(links) {
+				b.publishEvent(maintainEvent{
+					kind:   eventKindNeuron,
+					action: eventActionNeuronTryActivate,
+					id:     n.id,
+				})
+				break
+			}
+		}
+	}
+}
+
+// triggers returns the trigger groups of neuron to change them
+func (n *neuron) triggers() patch.Groups[*link] {
+	return patch.Groups[*link]{Groups: n.spec.triggerGroups, ID: linkID}
+}
+
+// casts returns the cast groups of neuron to change them
+func (n *neuron) casts() patch.Groups[*link] {
+	return patch.Groups[*link]{Groups: n.spec.castGroups, ID: linkID}
+}
+
+func linkID(l *link) string {
+	return l.id
+}
+
+func linkIDs(links []*link) []string {
+	ids := make([]string, 0, len(links))
+	for _, l := range links {
+		ids = append(ids, l.id)
+	}
+
+	return ids
+}
+
+func hasReadyLink(links []*link) bool {
+	for _, l := range links {
+		if l.status.state == core.LinkStateReady {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,251 @@
+package brainlocal
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Rovanta/rmodel/codec"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// snapshot is a core.Snapshot with the pending queue and memory of brain
+type snapshot struct {
+	core.Snapshot
+	events      map[maintainEvent]int
+	activations map[string]int
+	// memory values are deep copies, see codec.Registry.Copy
+	memory map[any]any
+}
+
+type snapshots struct {
+	mu      sync.Mutex
+	enabled bool
+	// keep the latest limit snapshots, keep all if limit <= 0
+	limit int
+	seq   int
+	list  []*snapshot
+	// err is the last failure of taking a snapshot, the step has no snapshot
+	err error
+}
+
+func (s *snapshots) add(snap *snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	snap.ID = s.seq
+	s.list = append(s.list, snap)
+	if s.limit > 0 && len(s.list) > s.limit {
+		s.list = s.list[len(s.list)-s.limit:]
+	}
+}
+
+func (s *snapshots) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *snapshots) get(id int) (*snapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, snap := range s.list {
+		if snap.ID == id {
+			return snap, true
+		}
+	}
+
+	return nil, false
+}
+
+// ListSnapshots lists snapshots of brain in the order they are taken, snapshots are enabled by WithSnapshots.
+// It returns an error if snapshots are not enabled, or a snapshot failed to be taken
+func (b *BrainLocal) ListSnapshots() ([]core.Snapshot, error) {
+	b.snapshots.mu.Lock()
+	defer b.snapshots.mu.Unlock()
+	if !b.snapshots.enabled {
+		return nil, fmt.Errorf("snapshots of brain %s are not enabled", b.id)
+	}
+	if b.snapshots.err != nil {
+		return nil, b.snapshots.err
+	}
+	ret := make([]core.Snapshot, 0, len(b.snapshots.list))
+	for _, snap := range b.snapshots.list {
+		ret = append(ret, snap.Snapshot)
+	}
+
+	return ret, nil
+}
+
+// Fork builds a new brain with a new ID, and restores the memory and graph state of the snapshot into it.
+// The forked brain inherits settings of the brain, withOpts override them.
+// The memory store is not inherited, the forked brain keeps its memories in a memstore.Map of its own,
+// unless a store is set by withOpts.
+// Memory of forked brain can be edited before the run is continued by Continue.
+func (b *BrainLocal) Fork(snapshotID int, withOpts ...Option) (*BrainLocal, error) {
+	snap, ok := b.snapshots.get(snapshotID)
+	if !ok {
+		return nil, fmt.Errorf("snapshot %d of brain %s not found", snapshotID, b.id)
+	}
+
+	nb := newBrain(b.labels)
+	b.topoMu.RLock()
+	for id, l := range b.links {
+		nb.links[id] = l.clone()
+	}
+	for id, n := range b.neurons {
+		nb.neurons[id] = n.clone(nb.links)
+	}
+	b.topoMu.RUnlock()
+	nb.init(append([]Option{b.inheritedOption()}, withOpts...)...)
+
+	if err := nb.restoreSnapshot(snap); err != nil {
+		return nil, err
+	}
+	nb.logger.Info().
+		Str("forkedFrom", b.id).
+		Int("snapshotID", snapshotID).
+		Msg("brain forked from snapshot")
+
+	return nb, nil
+}
+
+// Continue continues the run restored by Fork, the activated neurons and pending queue entries
+// of the snapshot are queued again.
+func (b *BrainLocal) Continue() error {
+	snap := b.restored
+	if snap == nil {
+		return fmt.Errorf("brain %s is not restored from a snapshot", b.id)
+	}
+	b.restored = nil
+
+	if err := b.ensureMemoryInit(); err != nil {
+		return err
+	}
+	b.ensureMaintainerStart()
+	b.topoMu.RLock()
+	for _, n := range b.neurons {
+		if n.status.state == core.NeuronStateActivated {
+			b.publishEventActivateNeuron(n.id)
+		}
+	}
+	for id, cnt := range snap.activations {
+		if b.neurons[id].status.state == core.NeuronStateActivated {
+			continue
+		}
+		for i := 0; i < cnt; i++ {
+			b.publishEventActivateNeuron(id)
+		}
+	}
+	// the maintainer may put brain to sleep once the state is refreshed, the states are not read after it
+	b.refreshState()
+	b.topoMu.RUnlock()
+
+	// the queues may be full, they are pushed without topoMu
+	b.flushActivations()
+	for e, cnt := range snap.events {
+		for i := 0; i < cnt; i++ {
+			b.publishEvent(e)
+		}
+	}
+
+	return nil
+}
+
+func (b *BrainLocal) restoreSnapshot(snap *snapshot) error {
+	for id, state := range snap.Links {
+		l, ok := b.links[id]
+		if !ok {
+			return errors.Wrapf(errors.ErrLinkNotFound(id), "snapshot does not match brain")
+		}
+		l.status.state = state
+	}
+	for id, state := range snap.Neurons {
+		n, ok := b.neurons[id]
+		if !ok {
+			return errors.Wrapf(errors.ErrNeuronNotFound(id), "snapshot does not match brain")
+		}
+		n.status.state = state
+	}
+
+	keysAndValues := make([]any, 0, 2*len(snap.memory))
+	// the snapshot can be forked again, the forked brain writes into copies of its values
+	for k, v := range snap.memory {
+		cp, err := codec.Default.Copy(v)
+		if err != nil {
+			return errors.Wrapf(err, "restore memory failed")
+		}
+		keysAndValues = append(keysAndValues, k, cp)
+	}
+	// the memories are restored as they are, without the reducers of keys
+	err := b.updateMemory("", func(tx processor.MemoryTx) error {
+		for i := 0; i < len(keysAndValues); i += 2 {
+			tx.(*memoryTx).put(keysAndValues[i], keysAndValues[i+1], 0)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "restore memory failed")
+	}
+	b.restored = snap
+
+	return nil
+}
+
+// takeSnapshot takes a snapshot after maintainer step, if snapshots are enabled
+func (b *BrainLocal) takeSnapshot(step maintainEvent) {
+	if !b.snapshots.enabled {
+		return
+	}
+
+	snap := &snapshot{
+		Snapshot: core.Snapshot{
+			BrainID:   b.id,
+			Step:      fmt.Sprintf("%s %s %s", step.kind, step.action, step.id),
+			CreatedAt: time.Now(),
+			Links:     make(map[string]core.LinkState, len(b.links)),
+			Neurons:   make(map[string]core.NeuronState, len(b.neurons)),
+		},
+		memory: make(map[any]any),
+	}
+	for id, l := range b.links {
+		snap.Links[id] = l.status.state
+	}
+	for id, n := range b.neurons {
+		snap.Neurons[id] = n.status.state
+	}
+	snap.events, snap.activations = b.pending.Copy()
+	for _, k := range b.BrainMemory.listKeys() {
+		v, ok := b.getMemory(k)
+		if !ok {
+			continue
+		}
+		cp, err := codec.Default.Copy(v)
+		if err != nil {
+			b.logger.Error().Err(err).Any("key", k).Msg("take snapshot failed")
+			b.snapshots.fail(fmt.Errorf("snapshot after step %s: copy memory %v failed: %v", snap.Step, k, err))
+			return
+		}
+		snap.memory[k] = cp
+	}
+
+	b.snapshots.add(snap)
+}
+
+// inheritedOption copies settings of brain to a forked brain
+func (b *BrainLocal) inheritedOption() Option {
+	return optionFunc(func(brain *BrainLocal) {
+		brain.nWorkerNum = b.nWorkerNum
+		brain.nQueueLen = b.nQueueLen
+		brain.nAging = b.nAging
+		brain.BrainMemory.reducers = b.BrainMemory.reducers
+		// the store is not inherited, a store shared with brain would be written by the fork
+		brain.BrainMemory.artifacts = b.BrainMemory.artifacts
+		brain.BrainMemory.sweepInterval = b.BrainMemory.sweepInterval
+		brain.snapshots.enabled = b.snapshots.enabled
+		brain.snapshots.limit = b.snapshots.limit
+		brain.logger = brain.logger.Level(b.logger.GetLevel())
+	})
+}
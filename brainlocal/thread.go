@@ -0,0 +1,256 @@
+package brainlocal
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Rovanta/rmodel/core"
+)
+
+// Run runs the thread threadID of brain with input, and blocks until the thread is sleeping or interrupted, or ctx is done.
+// The thread is created at its first run, it has its own memory namespace and its own link and neuron states,
+// its neurons are processed by the shared neuron workers of brain. Memory of a thread is kept between its runs,
+// until the thread is shut down. When ctx is done, the run is not stopped, Wait of the thread can be used to wait it.
+func (b *BrainLocal) Run(ctx context.Context, threadID string, input map[string]any) (*BrainLocal, error) {
+	if b.root != nil {
+		return b.root.Run(ctx, threadID, input)
+	}
+
+	t, err := b.getOrNewThread(threadID)
+	if err != nil {
+		return nil, err
+	}
+	if state := t.getState(); state == core.BrainStateRunning || state == core.BrainStateInterrupted {
+		return t, fmt.Errorf("thread %s of brain %s is %s", threadID, b.id, state)
+	}
+
+	keysAndValues := make([]any, 0, 2*len(input))
+	for k, v := range input {
+		keysAndValues = append(keysAndValues, k, v)
+	}
+	if err = t.EntryWithMemory(keysAndValues...); err != nil {
+		return t, err
+	}
+
+	return t, t.WaitContext(ctx)
+}
+
+// Thread returns the thread threadID of brain, nil if the thread is not found
+func (b *BrainLocal) Thread(threadID string) *BrainLocal {
+	b.threadsMu.Lock()
+	defer b.threadsMu.Unlock()
+
+	return b.threads[threadID]
+}
+
+// ListThreads lists IDs of the threads of brain
+func (b *BrainLocal) ListThreads() []string {
+	b.threadsMu.Lock()
+	defer b.threadsMu.Unlock()
+	ids := make([]string, 0, len(b.threads))
+	for id := range b.threads {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// GetThreadID returns the thread ID, empty if b is not a thread
+func (b *BrainLocal) GetThreadID() string {
+	return b.thread
+}
+
+func (b *BrainLocal) getOrNewThread(threadID string) (*BrainLocal, error) {
+	if threadID == "" {
+		return nil, fmt.Errorf("thread ID is empty")
+	}
+
+	b.threadsMu.Lock()
+	defer b.threadsMu.Unlock()
+	if t, ok := b.threads[threadID]; ok {
+		return t, nil
+	}
+	// the memory store of brain is shared by threads
+	if err := b.ensureMemoryInit(); err != nil {
+		return nil, err
+	}
+
+	t := newBrain(b.labels)
+	t.id = b.id
+	t.thread = threadID
+	t.root = b
+	b.topoMu.RLock()
+	for id, l := range b.links {
+		t.links[id] = l.clone()
+	}
+	for id, n := range b.neurons {
+		t.neurons[id] = n.clone(t.links)
+	}
+	b.topoMu.RUnlock()
+	t.nWorkerNum = b.nWorkerNum
+	t.nQueueLen = b.nQueueLen
+	t.nAging = b.nAging
+	t.BrainMemory.sweepInterval = b.BrainMemory.sweepInterval
+	t.BrainMemory.reducers = b.BrainMemory.reducers
+	t.BrainMemory.newStore = b.BrainMemory.newStore
+	t.BrainMemory.artifacts = b.BrainMemory.artifacts
+	t.snapshots.enabled = b.snapshots.enabled
+	t.snapshots.limit = b.snapshots.limit
+	t.BrainMemory.store = b.BrainMemory.store
+	if err := t.loadKeys(t.BrainMemory.store); err != nil {
+		return nil, err
+	}
+	t.logger = b.logger.With().Str("threadID", threadID).Logger()
+
+	if b.threads == nil {
+		b.threads = make(map[string]*BrainLocal)
+	}
+	b.threads[threadID] = t
+	t.logger.Info().Msg("thread created")
+
+	return t, nil
+}
+
+const (
+	// threadKeyPrefix starts the keys of thread memories in the shared store
+	threadKeyPrefix = "thread:"
+	// rootKeyPrefix escapes the string keys of brain memories which start with a prefix of the namespaces,
+	// so the keys of brain never look like the keys of threads
+	rootKeyPrefix = "root:"
+)
+
+// memKey maps key of memory into the store shared by brain and its threads. Keys of a thread are strings in
+// the thread namespace, the length of thread ID is in it so the IDs containing the separator can not overlap
+func (b *BrainLocal) memKey(key any) any {
+	if b.root != nil {
+		return fmt.Sprintf("%s%d:%s:%T:%v", threadKeyPrefix, len(b.thread), b.thread, key, key)
+	}
+
+	v := reflect.ValueOf(key)
+	if v.Kind() != reflect.String {
+		return key
+	}
+	if s := v.String(); strings.HasPrefix(s, threadKeyPrefix) || strings.HasPrefix(s, rootKeyPrefix) {
+		// the type of key is kept, a key of a named string type is not the same as a string key
+		return reflect.ValueOf(rootKeyPrefix + s).Convert(v.Type()).Interface()
+	}
+
+	return key
+}
+
+// rootKey is the key of brain memory kept at storeKey, ok is false if storeKey is in the namespace of a thread
+func rootKey(storeKey any) (any, bool) {
+	v := reflect.ValueOf(storeKey)
+	if v.Kind() != reflect.String {
+		return storeKey, true
+	}
+	s := v.String()
+	if strings.HasPrefix(s, threadKeyPrefix) {
+		return nil, false
+	}
+	if strings.HasPrefix(s, rootKeyPrefix) {
+		return reflect.ValueOf(strings.TrimPrefix(s, rootKeyPrefix)).Convert(v.Type()).Interface(), true
+	}
+
+	return storeKey, true
+}
+
+// keyOf is the key of memory of brain or the thread kept at storeKey, ok is false if it belongs to the others.
+// Keys of a thread are kept as their types and texts, only the keys of strings, ints, floats and bools are parsed
+func (b *BrainLocal) keyOf(storeKey any) (any, bool) {
+	if b.root == nil {
+		return rootKey(storeKey)
+	}
+	s, ok := storeKey.(string)
+	prefix := fmt.Sprintf("%s%d:%s:", threadKeyPrefix, len(b.thread), b.thread)
+	if !ok || !strings.HasPrefix(s, prefix) {
+		return nil, false
+	}
+
+	typeName, text, _ := strings.Cut(strings.TrimPrefix(s, prefix), ":")
+	var key any
+	var err error
+	switch typeName {
+	case "string":
+		key = text
+	case "int":
+		key, err = strconv.Atoi(text)
+	case "float64":
+		key, err = strconv.ParseFloat(text, 64)
+	case "bool":
+		key, err = strconv.ParseBool(text)
+	default:
+		return nil, false
+	}
+
+	return key, err == nil
+}
+
+// ownsStoreKey reports whether the memory at storeKey of the shared store belongs to brain or the thread
+func (b *BrainLocal) ownsStoreKey(storeKey any) bool {
+	if b.root == nil {
+		_, ok := rootKey(storeKey)
+		return ok
+	}
+	s, ok := storeKey.(string)
+
+	return ok && strings.HasPrefix(s, fmt.Sprintf("%s%d:%s:", threadKeyPrefix, len(b.thread), b.thread))
+}
+
+// runID returns ID of the run of brain, it is the thread ID for threads
+func (b *BrainLocal) runID() string {
+	if b.root != nil {
+		return b.thread
+	}
+
+	return b.id
+}
+
+// shutdownThread stops maintainer of thread, deletes its memory and removes it from brain
+func (b *BrainLocal) shutdownThread(ctx context.Context) error {
+	b.lifecycleMu.Lock()
+	defer b.lifecycleMu.Unlock()
+
+	var err error
+	if b.getState() != core.BrainStateShutdown {
+		b.logger.Info().Msg("thread shutdown")
+		err = b.stopMaintainer(ctx)
+	}
+	b.BrainMemory.sweeper.Stop()
+	b.ClearMemory()
+
+	b.root.threadsMu.Lock()
+	if b.root.threads[b.thread] == b {
+		delete(b.root.threads, b.thread)
+	}
+	b.root.threadsMu.Unlock()
+
+	return err
+}
+
+func (b *BrainLocal) shutdownThreads(ctx context.Context) error {
+	var err error
+	for _, t := range b.listThreads() {
+		if tErr := t.Shutdown(ctx); tErr != nil {
+			err = tErr
+		}
+	}
+
+	return err
+}
+
+func (b *BrainLocal) listThreads() []*BrainLocal {
+	b.threadsMu.Lock()
+	defer b.threadsMu.Unlock()
+	threads := make([]*BrainLocal, 0, len(b.threads))
+	for _, t := range b.threads {
+		threads = append(threads, t)
+	}
+
+	return threads
+}
@@ -0,0 +1,67 @@
+package brainpool
+
+import (
+	"time"
+
+	"github.com/Rovanta/rmodel/core"
+)
+
+// Option configures a BrainPool in build.
+type Option interface {
+	apply(pool *BrainPool)
+}
+
+// optionFunc wraps a func, so it satisfies the Option interface.
+type optionFunc func(*BrainPool)
+
+func (f optionFunc) apply(pool *BrainPool) {
+	f(pool)
+}
+
+// WithMaxSize sets the maximum number of brains built by the pool, in use or idle.
+// Get blocks when the limit is reached, no limit if maxSize <= 0
+func WithMaxSize(maxSize int) Option {
+	return optionFunc(func(pool *BrainPool) {
+		pool.maxSize = maxSize
+	})
+}
+
+// WithMinIdle sets the number of idle brains which are pre-built and kept by the pool
+func WithMinIdle(minIdle int) Option {
+	return optionFunc(func(pool *BrainPool) {
+		pool.minIdle = minIdle
+	})
+}
+
+// WithMaxIdle sets the maximum number of idle brains, the returned brains over the limit are shut down,
+// no limit if maxIdle <= 0
+func WithMaxIdle(maxIdle int) Option {
+	return optionFunc(func(pool *BrainPool) {
+		pool.maxIdle = maxIdle
+	})
+}
+
+// WithIdleTimeout sets how long a brain can be idle before it is evicted, idle brains are not evicted if timeout <= 0
+func WithIdleTimeout(timeout time.Duration) Option {
+	return optionFunc(func(pool *BrainPool) {
+		pool.idleTimeout = timeout
+	})
+}
+
+// WithResetTimeout sets how long Put waits for a returned brain which is still running, 10 seconds by default.
+// The brain still running after timeout is shut down and dropped as unhealthy
+func WithResetTimeout(timeout time.Duration) Option {
+	return optionFunc(func(pool *BrainPool) {
+		if timeout > 0 {
+			pool.resetTimeout = timeout
+		}
+	})
+}
+
+// WithHealthCheck sets the check of brains when they are got from and returned to the pool,
+// the unhealthy brains are shut down and dropped
+func WithHealthCheck(check func(brain core.Brain) error) Option {
+	return optionFunc(func(pool *BrainPool) {
+		pool.healthCheck = check
+	})
+}
@@ -0,0 +1,370 @@
+package brainpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Rovanta/rmodel/core"
+)
+
+// ErrPoolClosed is returned by Get after the pool is closed
+var ErrPoolClosed = errors.New("brain pool is closed")
+
+// defaultResetTimeout is how long Put waits for a running brain by default
+const defaultResetTimeout = 10 * time.Second
+
+// BuildFunc builds a brain from blueprint, e.g. brainlocal.BuildBrain with options
+type BuildFunc func(blueprint core.Blueprint) core.Brain
+
+// BrainPool pre-builds brains from one blueprint, and hands out a clean brain for every Get.
+// The returned brains are reset by ForceSleep and ClearMemory, then reused.
+type BrainPool struct {
+	blueprint core.Blueprint
+	build     BuildFunc
+
+	maxSize     int
+	minIdle     int
+	maxIdle     int
+	idleTimeout time.Duration
+	// resetTimeout is how long Put waits for a running brain, the brain still running is shut down and dropped
+	resetTimeout time.Duration
+	healthCheck  func(brain core.Brain) error
+
+	mu    sync.Mutex
+	idle  []idleBrain
+	inUse map[core.Brain]struct{}
+	// building is the number of brains being built, they are counted in max size before they are built
+	building int
+	// returning is the number of brains being reset by Put, they are counted in max size until they are idle or dropped
+	returning int
+	// released is closed and renewed when a brain is returned or dropped, it wakes up the waiting Get
+	released chan struct{}
+	closed   bool
+	stop     chan struct{}
+	stats    Stats
+}
+
+type idleBrain struct {
+	brain core.Brain
+	since time.Time
+}
+
+// Stats is the counters of a BrainPool
+type Stats struct {
+	// Idle is the number of idle brains
+	Idle int
+	// InUse is the number of brains got but not returned
+	InUse int
+	// Built is the number of brains built by the pool
+	Built int
+	// Evicted is the number of idle brains shut down by idle timeout or max idle
+	Evicted int
+	// Unhealthy is the number of brains dropped by health check
+	Unhealthy int
+}
+
+// NewBrainPool creates a pool of brains built from blueprint by build, and pre-builds the min idle brains.
+func NewBrainPool(blueprint core.Blueprint, build BuildFunc, withOpts ...Option) *BrainPool {
+	p := &BrainPool{
+		blueprint:    blueprint,
+		build:        build,
+		resetTimeout: defaultResetTimeout,
+		healthCheck:  DefaultHealthCheck,
+		inUse:        make(map[core.Brain]struct{}),
+		released:     make(chan struct{}),
+		stop:         make(chan struct{}),
+	}
+	for _, opt := range withOpts {
+		opt.apply(p)
+	}
+	if p.maxSize > 0 && p.minIdle > p.maxSize {
+		p.minIdle = p.maxSize
+	}
+
+	p.fill()
+	if p.idleTimeout > 0 {
+		go p.runEvictor()
+	}
+
+	return p
+}
+
+// DefaultHealthCheck checks that brain is not running or interrupted
+func DefaultHealthCheck(brain core.Brain) error {
+	switch state := brain.GetState(); state {
+	case core.BrainStateSleeping, core.BrainStateShutdown:
+		return nil
+	default:
+		return fmt.Errorf("brain is %s", state)
+	}
+}
+
+// Get gets an idle brain from the pool, or builds a new one.
+// It blocks when the max size of pool is reached, until a brain is returned or ctx is done.
+func (p *BrainPool) Get(ctx context.Context) (core.Brain, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+
+		// the latest returned brain first, so the others stay idle and can be evicted
+		if n := len(p.idle); n > 0 {
+			brain := p.idle[n-1].brain
+			p.idle = p.idle[:n-1]
+			p.inUse[brain] = struct{}{}
+			p.mu.Unlock()
+
+			if err := p.healthCheck(brain); err != nil {
+				p.drop(brain, true, false)
+				continue
+			}
+			return brain, nil
+		}
+
+		if !p.isFull() {
+			// the slot is reserved before the lock is released
+			p.building++
+			p.mu.Unlock()
+			brain, err := p.buildBrain()
+			if err != nil {
+				return nil, err
+			}
+
+			p.mu.Lock()
+			p.building--
+			p.stats.Built++
+			p.inUse[brain] = struct{}{}
+			p.mu.Unlock()
+			return brain, nil
+		}
+
+		released := p.released
+		p.mu.Unlock()
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Put returns a brain got from the pool. It waits until the brain is not running, so no processor of the brain
+// is running when it is reset by ForceSleep and ClearMemory. A brain still running after the reset timeout,
+// see WithResetTimeout, is shut down and dropped as unhealthy.
+func (p *BrainPool) Put(brain core.Brain) error {
+	// the brain is taken out of use at once, so it is returned once
+	p.mu.Lock()
+	if _, ok := p.inUse[brain]; !ok {
+		p.mu.Unlock()
+		return fmt.Errorf("brain is not got from the pool")
+	}
+	delete(p.inUse, brain)
+	p.returning++
+	p.mu.Unlock()
+
+	if err := p.reset(brain); err != nil {
+		p.drop(brain, true, true)
+		return nil
+	}
+	if err := p.healthCheck(brain); err != nil {
+		p.drop(brain, true, true)
+		return nil
+	}
+
+	p.mu.Lock()
+	if p.closed || (p.maxIdle > 0 && len(p.idle) >= p.maxIdle) {
+		p.mu.Unlock()
+		p.drop(brain, false, true)
+		return nil
+	}
+	p.returning--
+	p.idle = append(p.idle, idleBrain{brain: brain, since: time.Now()})
+	p.notify()
+	p.mu.Unlock()
+
+	return nil
+}
+
+// Stats returns the counters of pool
+func (p *BrainPool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.Idle = len(p.idle)
+	stats.InUse = len(p.inUse) + p.returning
+
+	return stats
+}
+
+// Close shuts down all idle brains and stops the pool, brains in use are shut down when they are returned.
+func (p *BrainPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	close(p.stop)
+	p.notify()
+	p.mu.Unlock()
+
+	for _, ib := range idle {
+		p.shutdown(ib.brain)
+	}
+}
+
+// drop shuts down a brain in use and removes it from pool, returning is set for a brain being returned by Put
+func (p *BrainPool) drop(brain core.Brain, unhealthy, returning bool) {
+	p.mu.Lock()
+	if returning {
+		p.returning--
+	} else {
+		delete(p.inUse, brain)
+	}
+	if unhealthy {
+		p.stats.Unhealthy++
+	} else {
+		p.stats.Evicted++
+	}
+	p.notify()
+	p.mu.Unlock()
+
+	p.shutdown(brain)
+}
+
+// fill pre-builds brains until the min idle brains are kept
+func (p *BrainPool) fill() {
+	for {
+		p.mu.Lock()
+		// the brains being built by fill count as idle, so concurrent fills do not build more than min idle
+		if p.closed || len(p.idle)+p.building >= p.minIdle || p.isFull() {
+			p.mu.Unlock()
+			return
+		}
+		p.building++
+		p.mu.Unlock()
+
+		brain, err := p.buildBrain()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		p.building--
+		p.stats.Built++
+		if p.closed {
+			p.mu.Unlock()
+			p.shutdown(brain)
+			return
+		}
+		p.idle = append(p.idle, idleBrain{brain: brain, since: time.Now()})
+		p.notify()
+		p.mu.Unlock()
+	}
+}
+
+// isFull reports whether the max size of pool is reached, p.mu must be held
+func (p *BrainPool) isFull() bool {
+	return p.maxSize > 0 && len(p.idle)+len(p.inUse)+p.building+p.returning >= p.maxSize
+}
+
+// buildBrain builds a brain in the slot reserved by building. The slot is released if the build failed or panicked,
+// otherwise the caller moves the brain into idle or inUse with the slot released under p.mu
+func (p *BrainPool) buildBrain() (core.Brain, error) {
+	built := false
+	defer func() {
+		if built {
+			return
+		}
+		p.mu.Lock()
+		p.building--
+		p.notify()
+		p.mu.Unlock()
+	}()
+
+	brain := p.build(p.blueprint)
+	if brain == nil {
+		return nil, fmt.Errorf("build brain failed")
+	}
+	built = true
+
+	return brain, nil
+}
+
+func (p *BrainPool) runEvictor() {
+	interval := p.idleTimeout / 2
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.evict()
+			p.fill()
+		}
+	}
+}
+
+// evict shuts down the brains idle longer than idle timeout, the min idle brains are kept
+func (p *BrainPool) evict() {
+	p.mu.Lock()
+	expired := make([]core.Brain, 0)
+	kept := make([]idleBrain, 0, len(p.idle))
+	deadline := time.Now().Add(-p.idleTimeout)
+	// idle is ordered by returned time, the oldest first
+	for i, ib := range p.idle {
+		if ib.since.Before(deadline) && len(p.idle)-i > p.minIdle {
+			expired = append(expired, ib.brain)
+			continue
+		}
+		kept = append(kept, ib)
+	}
+	p.idle = kept
+	p.stats.Evicted += len(expired)
+	p.mu.Unlock()
+
+	for _, brain := range expired {
+		p.shutdown(brain)
+	}
+}
+
+// notify wakes up the waiting Get, p.mu must be held
+func (p *BrainPool) notify() {
+	close(p.released)
+	p.released = make(chan struct{})
+}
+
+// reset waits until brain is sleeping, interrupted or shut down, then clears its state and memory.
+// It fails if brain is still running after the reset timeout
+func (p *BrainPool) reset(brain core.Brain) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.resetTimeout)
+	defer cancel()
+	if err := brain.WaitContext(ctx); err != nil {
+		return err
+	}
+	if brain.GetState() != core.BrainStateShutdown {
+		brain.ForceSleep()
+	}
+	brain.ClearMemory()
+
+	return nil
+}
+
+// shutdown shuts down a dropped brain, it is not used by anyone. The processors still running after
+// the reset timeout are cancelled
+func (p *BrainPool) shutdown(brain core.Brain) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.resetTimeout)
+	defer cancel()
+	_ = brain.Shutdown(ctx)
+}
@@ -12,20 +12,22 @@ import (
 // NewBlueprint new blueprint
 func NewBlueprint() core.Blueprint {
 	return &brainprint{
-		id:      utils.GenID(),
-		labels:  make(map[string]string),
-		neurons: make(map[string]*neuron),
-		links:   make(map[string]*link),
+		id:       utils.GenID(),
+		labels:   make(map[string]string),
+		neurons:  make(map[string]*neuron),
+		links:    make(map[string]*link),
+		reducers: make(map[any]core.MemoryReducer),
 	}
 }
 
 // NewMultiLangBlueprint new multi-language blueprint
 func NewMultiLangBlueprint() core.MultiLangBlueprint {
 	return &brainprint{
-		id:      utils.GenID(),
-		labels:  make(map[string]string),
-		neurons: make(map[string]*neuron),
-		links:   make(map[string]*link),
+		id:       utils.GenID(),
+		labels:   make(map[string]string),
+		neurons:  make(map[string]*neuron),
+		links:    make(map[string]*link),
+		reducers: make(map[any]core.MemoryReducer),
 	}
 }
 
@@ -39,6 +41,10 @@ type brainprint struct {
 	neurons map[string]*neuron
 	// map of all link
 	links map[string]*link
+	// reducers of memory keys
+	reducers map[any]core.MemoryReducer
+	// err is the first error of building blueprint, which AddNeuron has no way to return
+	err error
 }
 
 func (b *brainprint) GetID() string {
@@ -211,15 +217,16 @@ func (b *brainprint) AddLink(from, to core.Neuron, withOpts ...core.LinkOption)
 	if !ok {
 		return nil, errors.ErrNeuronNotFound(to.GetID())
 	}
-	// new link, and neurons set
+	// new link, apply options before neurons set, the options may change link ID
 	l := newLink(from.GetID(), to.GetID())
+	l.applyOptions(withOpts)
+	if _, ok := b.links[l.GetID()]; ok {
+		return nil, errors.ErrLinkExists(l.GetID())
+	}
 	src.addOutLink(l.GetID())
 	dest.addInLink(l.GetID())
 
 	// bp add link
-	for _, opt := range withOpts {
-		opt.Apply(l)
-	}
 	b.links[l.GetID()] = l
 
 	return l, nil
@@ -231,14 +238,15 @@ func (b *brainprint) AddEntryLinkTo(to core.Neuron, withOpts ...core.LinkOption)
 	if !ok {
 		return nil, errors.ErrNeuronNotFound(to.GetID())
 	}
-	// new link, and neurons set
+	// new link, apply options before neurons set, the options may change link ID
 	l := newEntryLink(to.GetID())
+	l.applyOptions(withOpts)
+	if _, ok := b.links[l.GetID()]; ok {
+		return nil, errors.ErrLinkExists(l.GetID())
+	}
 	dest.addInLink(l.GetID())
 
 	// bp add link
-	for _, opt := range withOpts {
-		opt.Apply(l)
-	}
 	b.links[l.GetID()] = l
 
 	return l, nil
@@ -252,15 +260,16 @@ func (b *brainprint) AddEndLinkFrom(from core.Neuron, withOpts ...core.LinkOptio
 	}
 	// ensure END neuron
 	end := b.ensureEndNeuron()
-	// new link, and neurons set
+	// new link, apply options before neurons set, the options may change link ID
 	l := newEndLink(src.GetID())
+	l.applyOptions(withOpts)
+	if _, ok := b.links[l.GetID()]; ok {
+		return nil, errors.ErrLinkExists(l.GetID())
+	}
 	src.addOutLink(l.GetID())
 	end.addInLink(l.GetID())
 
 	// bp add link
-	for _, opt := range withOpts {
-		opt.Apply(l)
-	}
 	b.links[l.GetID()] = l
 
 	return l, nil
@@ -282,9 +291,34 @@ func (b *brainprint) Clone() core.Blueprint {
 	for id, l := range b.links {
 		cp.links[id] = l.deepCopy()
 	}
+	cp.reducers = b.ListMemoryReducers()
+	cp.err = b.err
 	return cp
 }
 
+func (b *brainprint) Err() error {
+	return b.err
+}
+
+func (b *brainprint) SetMemoryReducer(key any, reducer core.MemoryReducer) {
+	if b.reducers == nil {
+		b.reducers = make(map[any]core.MemoryReducer)
+	}
+	if reducer == nil {
+		delete(b.reducers, key)
+		return
+	}
+	b.reducers[key] = reducer
+}
+
+func (b *brainprint) ListMemoryReducers() map[any]core.MemoryReducer {
+	reducers := make(map[any]core.MemoryReducer, len(b.reducers))
+	for k, r := range b.reducers {
+		reducers[k] = r
+	}
+	return reducers
+}
+
 func (b *brainprint) MarshalZerologObject(e *zerolog.Event) {
 	e.Str("id", b.id).
 		Any("labels", b.labels).
@@ -309,8 +343,13 @@ func (ls linkArray) MarshalZerologArray(a *zerolog.Array) {
 
 func (b *brainprint) addNeuronWithProcessor(p processor.Processor, withOpts ...core.NeuronOption) core.Neuron {
 	n := newNeuron(p)
-	for _, opt := range withOpts {
-		opt.Apply(n)
+	n.applyOptions(withOpts)
+	// AddNeuron has no error to return, a neuron with a duplicate ID is not added, and the error is kept for Err
+	if _, ok := b.neurons[n.GetID()]; ok {
+		if b.err == nil {
+			b.err = errors.ErrNeuronExists(n.GetID())
+		}
+		return n
 	}
 	b.neurons[n.GetID()] = n
 
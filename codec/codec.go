@@ -0,0 +1,176 @@
+// Package codec keeps a registry of Go types by name, so the memories of a type can be encoded and decoded
+// back to the original Go type by the memory stores which serialize memories, e.g. BrainLite.
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Encoding is the encoding of the values of a registered type
+type Encoding string
+
+const (
+	JSON    Encoding = "json"
+	Gob     Encoding = "gob"
+	Msgpack Encoding = "msgpack"
+)
+
+// Encoded is a value encoded by a Registry
+type Encoded struct {
+	// TypeName is the name the type of value is registered with
+	TypeName string
+	Encoding Encoding
+	Data     []byte
+}
+
+// Registry maps Go types to names, it is safe for concurrent use
+type Registry struct {
+	mu     sync.RWMutex
+	byName map[string]*entry
+	byType map[reflect.Type]*entry
+}
+
+type entry struct {
+	name     string
+	typ      reflect.Type
+	encoding Encoding
+}
+
+// Default is the registry used by brains unless another one is set, e.g. by brainlite.WithCodecRegistry
+var Default = NewRegistry()
+
+// Register registers the type of value to the Default registry, see Registry.Register
+func Register(name string, value any, encoding Encoding) error {
+	return Default.Register(name, value, encoding)
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		byName: make(map[string]*entry),
+		byType: make(map[reflect.Type]*entry),
+	}
+}
+
+// Register registers the type of value with name, the values of the type are encoded with encoding.
+// value is a zero value of the type, e.g. PastSteps{} or &Plan{}, and a value decoded is of the same type.
+// Registering a type again with the same name changes its encoding, the values encoded before are still decoded
+// with their own encoding. It is an error if name or the type is already registered with another type or name.
+func (r *Registry) Register(name string, value any, encoding Encoding) error {
+	if name == "" {
+		return fmt.Errorf("type name is empty")
+	}
+	if value == nil {
+		return fmt.Errorf("value of type %s is nil", name)
+	}
+	switch encoding {
+	case JSON, Gob, Msgpack:
+	default:
+		return fmt.Errorf("unknown encoding %q", encoding)
+	}
+
+	typ := reflect.TypeOf(value)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.byName[name]; ok && e.typ != typ {
+		return fmt.Errorf("type name %s is registered with type %v", name, e.typ)
+	}
+	if e, ok := r.byType[typ]; ok && e.name != name {
+		return fmt.Errorf("type %v is registered with name %s", typ, e.name)
+	}
+
+	e := &entry{name: name, typ: typ, encoding: encoding}
+	r.byName[name] = e
+	r.byType[typ] = e
+
+	return nil
+}
+
+// Encode encodes value of a registered type, ok is false if the type of value is not registered
+func (r *Registry) Encode(value any) (encoded Encoded, ok bool, err error) {
+	e := r.lookupType(value)
+	if e == nil {
+		return Encoded{}, false, nil
+	}
+
+	data, err := marshal(e.encoding, value)
+	if err != nil {
+		return Encoded{}, true, fmt.Errorf("unable to encode value of type %s: %v", e.name, err)
+	}
+
+	return Encoded{TypeName: e.name, Encoding: e.encoding, Data: data}, true, nil
+}
+
+// Decode decodes encoded to a value of the type registered with encoded.TypeName
+func (r *Registry) Decode(encoded Encoded) (any, error) {
+	r.mu.RLock()
+	e, ok := r.byName[encoded.TypeName]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("type name %s is not registered", encoded.TypeName)
+	}
+
+	// values of a pointer type are decoded to a new value it points to
+	ptr := e.typ.Kind() == reflect.Ptr
+	typ := e.typ
+	if ptr {
+		typ = typ.Elem()
+	}
+	v := reflect.New(typ)
+	if err := unmarshal(encoded.Encoding, encoded.Data, v.Interface()); err != nil {
+		return nil, fmt.Errorf("unable to decode value of type %s: %v", e.name, err)
+	}
+	if ptr {
+		return v.Interface(), nil
+	}
+
+	return v.Elem().Interface(), nil
+}
+
+func (r *Registry) lookupType(value any) *entry {
+	if value == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.byType[reflect.TypeOf(value)]
+}
+
+func marshal(encoding Encoding, value any) ([]byte, error) {
+	switch encoding {
+	case JSON:
+		return json.Marshal(value)
+	case Gob:
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Msgpack:
+		return msgpack.Marshal(value)
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+}
+
+func unmarshal(encoding Encoding, data []byte, ptr any) error {
+	switch encoding {
+	case JSON:
+		return json.Unmarshal(data, ptr)
+	case Gob:
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(ptr)
+	case Msgpack:
+		return msgpack.Unmarshal(data, ptr)
+	default:
+		return fmt.Errorf("unknown encoding %q", encoding)
+	}
+}
@@ -0,0 +1,78 @@
+package codec
+
+import (
+	"reflect"
+)
+
+// Copy returns a deep copy of value, so the copy is not changed by writes through value, e.g. into its maps.
+// A value of a registered type is copied by encoding and decoding it, the others are copied field by field,
+// unexported fields of structs are copied shallowly. value must not refer to itself.
+func (r *Registry) Copy(value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if encoded, ok, err := r.Encode(value); ok {
+		if err != nil {
+			return nil, err
+		}
+		return r.Decode(encoded)
+	}
+
+	return copyValue(reflect.ValueOf(value)).Interface(), nil
+}
+
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			cp.SetMapIndex(iter.Key(), copyValue(iter.Value()))
+		}
+		return cp
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return cp
+	case reflect.Array:
+		cp := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return cp
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type().Elem())
+		cp.Elem().Set(copyValue(v.Elem()))
+		return cp
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(copyValue(v.Elem()))
+		return cp
+	case reflect.Struct:
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if cp.Field(i).CanSet() {
+				cp.Field(i).Set(copyValue(v.Field(i)))
+			}
+		}
+		return cp
+	default:
+		// scalars, strings, funcs and channels
+		return v
+	}
+}
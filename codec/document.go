@@ -0,0 +1,260 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// DocumentVersion is the version of the memory documents written by WriteMemory
+const DocumentVersion = 1
+
+// Document is a portable JSON document of memories, it is written by the ExportMemory of brains
+type Document struct {
+	Version  int      `json:"version"`
+	Memories []Memory `json:"memories"`
+}
+
+// Memory is a memory in a Document. Key is the text of the key, KeyType is one of string, int, uint, float and bool.
+// Value is the JSON view of the value, Type is its type tag, one of the BrainLite tags string, int, float, bool
+// and json, or uint for the unsigned integers of 64 bits. Kind is the Go kind of a number which is not read back
+// by its type tag alone, e.g. int64 or uint32, and TypeName is the name of its registered type, if any.
+type Memory struct {
+	Key      string          `json:"key"`
+	KeyType  string          `json:"key_type"`
+	Type     string          `json:"type"`
+	Kind     string          `json:"kind,omitempty"`
+	TypeName string          `json:"type_name,omitempty"`
+	Value    json.RawMessage `json:"value"`
+}
+
+// type tags of keys and values, the same as the ones of BrainLite memory
+const (
+	typeString = "string"
+	typeInt    = "int"
+	typeUint   = "uint"
+	typeFloat  = "float"
+	typeBool   = "bool"
+	typeJSON   = "json"
+)
+
+// WriteMemory writes memories to w as an indented Document, ordered by the types and the texts of keys,
+// so the documents of two runs can be diffed. Keys are strings, integers, floats or bools, or the types based on them.
+func (r *Registry) WriteMemory(w io.Writer, memories map[any]any) error {
+	doc := Document{Version: DocumentVersion, Memories: make([]Memory, 0, len(memories))}
+	for k, v := range memories {
+		m, err := r.encodeMemory(k, v)
+		if err != nil {
+			return err
+		}
+		doc.Memories = append(doc.Memories, m)
+	}
+	sort.Slice(doc.Memories, func(i, j int) bool {
+		if doc.Memories[i].KeyType != doc.Memories[j].KeyType {
+			return doc.Memories[i].KeyType < doc.Memories[j].KeyType
+		}
+		return doc.Memories[i].Key < doc.Memories[j].Key
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(doc)
+}
+
+// ReadMemory reads the memories of a Document from r. A value with a registered type name is decoded
+// to its Go type, otherwise it is decoded from its JSON view by its type tag, as BrainLite reads it.
+func (r *Registry) ReadMemory(rd io.Reader) (map[any]any, error) {
+	var doc Document
+	if err := json.NewDecoder(rd).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("unable to parse memory document: %v", err)
+	}
+	if doc.Version > DocumentVersion {
+		return nil, fmt.Errorf("memory document version %d is newer than %d", doc.Version, DocumentVersion)
+	}
+
+	memories := make(map[any]any, len(doc.Memories))
+	for _, m := range doc.Memories {
+		key, err := decodeKey(m.KeyType, m.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.decodeMemoryValue(m)
+		if err != nil {
+			return nil, fmt.Errorf("memory %v: %v", key, err)
+		}
+		memories[key] = value
+	}
+
+	return memories, nil
+}
+
+func (r *Registry) encodeMemory(key, value any) (Memory, error) {
+	var m Memory
+	var err error
+	if m.KeyType, m.Key, err = encodeKey(key); err != nil {
+		return m, err
+	}
+
+	switch v := value.(type) {
+	case string:
+		m.Type = typeString
+	case int:
+		m.Type = typeInt
+		// an int out of 32 bits is read back as int64 by its type tag
+		if int64(v) < math.MinInt32 || int64(v) > math.MaxInt32 {
+			m.Kind = reflect.Int.String()
+		}
+	case int8, int16, int32, int64, uint8, uint16, uint32:
+		m.Type, m.Kind = typeInt, reflect.TypeOf(v).Kind().String()
+	case uint, uint64:
+		m.Type, m.Kind = typeUint, reflect.TypeOf(v).Kind().String()
+	case float32:
+		m.Type, m.Kind = typeFloat, reflect.Float32.String()
+	case float64:
+		m.Type = typeFloat
+	case bool:
+		m.Type = typeBool
+	default:
+		m.Type = typeJSON
+	}
+	if e := r.lookupType(value); e != nil {
+		m.TypeName = e.name
+	}
+	if m.Value, err = json.Marshal(value); err != nil {
+		return m, fmt.Errorf("unable to serialize memory %v: %v", key, err)
+	}
+
+	return m, nil
+}
+
+func (r *Registry) decodeMemoryValue(m Memory) (any, error) {
+	if m.TypeName != "" {
+		value, err := r.Decode(Encoded{TypeName: m.TypeName, Encoding: JSON, Data: m.Value})
+		if err == nil {
+			return value, nil
+		}
+		// the type may not be registered in this process, the JSON view is returned
+	}
+
+	if m.Kind != "" {
+		t, ok := numberKinds[m.Kind]
+		if !ok {
+			return nil, fmt.Errorf("unknown value kind %q", m.Kind)
+		}
+		ptr := reflect.New(t)
+		if err := json.Unmarshal(m.Value, ptr.Interface()); err != nil {
+			return nil, fmt.Errorf("unable to parse value of kind %s: %v", m.Kind, err)
+		}
+		return ptr.Elem().Interface(), nil
+	}
+
+	var value any
+	var err error
+	switch m.Type {
+	case typeString:
+		var s string
+		err = json.Unmarshal(m.Value, &s)
+		value = s
+	case typeInt:
+		var i int64
+		err = json.Unmarshal(m.Value, &i)
+		if i >= math.MinInt32 && i <= math.MaxInt32 {
+			value = int(i)
+		} else {
+			value = i
+		}
+	case typeUint:
+		var u uint64
+		err = json.Unmarshal(m.Value, &u)
+		value = u
+	case typeFloat:
+		var f float64
+		err = json.Unmarshal(m.Value, &f)
+		value = f
+	case typeBool:
+		var b bool
+		err = json.Unmarshal(m.Value, &b)
+		value = b
+	case typeJSON:
+		err = json.Unmarshal(m.Value, &value)
+	default:
+		return nil, fmt.Errorf("unknown value type %q", m.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse value: %v", err)
+	}
+
+	return value, nil
+}
+
+// numberKinds are the Go types of the kinds of numbers recorded in documents
+var numberKinds = map[string]reflect.Type{
+	reflect.Int.String():     reflect.TypeOf(int(0)),
+	reflect.Int8.String():    reflect.TypeOf(int8(0)),
+	reflect.Int16.String():   reflect.TypeOf(int16(0)),
+	reflect.Int32.String():   reflect.TypeOf(int32(0)),
+	reflect.Int64.String():   reflect.TypeOf(int64(0)),
+	reflect.Uint.String():    reflect.TypeOf(uint(0)),
+	reflect.Uint8.String():   reflect.TypeOf(uint8(0)),
+	reflect.Uint16.String():  reflect.TypeOf(uint16(0)),
+	reflect.Uint32.String():  reflect.TypeOf(uint32(0)),
+	reflect.Uint64.String():  reflect.TypeOf(uint64(0)),
+	reflect.Float32.String(): reflect.TypeOf(float32(0)),
+}
+
+// encodeKey encodes key as its type tag and text, keys of named types are encoded as the basic types
+func encodeKey(key any) (string, string, error) {
+	if key == nil {
+		return "", "", fmt.Errorf("unsupported key type %T", key)
+	}
+
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.String:
+		return typeString, v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return typeInt, strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return typeInt, strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Uint, reflect.Uint64:
+		return typeUint, strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float64:
+		return typeFloat, strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case reflect.Bool:
+		return typeBool, strconv.FormatBool(v.Bool()), nil
+	default:
+		return "", "", fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// decodeKey decodes the key encoded by encodeKey
+func decodeKey(keyType, text string) (any, error) {
+	var key any
+	var err error
+	switch keyType {
+	case typeString:
+		key = text
+	case typeInt:
+		var i int64
+		i, err = strconv.ParseInt(text, 10, 64)
+		key = int(i)
+	case typeUint:
+		key, err = strconv.ParseUint(text, 10, 64)
+	case typeFloat:
+		key, err = strconv.ParseFloat(text, 64)
+	case typeBool:
+		key, err = strconv.ParseBool(text)
+	default:
+		return nil, fmt.Errorf("unknown key type %q", keyType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %s:%s: %v", keyType, text, err)
+	}
+
+	return key, nil
+}
@@ -29,7 +29,15 @@ type Blueprint interface {
 	AddEntryLinkTo(neuron Neuron, withOpts ...LinkOption) (Link, error)
 	AddEndLinkFrom(neuron Neuron, withOpts ...LinkOption) (Link, error)
 
+	// SetMemoryReducer declares the reducer of memory key, SetMemory of the key combines the new value
+	// with the current one by reducer, instead of overwriting it
+	SetMemoryReducer(key any, reducer MemoryReducer)
+	ListMemoryReducers() map[any]MemoryReducer
+
 	Clone() Blueprint
+	// Err returns the first error of building blueprint which the methods could not return,
+	// e.g. a neuron added by AddNeuron with an ID in use
+	Err() error
 }
 
 // MultiLangBlueprint is extension interface of Blueprint, it is used for supporting multi-language blueprint
@@ -1,10 +1,20 @@
 package core
 
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/Rovanta/rmodel/processor"
+)
+
 const (
 	// BrainStateShutdown brain
 	BrainStateShutdown BrainState = "Shutdown"
 	BrainStateSleeping BrainState = "Sleeping"
 	BrainStateRunning BrainState = "Running"
+	// BrainStateInterrupted brain is parked at interrupt points of neurons, and nothing else is running
+	BrainStateInterrupted BrainState = "Interrupted"
 )
 
 type BrainState string
@@ -17,6 +27,9 @@ type Brain interface {
 	// SetMemory set memories for brain, one key value pair is one memory.
 	// memory will lazy initial util `SetMemory` or any link trig
 	SetMemory(keysAndValues ...any) error
+	// SetMemoryWithTTL sets memory of key which expires after ttl, ttl must be positive.
+	// An expired memory does not exist any more, and it is sent to the watchers with Expired set
+	SetMemoryWithTTL(key, value any, ttl time.Duration) error
 	// GetMemory get memory by key
 	GetMemory(key any) any
 	// ExistMemory indicates whether there is a memory in the brain
@@ -25,10 +38,49 @@ type Brain interface {
 	DeleteMemory(key any)
 	// ClearMemory clear all memories
 	ClearMemory()
+	// WatchMemory returns a channel of changes of memories of keys, all memories are watched if keys is empty.
+	// The channel is closed when ctx is done
+	WatchMemory(ctx context.Context, keys ...any) <-chan processor.MemoryChange
+	// UpdateMemory runs fn in a memory transaction, the writes of tx are committed together only if fn returns nil.
+	// Reads of tx see the memories when the transaction began and its own writes
+	UpdateMemory(fn func(tx processor.MemoryTx) error) error
+	// CompareAndSwapMemory sets key to new if its value deeply equals old, old is nil for a key not existing
+	CompareAndSwapMemory(key, old, new any) (bool, error)
+	// ListMemoryKeys lists keys of all memories, in no particular order
+	ListMemoryKeys() ([]any, error)
+	// RangeMemory calls fn for every memory until fn returns false, fn sees the memories when RangeMemory is called
+	RangeMemory(fn func(key, value any) bool) error
+	// DumpMemory copies all memories
+	DumpMemory() (map[any]any, error)
+	// ExportMemory writes all memories to w as a portable JSON document, see codec.Document
+	ExportMemory(w io.Writer) error
+	// ImportMemory sets the memories of a JSON document written by ExportMemory in one transaction
+	ImportMemory(r io.Reader) error
+	// PutArtifact keeps the content of r in the artifact store of brain, and sets memory name to its reference
+	PutArtifact(name string, r io.Reader) error
+	// OpenArtifact opens the content of the artifact referenced by memory name, the caller closes it
+	OpenArtifact(name string) (io.ReadCloser, error)
 	// GetState get brain state
 	GetState() BrainState
-	// Wait wait util brain maintainer shutdown, which means brain state is `Sleeping`
+	// Wait wait util brain maintainer shutdown, which means brain state is `Sleeping`, or brain is `Interrupted`
 	Wait()
-	// Shutdown the brain
-	Shutdown()
+	// WaitContext waits as Wait, until ctx is done
+	WaitContext(ctx context.Context) error
+	// WaitFor waits until the memory of key satisfies predicate or ctx is done, even if brain keeps running
+	WaitFor(ctx context.Context, key any, predicate func(value any) bool) (any, error)
+	// ListInterrupts lists the interrupt points where the run is parked, see WithInterruptBefore and WithInterruptAfter
+	ListInterrupts() []Interrupt
+	// Resume updates memory of the run, then continues all interrupt points of the run
+	Resume(runID string, keysAndValues ...any) error
+	// Reject drops all interrupt points of the run, the neurons parked there are not activated and do not cast
+	Reject(runID string) error
+	// Apply applies patch to the topology of brain, it can be applied when brain is running
+	Apply(patch Patch) error
+	// Explain explains the state of brain, e.g. why the last run went to sleep without reaching END
+	Explain() Explanation
+	// ForceSleep resets all links and neurons, and brain state to `Sleeping`
+	ForceSleep()
+	// Shutdown the brain gracefully, it waits the in-flight processors until ctx is done, then cancels them.
+	// It can be called repeatedly, and brain is started again by Entry or TrigLinks after shutdown
+	Shutdown(ctx context.Context) error
 }
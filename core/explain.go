@@ -0,0 +1,50 @@
+package core
+
+// Explanation explains the state of brain, e.g. why a run went to sleep without reaching END.
+// For a sleeping brain it is taken when the last run went to sleep, otherwise it is the current state.
+type Explanation struct {
+	State BrainState
+	// ReachedEnd indicates whether the run reached END neuron
+	ReachedEnd bool
+	// Neurons lists the neurons which are not inactive, have partially satisfied trigger groups or cast in the run,
+	// sorted by neuron ID
+	Neurons []NeuronExplanation
+	// BlockedTriggerGroups lists the trigger groups which can never become ready, found by the topology only
+	BlockedTriggerGroups []BlockedTriggerGroup
+}
+
+// NeuronExplanation explains a neuron in the run
+type NeuronExplanation struct {
+	NeuronID string
+	State    NeuronState
+	// PartialTriggerGroups lists the trigger groups which have ready in-links, but not all of them
+	PartialTriggerGroups []TriggerGroupExplanation
+	// Cast is the last cast of neuron in the run, it is nil if neuron did not cast
+	Cast *CastExplanation
+}
+
+// TriggerGroupExplanation explains the in-links of a trigger group
+type TriggerGroupExplanation struct {
+	ReadyLinkIDs []string
+	// WaitLinkIDs are the in-links whose source neuron is processing
+	WaitLinkIDs []string
+	// InitLinkIDs are the in-links which are not cast
+	InitLinkIDs []string
+}
+
+// CastExplanation explains a cast of neuron
+type CastExplanation struct {
+	SelectedGroup string
+	// CastLinkIDs are the out-links in the selected cast group
+	CastLinkIDs []string
+	// SkippedLinkIDs are the out-links in other cast groups
+	SkippedLinkIDs []string
+}
+
+// BlockedTriggerGroup is a trigger group which can never become ready, e.g. two of its in-links are only reachable
+// through different cast groups of one neuron, and only one cast group is selected in a cast
+type BlockedTriggerGroup struct {
+	NeuronID string
+	LinkIDs  []string
+	Reason   string
+}
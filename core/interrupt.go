@@ -0,0 +1,22 @@
+package core
+
+import "github.com/Rovanta/rmodel/processor"
+
+type InterruptPoint string
+
+const (
+	InterruptPointBefore InterruptPoint = "before"
+	InterruptPointAfter  InterruptPoint = "after"
+)
+
+// Interrupt is the notice of a run parked at an interrupt point of a neuron
+type Interrupt struct {
+	// RunID the run which is parked
+	RunID string
+	// NeuronID the pending neuron
+	NeuronID string
+	// Point the run is parked before or after the neuron
+	Point InterruptPoint
+	// Memory reads the memory of the run
+	Memory processor.BrainContextReader
+}
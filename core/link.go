@@ -42,3 +42,19 @@ func WithLinkLabels(labels map[string]string) LinkOption {
 		link.SetLabels(labels)
 	})
 }
+
+// WithLinkID sets the specific ID for Link, a stable ID is required to resume a brain from a checkpoint
+// after the blueprint is rebuilt in a new process. The ID is taken when the link is added, it can not be changed later
+func WithLinkID(linkID string) LinkOption {
+	return linkIDOption(linkID)
+}
+
+// linkIDOption is read by the blueprint when it creates the link, so Link has no setter of ID
+type linkIDOption string
+
+func (o linkIDOption) Apply(Link) {}
+
+// LinkID returns the ID set by WithLinkID
+func (o linkIDOption) LinkID() string {
+	return string(o)
+}
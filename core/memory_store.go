@@ -0,0 +1,56 @@
+package core
+
+import "time"
+
+// MemoryStore keeps the memories of a brain. It must be safe for concurrent use, and a memory set by Set is
+// visible to Get when Set returns. Built-in stores are in package memstore, and a store is used by a brain
+// with the option of the brain implementation, brainlocal.WithMemoryStore or brainlite.WithMemoryStore.
+// Every store should pass the conformance suite memstoretest.TestMemoryStore.
+type MemoryStore interface {
+	// Get gets memory by key, ok is false if it does not exist
+	Get(key any) (value any, ok bool, err error)
+	// Set sets memory of key, the old value is replaced
+	Set(key, value any) error
+	// Delete deletes memory of key, it is not an error if it does not exist
+	Delete(key any) error
+	// Clear deletes all memories
+	Clear() error
+	// Keys lists keys of all memories, in no particular order
+	Keys() ([]any, error)
+	// Snapshot copies all memories, a key of bytes is a string in the map
+	Snapshot() (map[any]any, error)
+	// Close releases the resources of store, it is not used after Close
+	Close() error
+}
+
+// EvictingMemoryStore is a MemoryStore which may drop memories by itself, e.g. a cache bounded by cost
+type EvictingMemoryStore interface {
+	MemoryStore
+	// OnEvict sets fn which is called with the key and the value of every dropped memory.
+	// fn may be called by the goroutines of store with store locked, so it must not call store
+	OnEvict(fn func(key, value any))
+}
+
+// TTLMemoryStore is a MemoryStore which can drop memories by itself when they expire
+type TTLMemoryStore interface {
+	MemoryStore
+	// SetWithTTL sets memory of key which expires after ttl, it never expires if ttl is 0
+	SetWithTTL(key, value any, ttl time.Duration) error
+}
+
+// SharedMemoryStore is a MemoryStore shared by brains in many processes, which reports the changes made by the others
+type SharedMemoryStore interface {
+	MemoryStore
+	// OnChange sets fn which is called with every change of memory made by the other users of store
+	OnChange(fn func(change MemoryStoreChange))
+}
+
+// MemoryStoreChange is a change of memory in a SharedMemoryStore
+type MemoryStoreChange struct {
+	Key any
+	// Value is nil if the memory is deleted
+	Value   any
+	Deleted bool
+	// Cleared indicates that all memories are cleared, Key is nil
+	Cleared bool
+}
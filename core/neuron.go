@@ -1,12 +1,22 @@
 package core
 
 import (
+	"strconv"
+
 	"github.com/Rovanta/rmodel/internal/utils"
 	"github.com/Rovanta/rmodel/processor"
+	"github.com/Rovanta/rmodel/pyprocessor"
 )
 
 const (
 	EndNeuronID = "__END_NEURON__"
+
+	// NeuronLabelInterruptBefore the run is parked before the neuron with this label is activated
+	NeuronLabelInterruptBefore = "interrupt_before"
+	// NeuronLabelInterruptAfter the run is parked after the neuron with this label is processed, before it casts
+	NeuronLabelInterruptAfter = "interrupt_after"
+	// NeuronLabelPriority the activations of neuron with higher priority are processed first, it is an integer, 0 by default
+	NeuronLabelPriority = "priority"
 )
 
 type NeuronState string
@@ -14,6 +24,10 @@ type NeuronState string
 const (
 	NeuronStateInactive  NeuronState = "Inactive"
 	NeuronStateActivated NeuronState = "Active"
+	// NeuronStateInterruptedBefore the neuron is ready to activate, but parked until the run is resumed or rejected
+	NeuronStateInterruptedBefore NeuronState = "InterruptedBefore"
+	// NeuronStateInterruptedAfter the neuron is processed, but parked until the run is resumed or rejected
+	NeuronStateInterruptedAfter NeuronState = "InterruptedAfter"
 )
 
 type Neuron interface {
@@ -53,6 +67,22 @@ func WithNeuronLabels(labels map[string]string) NeuronOption {
 	})
 }
 
+// WithNeuronID sets the specific ID for Neuron, a stable ID is required to resume a brain from a checkpoint
+// after the blueprint is rebuilt in a new process. The ID is taken when the neuron is added, it can not be changed later
+func WithNeuronID(neuronID string) NeuronOption {
+	return neuronIDOption(neuronID)
+}
+
+// neuronIDOption is read by the blueprint when it creates the neuron, so Neuron has no setter of ID
+type neuronIDOption string
+
+func (o neuronIDOption) Apply(Neuron) {}
+
+// NeuronID returns the ID set by WithNeuronID
+func (o neuronIDOption) NeuronID() string {
+	return string(o)
+}
+
 // WithSelectFn sets the specific selectFn for Neuron
 func WithSelectFn(selectFn func(brain processor.BrainContextReader) string) NeuronOption {
 	return neuronOptionFunc(func(neuron Neuron) {
@@ -67,10 +97,43 @@ func WithSelector(selector processor.Selector) NeuronOption {
 	})
 }
 
+// WithInterruptBefore parks the run before the neuron is activated, the run continues when it is resumed
+func WithInterruptBefore() NeuronOption {
+	return neuronOptionFunc(func(neuron Neuron) {
+		origin := neuron.GetLabels()
+		neuron.SetLabels(utils.MergeLabels(origin, map[string]string{NeuronLabelInterruptBefore: "true"}))
+	})
+}
+
+// WithInterruptAfter parks the run after the neuron is processed, the neuron casts when the run is resumed
+func WithInterruptAfter() NeuronOption {
+	return neuronOptionFunc(func(neuron Neuron) {
+		origin := neuron.GetLabels()
+		neuron.SetLabels(utils.MergeLabels(origin, map[string]string{NeuronLabelInterruptAfter: "true"}))
+	})
+}
+
+// WithNeuronPriority sets the priority of Neuron, the activations with higher priority are processed first
+// when many neurons are ready at once, e.g. a user-facing responder before background summarisation
+func WithNeuronPriority(priority int) NeuronOption {
+	return neuronOptionFunc(func(neuron Neuron) {
+		origin := neuron.GetLabels()
+		neuron.SetLabels(utils.MergeLabels(origin, map[string]string{NeuronLabelPriority: strconv.Itoa(priority)}))
+	})
+}
+
 // WithPyProcessExecCmd sets the specific python command for Neuron
 func WithPyProcessExecCmd(pythonCmd string) NeuronOption {
 	return neuronOptionFunc(func(neuron Neuron) {
 		origin := neuron.GetLabels()
 		neuron.SetLabels(utils.MergeLabels(origin, map[string]string{"python_cmd": pythonCmd}))
 	})
-}
\ No newline at end of file
+}
+
+// WithPySelector sets a Python selector for Neuron, the select(ctx) of the class in ${pyCodePath}/${moduleName}.py
+// returns the cast group, it reads memory by ctx. The python command is set by WithPyProcessExecCmd
+func WithPySelector(pyCodePath, moduleName, selectorClassName string, constructorArgs map[string]interface{}) NeuronOption {
+	return neuronOptionFunc(func(neuron Neuron) {
+		neuron.BindCastGroupSelector(pyprocessor.LoadPythonSelector(pyCodePath, moduleName, selectorClassName, constructorArgs))
+	})
+}
@@ -0,0 +1,50 @@
+package core
+
+import "github.com/Rovanta/rmodel/processor"
+
+type GroupChangeKind string
+
+const (
+	GroupChangeAddTrigger    GroupChangeKind = "add_trigger_group"
+	GroupChangeRemoveTrigger GroupChangeKind = "remove_trigger_group"
+	GroupChangeAddCast       GroupChangeKind = "add_cast_group"
+	GroupChangeRemoveCast    GroupChangeKind = "remove_cast_group"
+)
+
+// GroupChange is a change of trigger group or cast group of a neuron in a Patch
+type GroupChange struct {
+	Kind     GroupChangeKind
+	NeuronID string
+	// GroupName is the name of cast group, it is empty for trigger group
+	GroupName string
+	LinkIDs   []string
+}
+
+// Patch describes changes of the topology of a built brain, it is applied by Apply of brain.
+// The links and neurons are removed first, then the new links and neurons are added, then the groups are changed.
+type Patch interface {
+	AddNeuron(processFn func(bc processor.BrainContext) error, withOpts ...NeuronOption) Neuron
+	AddNeuronWithProcessor(processor processor.Processor, withOpts ...NeuronOption) Neuron
+	// AddLink adds a link, from and to can be neurons of the brain or neurons added by the patch
+	AddLink(from, to Neuron, withOpts ...LinkOption) (Link, error)
+	AddEntryLinkTo(to Neuron, withOpts ...LinkOption) (Link, error)
+	AddEndLinkFrom(from Neuron, withOpts ...LinkOption) (Link, error)
+	// RemoveNeuron removes a neuron of the brain, its in-links and out-links are removed too
+	RemoveNeuron(neuronID string)
+	// RemoveLink removes a link of the brain, it is removed from groups of neurons too
+	RemoveLink(linkID string)
+	// AddTriggerGroup puts in-links of neuron into the same trigger group, as Neuron.AddTriggerGroup
+	AddTriggerGroup(neuronID string, linkIDs ...string)
+	// RemoveTriggerGroup removes the trigger group of the links, any of the links can trigger neuron again
+	RemoveTriggerGroup(neuronID string, linkIDs ...string)
+	// AddCastGroup puts out-links of neuron into the cast group, as Neuron.AddCastGroup
+	AddCastGroup(neuronID, groupName string, linkIDs ...string)
+	// RemoveCastGroup removes the cast group, its links are moved into the default cast group
+	RemoveCastGroup(neuronID, groupName string)
+
+	ListNeurons() []Neuron
+	ListLinks() []Link
+	ListRemovedNeurons() []string
+	ListRemovedLinks() []string
+	ListGroupChanges() []GroupChange
+}
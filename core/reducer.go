@@ -0,0 +1,185 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MemoryReducer combines the current value of a memory with the value set by SetMemory, and returns the value to store.
+// current is nil if the memory does not exist. The reducer of a key is declared on the blueprint by SetMemoryReducer,
+// and the read, reduce and write of the key are atomic, so parallel neurons can write the same key safely.
+type MemoryReducer func(current, update any) (any, error)
+
+// AppendReducer appends update to the current slice. If update is a slice, its elements are appended.
+// The current slice keeps its type, a new slice is []any unless update is a slice.
+func AppendReducer() MemoryReducer {
+	return func(current, update any) (any, error) {
+		uv := reflect.ValueOf(update)
+		updateIsSlice := uv.Kind() == reflect.Slice
+		if current == nil {
+			if updateIsSlice {
+				return reflect.AppendSlice(reflect.MakeSlice(uv.Type(), 0, uv.Len()), uv).Interface(), nil
+			}
+			return []any{update}, nil
+		}
+
+		cv := reflect.ValueOf(current)
+		if cv.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("append reducer: current value is %T, not a slice", current)
+		}
+		elemType := cv.Type().Elem()
+		items := []reflect.Value{uv}
+		if updateIsSlice {
+			items = make([]reflect.Value, 0, uv.Len())
+			for i := 0; i < uv.Len(); i++ {
+				items = append(items, uv.Index(i))
+			}
+		}
+
+		ret := reflect.MakeSlice(cv.Type(), 0, cv.Len()+len(items))
+		ret = reflect.AppendSlice(ret, cv)
+		for _, item := range items {
+			if !item.IsValid() {
+				// nil update
+				item = reflect.Zero(elemType)
+			}
+			if !item.Type().AssignableTo(elemType) {
+				return nil, fmt.Errorf("append reducer: %s can not be appended to %T", item.Type(), current)
+			}
+			ret = reflect.Append(ret, item)
+		}
+
+		return ret.Interface(), nil
+	}
+}
+
+// MergeMapReducer merges update map into a copy of the current map, the keys of update override the current ones
+func MergeMapReducer() MemoryReducer {
+	return func(current, update any) (any, error) {
+		uv := reflect.ValueOf(update)
+		if uv.Kind() != reflect.Map {
+			return nil, fmt.Errorf("merge map reducer: update is %T, not a map", update)
+		}
+		if current == nil {
+			current = reflect.MakeMap(uv.Type()).Interface()
+		}
+		cv := reflect.ValueOf(current)
+		if cv.Kind() != reflect.Map {
+			return nil, fmt.Errorf("merge map reducer: current value is %T, not a map", current)
+		}
+
+		ret := reflect.MakeMapWithSize(cv.Type(), cv.Len()+uv.Len())
+		for _, src := range []reflect.Value{cv, uv} {
+			iter := src.MapRange()
+			for iter.Next() {
+				k, v := iter.Key(), iter.Value()
+				if !k.Type().AssignableTo(cv.Type().Key()) || !v.Type().AssignableTo(cv.Type().Elem()) {
+					return nil, fmt.Errorf("merge map reducer: %T can not be merged into %T", update, current)
+				}
+				ret.SetMapIndex(k, v)
+			}
+		}
+
+		return ret.Interface(), nil
+	}
+}
+
+// SumReducer adds update to the current number. The sum of integers keeps the type of the current value,
+// and it is float64 if any of them is a float.
+func SumReducer() MemoryReducer {
+	return func(current, update any) (any, error) {
+		if current == nil {
+			if _, ok := numberKind(update); !ok {
+				return nil, fmt.Errorf("sum reducer: update is %T, not a number", update)
+			}
+			return update, nil
+		}
+
+		cKind, cOK := numberKind(current)
+		uKind, uOK := numberKind(update)
+		if !cOK || !uOK {
+			return nil, fmt.Errorf("sum reducer: %T and %T are not numbers", current, update)
+		}
+		cv, uv := reflect.ValueOf(current), reflect.ValueOf(update)
+		if cKind == reflect.Float64 || uKind == reflect.Float64 {
+			sum := toFloat(cv) + toFloat(uv)
+			if cKind == reflect.Float64 {
+				return reflect.ValueOf(sum).Convert(cv.Type()).Interface(), nil
+			}
+			return sum, nil
+		}
+		if cKind == reflect.Uint64 && uKind == reflect.Uint64 {
+			return reflect.ValueOf(cv.Uint() + uv.Uint()).Convert(cv.Type()).Interface(), nil
+		}
+
+		return reflect.ValueOf(toInt(cv) + toInt(uv)).Convert(cv.Type()).Interface(), nil
+	}
+}
+
+// MaxReducer keeps the larger one of the current number and update
+func MaxReducer() MemoryReducer {
+	return func(current, update any) (any, error) {
+		uKind, uOK := numberKind(update)
+		if !uOK {
+			return nil, fmt.Errorf("max reducer: update is %T, not a number", update)
+		}
+		if current == nil {
+			return update, nil
+		}
+		cKind, cOK := numberKind(current)
+		if !cOK {
+			return nil, fmt.Errorf("max reducer: current value is %T, not a number", current)
+		}
+
+		cv, uv := reflect.ValueOf(current), reflect.ValueOf(update)
+		var greater bool
+		switch {
+		case cKind == reflect.Float64 || uKind == reflect.Float64:
+			greater = toFloat(uv) > toFloat(cv)
+		case cKind == reflect.Uint64 && uKind == reflect.Uint64:
+			greater = uv.Uint() > cv.Uint()
+		default:
+			greater = toInt(uv) > toInt(cv)
+		}
+		if greater {
+			return update, nil
+		}
+
+		return current, nil
+	}
+}
+
+// numberKind returns the kind of number, the signed integers are Int64, the unsigned ones are Uint64,
+// and the floats are Float64
+func numberKind(v any) (reflect.Kind, bool) {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int64, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.Uint64, true
+	case reflect.Float32, reflect.Float64:
+		return reflect.Float64, true
+	default:
+		return reflect.Invalid, false
+	}
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint())
+	default:
+		return float64(v.Int())
+	}
+}
+
+func toInt(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(v.Uint())
+	default:
+		return v.Int()
+	}
+}
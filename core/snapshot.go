@@ -0,0 +1,20 @@
+package core
+
+import "time"
+
+// Snapshot is the memory and graph state of a brain, it is taken after a step of brain maintainer.
+// The memory of snapshot is kept by brain, and restored when brain is forked from the snapshot.
+type Snapshot struct {
+	// ID of snapshot, it increases with steps in a run
+	ID int
+	// BrainID the brain which snapshot is taken from
+	BrainID string
+	// Step the maintainer step after which snapshot is taken
+	Step string
+	// CreatedAt the time snapshot is taken
+	CreatedAt time.Time
+	// Links state of every link
+	Links map[string]LinkState
+	// Neurons state of every neuron
+	Neurons map[string]NeuronState
+}
@@ -7,6 +7,8 @@ import (
 var (
 	errNeuronNotFound = errors.New("neuron not found")
 	errLinkNotFound   = errors.New("link not found")
+	errNeuronExists   = errors.New("neuron already exists")
+	errLinkExists     = errors.New("link already exists")
 )
 
 func Wrapf(err error, format string, args ...interface{}) error {
@@ -28,3 +30,11 @@ func ErrInLinkNotFound(linkID, neuronID string) error {
 func ErrOutLinkNotFound(linkID, neuronID string) error {
 	return errors.Wrapf(errLinkNotFound, "out-link %s of neuron %s", linkID, neuronID)
 }
+
+func ErrNeuronExists(neuronID string) error {
+	return errors.Wrapf(errNeuronExists, "neuron: %s", neuronID)
+}
+
+func ErrLinkExists(linkID string) error {
+	return errors.Wrapf(errLinkExists, "link: %s", linkID)
+}
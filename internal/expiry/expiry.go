@@ -0,0 +1,85 @@
+package expiry
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Rovanta/rmodel/internal/memkey"
+)
+
+// Times keeps the expiration times of memories by their keys, it is safe for concurrent use.
+// A key which is not comparable never expires
+type Times struct {
+	mu sync.Mutex
+	// at is kept by the keys made by memkey.Of
+	at map[any]time.Time
+}
+
+// Set sets the expiration time of the memory of key
+func (t *Times) Set(key any, at time.Time) {
+	k, ok := memkey.Of(key)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.at == nil {
+		t.at = make(map[any]time.Time)
+	}
+	t.at[k] = at
+}
+
+// Unset clears the expiration time of the memory of key, it reports whether the memory was expiring
+func (t *Times) Unset(key any) bool {
+	k, ok := memkey.Of(key)
+	if !ok {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok = t.at[k]
+	delete(t.at, k)
+
+	return ok
+}
+
+// At returns the expiration time of the memory of key, ok is false if it never expires
+func (t *Times) At(key any) (time.Time, bool) {
+	k, ok := memkey.Of(key)
+	if !ok {
+		return time.Time{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	at, ok := t.at[k]
+
+	return at, ok
+}
+
+// Expired reports whether the memory of key is expired at now
+func (t *Times) Expired(key any, now time.Time) bool {
+	at, ok := t.At(key)
+
+	return ok && !now.Before(at)
+}
+
+// List lists the keys of the memories expired at now
+func (t *Times) List(now time.Time) []any {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys := make([]any, 0)
+	for k, at := range t.at {
+		if !now.Before(at) {
+			keys = append(keys, memkey.Key(k))
+		}
+	}
+
+	return keys
+}
+
+// Reset clears the expiration times of all memories
+func (t *Times) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.at = nil
+}
@@ -0,0 +1,52 @@
+package expiry
+
+import (
+	"sync"
+	"time"
+)
+
+// Sweeper calls sweep every interval in a goroutine of its own, e.g. to delete the expired memories
+type Sweeper struct {
+	mu sync.Mutex
+	// stop is closed to stop the goroutine, and done is closed when it exits
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Start starts the sweeper if it is not running
+func (s *Sweeper) Start(interval time.Duration, sweep func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	s.stop, s.done = stop, done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				sweep()
+			}
+		}
+	}()
+}
+
+// Stop stops the sweeper and waits it to exit, so it must not be called with a lock which sweep takes
+func (s *Sweeper) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
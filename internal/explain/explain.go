@@ -0,0 +1,361 @@
+package explain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// Topology is the links and neurons of a brain with their states, it is what brain explains
+type Topology struct {
+	Neurons map[string]*Neuron
+	Links   map[string]*Link
+}
+
+// Link is a link of topology
+type Link struct {
+	ID    string
+	From  string
+	To    string
+	State core.LinkState
+}
+
+// Neuron is a neuron of topology, its groups hold IDs of links
+type Neuron struct {
+	ID    string
+	State core.NeuronState
+	// Cast is the last cast of neuron in the run
+	Cast          *core.CastExplanation
+	TriggerGroups [][]string
+	CastGroups    map[string][]string
+	// HasSelector is false if neuron always selects the default cast group
+	HasSelector bool
+}
+
+// Neurons explains the neurons which are not inactive, have partially satisfied trigger groups or did cast
+func Neurons(t Topology) []core.NeuronExplanation {
+	ret := make([]core.NeuronExplanation, 0)
+	for _, id := range t.sortedNeuronIDs() {
+		n := t.Neurons[id]
+		ne := core.NeuronExplanation{
+			NeuronID: n.ID,
+			State:    n.State,
+			Cast:     n.Cast,
+		}
+		for _, ids := range sortedGroups(n.TriggerGroups) {
+			g := core.TriggerGroupExplanation{}
+			for _, linkID := range ids {
+				l, ok := t.Links[linkID]
+				if !ok {
+					continue
+				}
+				switch l.State {
+				case core.LinkStateReady:
+					g.ReadyLinkIDs = append(g.ReadyLinkIDs, l.ID)
+				case core.LinkStateWait:
+					g.WaitLinkIDs = append(g.WaitLinkIDs, l.ID)
+				default:
+					g.InitLinkIDs = append(g.InitLinkIDs, l.ID)
+				}
+			}
+			if len(g.ReadyLinkIDs) > 0 && len(g.ReadyLinkIDs) < len(ids) {
+				ne.PartialTriggerGroups = append(ne.PartialTriggerGroups, g)
+			}
+		}
+
+		if ne.State != core.NeuronStateInactive || len(ne.PartialTriggerGroups) > 0 || ne.Cast != nil {
+			ret = append(ret, ne)
+		}
+	}
+
+	return ret
+}
+
+// Cast explains a cast with the selected cast group, castGroups maps name of cast group to IDs of its links.
+// A link in the selected group is cast even if it is in other groups too.
+func Cast(castGroups map[string][]string, selectedGroup string) *core.CastExplanation {
+	cast := &core.CastExplanation{
+		SelectedGroup:  selectedGroup,
+		CastLinkIDs:    append([]string{}, castGroups[selectedGroup]...),
+		SkippedLinkIDs: make([]string, 0),
+	}
+	selected := make(map[string]bool, len(cast.CastLinkIDs))
+	for _, id := range cast.CastLinkIDs {
+		selected[id] = true
+	}
+	for name, ids := range castGroups {
+		if name == selectedGroup {
+			continue
+		}
+		for _, id := range ids {
+			if !selected[id] {
+				selected[id] = true
+				cast.SkippedLinkIDs = append(cast.SkippedLinkIDs, id)
+			}
+		}
+	}
+	sort.Strings(cast.CastLinkIDs)
+	sort.Strings(cast.SkippedLinkIDs)
+
+	return cast
+}
+
+// requirement is what a neuron, a link or a trigger group needs to be reached in a run
+type requirement struct {
+	reached bool
+	// choices maps neuron ID to the cast groups it must select one of, and the link which requires them
+	choices map[string]choice
+	// reason is not empty if it can never be reached
+	reason string
+}
+
+type choice struct {
+	groups map[string]bool
+	linkID string
+}
+
+// BlockedTriggerGroups finds the trigger groups which can never become ready by the topology.
+// A neuron not on a cycle casts once in a run, so the out-links only in its different cast groups exclude each other.
+// The cast groups selected on every path to a neuron are propagated from the entry links,
+// and a trigger group which requires cast groups of one neuron which have no link in common is blocked.
+func BlockedTriggerGroups(t Topology) []core.BlockedTriggerGroup {
+	// a link may be in many cast groups of its source neuron
+	castGroupsOf := make(map[string]map[string]bool)
+	for _, n := range t.Neurons {
+		for name, ids := range n.CastGroups {
+			for _, id := range ids {
+				if castGroupsOf[id] == nil {
+					castGroupsOf[id] = make(map[string]bool)
+				}
+				castGroupsOf[id][name] = true
+			}
+		}
+	}
+	cyclic := t.cyclicNeurons()
+	reached := make(map[string]map[string]choice)
+
+	linkRequirement := func(linkID string) requirement {
+		l, ok := t.Links[linkID]
+		if !ok {
+			return requirement{}
+		}
+		if l.From == core.EntryLinkFrom {
+			return requirement{reached: true, choices: map[string]choice{}}
+		}
+		src, ok := t.Neurons[l.From]
+		if !ok {
+			return requirement{}
+		}
+		groups := castGroupsOf[l.ID]
+		if len(groups) == 0 {
+			return requirement{reason: fmt.Sprintf("link %s is not in any cast group of neuron %s", l.ID, src.ID)}
+		}
+		if !src.HasSelector {
+			if !groups[processor.DefaultCastGroupName] {
+				return requirement{reason: fmt.Sprintf("link %s is in cast group %s of neuron %s, but neuron has no selector", l.ID, groupNames(groups), src.ID)}
+			}
+			groups = map[string]bool{processor.DefaultCastGroupName: true}
+		}
+		srcChoices, ok := reached[src.ID]
+		if !ok {
+			return requirement{}
+		}
+
+		choices := make(map[string]choice, len(srcChoices)+1)
+		for id, c := range srcChoices {
+			choices[id] = c
+		}
+		if !cyclic[src.ID] {
+			choices[src.ID] = choice{groups: groups, linkID: l.ID}
+		}
+
+		return requirement{reached: true, choices: choices}
+	}
+
+	groupRequirement := func(ids []string) requirement {
+		reqs := make([]requirement, 0, len(ids))
+		for _, id := range ids {
+			req := linkRequirement(id)
+			if req.reason != "" {
+				return req
+			}
+			reqs = append(reqs, req)
+		}
+		choices := make(map[string]choice)
+		for _, req := range reqs {
+			if !req.reached {
+				return requirement{}
+			}
+			for id, c := range req.choices {
+				exist, ok := choices[id]
+				if !ok {
+					choices[id] = c
+					continue
+				}
+				common := make(map[string]bool)
+				for name := range c.groups {
+					if exist.groups[name] {
+						common[name] = true
+					}
+				}
+				if len(common) == 0 {
+					return requirement{reason: fmt.Sprintf(
+						"link %s needs cast group %s of neuron %s, but link %s needs cast group %s",
+						exist.linkID, groupNames(exist.groups), id, c.linkID, groupNames(c.groups))}
+				}
+				choices[id] = choice{groups: common, linkID: exist.linkID}
+			}
+		}
+
+		return requirement{reached: true, choices: choices}
+	}
+
+	ids := t.sortedNeuronIDs()
+	for changed := true; changed; {
+		changed = false
+		for _, id := range ids {
+			var choices map[string]choice
+			for _, group := range t.Neurons[id].TriggerGroups {
+				req := groupRequirement(group)
+				if !req.reached {
+					continue
+				}
+				if choices == nil {
+					choices = req.choices
+					continue
+				}
+				// neuron is reached by any of its trigger groups, so by any of the cast groups they need
+				merged := make(map[string]choice, len(choices))
+				for nid, c := range choices {
+					other, ok := req.choices[nid]
+					if !ok {
+						continue
+					}
+					groups := make(map[string]bool, len(c.groups)+len(other.groups))
+					for name := range c.groups {
+						groups[name] = true
+					}
+					for name := range other.groups {
+						groups[name] = true
+					}
+					merged[nid] = choice{groups: groups, linkID: c.linkID}
+				}
+				choices = merged
+			}
+			if choices == nil {
+				continue
+			}
+			if old, ok := reached[id]; !ok || !sameChoices(old, choices) {
+				reached[id] = choices
+				changed = true
+			}
+		}
+	}
+
+	blocked := make([]core.BlockedTriggerGroup, 0)
+	for _, id := range ids {
+		for _, group := range sortedGroups(t.Neurons[id].TriggerGroups) {
+			if req := groupRequirement(group); req.reason != "" {
+				blocked = append(blocked, core.BlockedTriggerGroup{
+					NeuronID: id,
+					LinkIDs:  group,
+					Reason:   req.reason,
+				})
+			}
+		}
+	}
+
+	return blocked
+}
+
+// cyclicNeurons finds the neurons on a cycle, they may cast many times in a run
+func (t Topology) cyclicNeurons() map[string]bool {
+	next := make(map[string][]string)
+	for _, l := range t.Links {
+		if l.From != core.EntryLinkFrom {
+			next[l.From] = append(next[l.From], l.To)
+		}
+	}
+
+	cyclic := make(map[string]bool)
+	for id := range t.Neurons {
+		visited := make(map[string]bool)
+		stack := append([]string(nil), next[id]...)
+		for len(stack) > 0 {
+			cur := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if cur == id {
+				cyclic[id] = true
+				break
+			}
+			if visited[cur] {
+				continue
+			}
+			visited[cur] = true
+			stack = append(stack, next[cur]...)
+		}
+	}
+
+	return cyclic
+}
+
+func (t Topology) sortedNeuronIDs() []string {
+	ids := make([]string, 0, len(t.Neurons))
+	for id := range t.Neurons {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// sortedGroups returns groups with link IDs sorted, in order of their first link ID
+func sortedGroups(groups [][]string) [][]string {
+	ret := make([][]string, 0, len(groups))
+	for _, ids := range groups {
+		sorted := append([]string(nil), ids...)
+		sort.Strings(sorted)
+		ret = append(ret, sorted)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if len(ret[i]) == 0 || len(ret[j]) == 0 {
+			return len(ret[i]) < len(ret[j])
+		}
+		return ret[i][0] < ret[j][0]
+	})
+
+	return ret
+}
+
+// groupNames formats names of cast groups, e.g. "a" or "a" or "b"
+func groupNames(groups map[string]bool) string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, fmt.Sprintf("%q", name))
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, " or ")
+}
+
+func sameChoices(a, b map[string]choice) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, c := range a {
+		other, ok := b[id]
+		if !ok || len(other.groups) != len(c.groups) {
+			return false
+		}
+		for name := range c.groups {
+			if !other.groups[name] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,45 @@
+package interrupt
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/Rovanta/rmodel/core"
+)
+
+// PointOf returns the interrupt point where a neuron in state is parked, ok is false if it is not parked
+func PointOf(state core.NeuronState) (core.InterruptPoint, bool) {
+	switch state {
+	case core.NeuronStateInterruptedBefore:
+		return core.InterruptPointBefore, true
+	case core.NeuronStateInterruptedAfter:
+		return core.InterruptPointAfter, true
+	default:
+		return "", false
+	}
+}
+
+// Gate lets one Resume or Reject at a time take the interrupt points of a run,
+// so they are resumed or rejected once when the calls race
+type Gate struct {
+	// taken is set from Take until the maintainer handled the interrupt points and calls Release
+	taken atomic.Bool
+}
+
+// Take takes the interrupt points of run, interrupted reports whether the run has any
+func (g *Gate) Take(runID string, interrupted func() bool) error {
+	if !g.taken.CompareAndSwap(false, true) {
+		return fmt.Errorf("run %s is being resumed or rejected", runID)
+	}
+	if !interrupted() {
+		g.taken.Store(false)
+		return fmt.Errorf("run %s is not interrupted", runID)
+	}
+
+	return nil
+}
+
+// Release gives back the interrupt points, e.g. after they are handled or when Resume failed to set memory
+func (g *Gate) Release() {
+	g.taken.Store(false)
+}
@@ -0,0 +1,27 @@
+package memkey
+
+import "reflect"
+
+// Bytes is a key of bytes as a map key, as a byte slice can not be a map key
+type Bytes string
+
+// Of returns memory key as a map key, ok is false for the keys which are not comparable
+func Of(key any) (any, bool) {
+	if b, ok := key.([]byte); ok {
+		return Bytes(b), true
+	}
+	if key != nil && !reflect.TypeOf(key).Comparable() {
+		return nil, false
+	}
+
+	return key, true
+}
+
+// Key returns the memory key of map key k made by Of
+func Key(k any) any {
+	if b, ok := k.(Bytes); ok {
+		return []byte(b)
+	}
+
+	return k
+}
@@ -0,0 +1,210 @@
+package memtx
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/internal/expiry"
+	"github.com/Rovanta/rmodel/internal/memkey"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// Write is a write of memory in a transaction
+type Write struct {
+	Key     any
+	Value   any
+	Deleted bool
+	// TTL of the memory, it never expires if TTL is 0
+	TTL time.Duration
+}
+
+// Writes keeps the writes of a transaction until it is committed, the last write of a key wins
+type Writes struct {
+	// writes are kept by the keys made by memkey.Of
+	writes map[any]Write
+	// order of the written keys
+	order []any
+}
+
+// Put keeps w, it reports false for a key which is not comparable, such a key is never written
+func (ws *Writes) Put(w Write) bool {
+	k, ok := memkey.Of(w.Key)
+	if !ok {
+		return false
+	}
+	if ws.writes == nil {
+		ws.writes = make(map[any]Write)
+	}
+	if _, ok := ws.writes[k]; !ok {
+		ws.order = append(ws.order, k)
+	}
+	ws.writes[k] = w
+
+	return true
+}
+
+// Get returns the last write of key
+func (ws *Writes) Get(key any) (Write, bool) {
+	k, ok := memkey.Of(key)
+	if !ok {
+		return Write{}, false
+	}
+	w, ok := ws.writes[k]
+
+	return w, ok
+}
+
+// List lists the writes in the order their keys are first written
+func (ws *Writes) List() []Write {
+	writes := make([]Write, 0, len(ws.order))
+	for _, k := range ws.order {
+		writes = append(writes, ws.writes[k])
+	}
+
+	return writes
+}
+
+// Len returns the number of the written keys
+func (ws *Writes) Len() int {
+	return len(ws.order)
+}
+
+// Reset drops the writes
+func (ws *Writes) Reset() {
+	ws.writes, ws.order = nil, nil
+}
+
+// Put sets memory of key in store, the store drops the memory by itself after ttl if it supports TTL
+func Put(store core.MemoryStore, key, value any, ttl time.Duration) error {
+	if ttlStore, ok := store.(core.TTLMemoryStore); ok && ttl > 0 {
+		return ttlStore.SetWithTTL(key, value, ttl)
+	}
+
+	return store.Set(key, value)
+}
+
+// Commit applies writes by the keys in store one by one, and keeps the expiration times of the memories in expires.
+// When the store fails a write, the applied writes are rolled back to the memories before the transaction,
+// so the writes are committed all or nothing. The caller serializes the commits on store
+func Commit(store core.MemoryStore, expires *expiry.Times, writes []Write) error {
+	applied := make([]undo, 0, len(writes))
+	for _, w := range writes {
+		u := undo{key: w.Key}
+		var err error
+		if u.value, u.existed, err = store.Get(w.Key); err != nil {
+			return rollback(store, expires, applied, errors.Wrapf(err, "get memory %v failed", w.Key))
+		}
+		u.expireAt, u.expiring = expires.At(w.Key)
+		if w.Deleted {
+			if err = store.Delete(w.Key); err != nil {
+				return rollback(store, expires, applied, errors.Wrapf(err, "delete memory %v failed", w.Key))
+			}
+		} else if err = Put(store, w.Key, w.Value, w.TTL); err != nil {
+			return rollback(store, expires, applied, errors.Wrapf(err, "set memory %v failed", w.Key))
+		}
+		applied = append(applied, u)
+		if w.TTL > 0 && !w.Deleted {
+			expires.Set(w.Key, time.Now().Add(w.TTL))
+		} else {
+			expires.Unset(w.Key)
+		}
+	}
+
+	return nil
+}
+
+// undo restores a memory written by a transaction
+type undo struct {
+	key      any
+	value    any
+	existed  bool
+	expireAt time.Time
+	expiring bool
+}
+
+// rollback restores the applied writes in reverse order, and returns err of the commit.
+// The error of a memory which can not be restored is returned with err
+func rollback(store core.MemoryStore, expires *expiry.Times, applied []undo, err error) error {
+	for i := len(applied) - 1; i >= 0; i-- {
+		u := applied[i]
+		var undoErr error
+		if !u.existed {
+			undoErr = store.Delete(u.key)
+			expires.Unset(u.key)
+		} else {
+			var ttl time.Duration
+			if u.expiring {
+				// a memory expired meanwhile is swept soon
+				if ttl = time.Until(u.expireAt); ttl <= 0 {
+					ttl = time.Millisecond
+				}
+			}
+			if undoErr = Put(store, u.key, u.value, ttl); undoErr == nil {
+				if u.expiring {
+					expires.Set(u.key, u.expireAt)
+				} else {
+					expires.Unset(u.key)
+				}
+			}
+		}
+		if undoErr != nil {
+			err = fmt.Errorf("%w, and roll back memory %v failed: %v", err, u.key, undoErr)
+		}
+	}
+
+	return err
+}
+
+// Reduce reduces value of key by the reducer of key in reducers, get reads the memory which value is reduced into.
+// A key of bytes has no reducer, as reducers are declared by comparable keys
+func Reduce(reducers map[any]core.MemoryReducer, key, value any, get func(key any) any) (any, error) {
+	k, ok := memkey.Of(key)
+	if !ok {
+		return value, nil
+	}
+	reducer, ok := reducers[k]
+	if !ok {
+		return value, nil
+	}
+	reduced, err := reducer(get(key), value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reduce memory %v failed", key)
+	}
+
+	return reduced, nil
+}
+
+// SetPairs sets the paired keys and values by set, in the order they are given
+func SetPairs(keysAndValues []any, set func(key, value any) error) error {
+	if len(keysAndValues)%2 != 0 {
+		return fmt.Errorf("key and value are not paired")
+	}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if err := set(keysAndValues[i], keysAndValues[i+1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CompareAndSwap sets key to new in a transaction run by update if its value deeply equals old,
+// old is nil for a key not existing. It returns whether the value is swapped
+func CompareAndSwap(update func(fn func(tx processor.MemoryTx) error) error, key, old, new any) (bool, error) {
+	swapped := false
+	err := update(func(tx processor.MemoryTx) error {
+		if !reflect.DeepEqual(tx.GetMemory(key), old) {
+			return nil
+		}
+		swapped = true
+		return tx.SetMemory(key, new)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return swapped, nil
+}
@@ -0,0 +1,195 @@
+package patch
+
+import (
+	"fmt"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/internal/explain"
+	"github.com/Rovanta/rmodel/internal/utils"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// Validate checks patch against topology t of a brain, activations counts the queued activations of neurons.
+// The activated, queued or interrupted neurons can not be removed
+func Validate(t explain.Topology, patch core.Patch, activations map[string]int) error {
+	neurons := make(map[string]bool, len(t.Neurons))
+	for id := range t.Neurons {
+		neurons[id] = true
+	}
+	// link ID to [from, to]
+	links := make(map[string][2]string, len(t.Links))
+	for id, l := range t.Links {
+		links[id] = [2]string{l.From, l.To}
+	}
+
+	for _, id := range patch.ListRemovedNeurons() {
+		n, ok := t.Neurons[id]
+		if !ok {
+			return errors.ErrNeuronNotFound(id)
+		}
+		if id == core.EndNeuronID {
+			return fmt.Errorf("END neuron can not be removed")
+		}
+		if n.State != core.NeuronStateInactive || activations[id] > 0 {
+			return fmt.Errorf("neuron %s is %s, it can not be removed", id, n.State)
+		}
+		delete(neurons, id)
+	}
+	for id, ends := range links {
+		if !neurons[ends[0]] && ends[0] != core.EntryLinkFrom || !neurons[ends[1]] {
+			delete(links, id)
+		}
+	}
+	for _, id := range patch.ListRemovedLinks() {
+		if _, ok := t.Links[id]; !ok {
+			return errors.ErrLinkNotFound(id)
+		}
+		delete(links, id)
+	}
+
+	for _, n := range patch.ListNeurons() {
+		if _, ok := t.Neurons[n.GetID()]; ok {
+			return fmt.Errorf("neuron %s already exists", n.GetID())
+		}
+		neurons[n.GetID()] = true
+	}
+	// END neuron is added with the first end link
+	neurons[core.EndNeuronID] = true
+	for _, l := range patch.ListLinks() {
+		if _, ok := t.Links[l.GetID()]; ok {
+			return fmt.Errorf("link %s already exists", l.GetID())
+		}
+		if !neurons[l.GetSrcNeuronID()] && l.GetSrcNeuronID() != core.EntryLinkFrom {
+			return errors.ErrNeuronNotFound(l.GetSrcNeuronID())
+		}
+		if !neurons[l.GetDestNeuronID()] {
+			return errors.ErrNeuronNotFound(l.GetDestNeuronID())
+		}
+		links[l.GetID()] = [2]string{l.GetSrcNeuronID(), l.GetDestNeuronID()}
+	}
+
+	for _, c := range patch.ListGroupChanges() {
+		if !neurons[c.NeuronID] {
+			return errors.ErrNeuronNotFound(c.NeuronID)
+		}
+		for _, id := range c.LinkIDs {
+			ends, ok := links[id]
+			if !ok {
+				return errors.ErrLinkNotFound(id)
+			}
+			switch c.Kind {
+			case core.GroupChangeAddTrigger, core.GroupChangeRemoveTrigger:
+				if ends[1] != c.NeuronID {
+					return errors.ErrInLinkNotFound(id, c.NeuronID)
+				}
+			case core.GroupChangeAddCast:
+				if ends[0] != c.NeuronID {
+					return errors.ErrOutLinkNotFound(id, c.NeuronID)
+				}
+			}
+		}
+		switch c.Kind {
+		case core.GroupChangeAddTrigger, core.GroupChangeRemoveTrigger:
+		case core.GroupChangeAddCast, core.GroupChangeRemoveCast:
+			if c.GroupName == "" || c.Kind == core.GroupChangeRemoveCast && c.GroupName == processor.DefaultCastGroupName {
+				return fmt.Errorf("invalid cast group name: %q", c.GroupName)
+			}
+		default:
+			return fmt.Errorf("unsupported group change: %s", c.Kind)
+		}
+	}
+
+	return nil
+}
+
+// Groups are the trigger groups or the cast groups of a neuron by their names, L is the link type of a brain,
+// and ID returns the ID of a link
+type Groups[L any] struct {
+	Groups map[string][]L
+	ID     func(L) string
+}
+
+// AddTrigger adds group as a trigger group, the trigger groups it contains are dropped,
+// and nothing is added if a trigger group contains it already
+func (g Groups[L]) AddTrigger(group []L) {
+	newIDs := g.IDs(group)
+	for name, links := range g.Groups {
+		ids := g.IDs(links)
+		if utils.SlicesContains(ids, newIDs) {
+			return
+		}
+		if utils.SlicesContains(newIDs, ids) {
+			delete(g.Groups, name)
+		}
+	}
+	g.Groups[utils.GenIDShort()] = group
+}
+
+// RemoveTrigger removes the trigger groups of the links in group, any of the links can trigger neuron again
+func (g Groups[L]) RemoveTrigger(group []L) {
+	ids := g.IDs(group)
+	for name, links := range g.Groups {
+		if utils.SlicesContainEqual(g.IDs(links), ids) {
+			delete(g.Groups, name)
+		}
+	}
+	for _, l := range group {
+		g.Groups[utils.GenIDShort()] = []L{l}
+	}
+}
+
+// AddCast moves the links in group from the other cast groups into the cast group of name
+func (g Groups[L]) AddCast(name string, group []L) {
+	for _, l := range group {
+		g.RemoveCastLink(g.ID(l))
+	}
+	g.Groups[name] = append(g.Groups[name], group...)
+}
+
+// RemoveCast removes the cast group of name, its links are cast by the default cast group
+func (g Groups[L]) RemoveCast(name string) {
+	links := g.Groups[name]
+	delete(g.Groups, name)
+	g.Groups[processor.DefaultCastGroupName] = append(g.Groups[processor.DefaultCastGroupName], links...)
+}
+
+// RemoveTriggerLink removes the link of linkID from the trigger groups, a group left empty is dropped
+func (g Groups[L]) RemoveTriggerLink(linkID string) {
+	for name, links := range g.Groups {
+		links = g.without(links, linkID)
+		if len(links) == 0 {
+			delete(g.Groups, name)
+			continue
+		}
+		g.Groups[name] = links
+	}
+}
+
+// RemoveCastLink removes the link of linkID from the cast groups, a group left empty can still be selected
+func (g Groups[L]) RemoveCastLink(linkID string) {
+	for name, links := range g.Groups {
+		g.Groups[name] = g.without(links, linkID)
+	}
+}
+
+// IDs returns the IDs of links
+func (g Groups[L]) IDs(links []L) []string {
+	ids := make([]string, 0, len(links))
+	for _, l := range links {
+		ids = append(ids, g.ID(l))
+	}
+
+	return ids
+}
+
+func (g Groups[L]) without(links []L, linkID string) []L {
+	ret := make([]L, 0, len(links))
+	for _, l := range links {
+		if g.ID(l) != linkID {
+			ret = append(ret, l)
+		}
+	}
+
+	return ret
+}
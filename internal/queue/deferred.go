@@ -0,0 +1,35 @@
+package queue
+
+import "sync"
+
+// Deferred keeps the items to push to a Priority queue while a lock is held, the workers of the queue may
+// wait for that lock, so the items are pushed by Flush after it is released
+type Deferred[T any] struct {
+	mu    sync.Mutex
+	items []deferredItem[T]
+}
+
+type deferredItem[T any] struct {
+	value    T
+	priority int
+}
+
+func (d *Deferred[T]) Add(value T, priority int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.items = append(d.items, deferredItem[T]{value: value, priority: priority})
+}
+
+// Flush takes the kept items and pushes them in the order they are added, it stops when push returns false
+func (d *Deferred[T]) Flush(push func(value T, priority int) bool) {
+	d.mu.Lock()
+	items := d.items
+	d.items = nil
+	d.mu.Unlock()
+
+	for _, item := range items {
+		if !push(item.value, item.priority) {
+			return
+		}
+	}
+}
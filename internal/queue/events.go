@@ -0,0 +1,91 @@
+package queue
+
+import "sync"
+
+// Events is the event queue of a brain maintainer. The maintainer publishes events too, it would wait for
+// itself on a full channel, so the events published when the channel is full are kept in overflow,
+// and they are handled after the channel is drained, in the order they are published
+type Events[E any] struct {
+	ch         chan E
+	overflow   []E
+	overflowMu sync.Mutex
+}
+
+func NewEvents[E any](capacity int) *Events[E] {
+	return &Events[E]{ch: make(chan E, capacity)}
+}
+
+// Push queues event, it never blocks
+func (q *Events[E]) Push(event E) {
+	q.overflowMu.Lock()
+	defer q.overflowMu.Unlock()
+	if len(q.overflow) == 0 {
+		select {
+		case q.ch <- event:
+			return
+		default:
+		}
+	}
+	q.overflow = append(q.overflow, event)
+}
+
+// TryPush queues event only if the channel has room, it reports whether event is queued.
+// It is used while the maintainer is flushing, when the overflow may be drained already
+func (q *Events[E]) TryPush(event E) bool {
+	select {
+	case q.ch <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run handles the queued events until stop is closed, then it handles the events left in queue and returns
+func (q *Events[E]) Run(stop <-chan struct{}, handle func(E)) {
+	for {
+		// the events in channel first, the overflow events are published after them
+		select {
+		case event := <-q.ch:
+			handle(event)
+			continue
+		default:
+		}
+		if event, ok := q.next(); ok {
+			handle(event)
+			continue
+		}
+
+		select {
+		case event := <-q.ch:
+			handle(event)
+		case <-stop:
+			for {
+				select {
+				case event := <-q.ch:
+					handle(event)
+					continue
+				default:
+				}
+				event, ok := q.next()
+				if !ok {
+					return
+				}
+				handle(event)
+			}
+		}
+	}
+}
+
+// next pops the oldest overflow event, the events in channel are older than it
+func (q *Events[E]) next() (E, bool) {
+	q.overflowMu.Lock()
+	defer q.overflowMu.Unlock()
+	if len(q.overflow) == 0 {
+		var zero E
+		return zero, false
+	}
+	event := q.overflow[0]
+	q.overflow = q.overflow[1:]
+
+	return event, true
+}
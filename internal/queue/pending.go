@@ -0,0 +1,74 @@
+package queue
+
+import "sync"
+
+// Pending tracks the maintain events and neuron activations which are queued but not handled yet,
+// a brain saves them in its checkpoint or snapshot to requeue them when it is resumed
+type Pending[E comparable] struct {
+	mu sync.Mutex
+	// pending maintain events, value is the count of same event in queue
+	events map[E]int
+	// pending neuron activations, value is the count of same neuron in queue
+	activations map[string]int
+}
+
+func (q *Pending[E]) AddEvent(event E) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.events == nil {
+		q.events = make(map[E]int)
+	}
+	q.events[event]++
+}
+
+func (q *Pending[E]) DoneEvent(event E) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.events[event] <= 1 {
+		delete(q.events, event)
+		return
+	}
+	q.events[event]--
+}
+
+func (q *Pending[E]) AddActivation(neuronID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.activations == nil {
+		q.activations = make(map[string]int)
+	}
+	q.activations[neuronID]++
+}
+
+func (q *Pending[E]) DoneActivation(neuronID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.activations[neuronID] <= 1 {
+		delete(q.activations, neuronID)
+		return
+	}
+	q.activations[neuronID]--
+}
+
+func (q *Pending[E]) Reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.events = nil
+	q.activations = nil
+}
+
+// Copy returns copies of the pending events and activations
+func (q *Pending[E]) Copy() (map[E]int, map[string]int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	events := make(map[E]int, len(q.events))
+	for e, cnt := range q.events {
+		events[e] = cnt
+	}
+	activations := make(map[string]int, len(q.activations))
+	for id, cnt := range q.activations {
+		activations[id] = cnt
+	}
+
+	return events, activations
+}
@@ -0,0 +1,131 @@
+package queue
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+	"time"
+)
+
+// Priority is a bounded priority queue, the item with the highest priority is popped first,
+// and the items with the same priority are popped in FIFO order.
+// With aging, the priority of a queued item is raised by one for every aging interval it waits,
+// so the items with low priority are not starved.
+type Priority[T any] struct {
+	mu    sync.Mutex
+	items items[T]
+	seq   uint64
+	aging time.Duration
+	start time.Time
+	// slots limits the number of queued items, ready counts the queued items
+	slots chan struct{}
+	ready chan struct{}
+}
+
+// NewPriority creates a priority queue holding at most capacity items, aging <= 0 disables aging
+func NewPriority[T any](capacity int, aging time.Duration) *Priority[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &Priority[T]{
+		aging: aging,
+		start: time.Now(),
+		slots: make(chan struct{}, capacity),
+		ready: make(chan struct{}, capacity),
+	}
+}
+
+// Push pushes item with priority, it blocks when the queue is full until an item is popped or stop is closed.
+// It returns false if stop is closed before item is pushed.
+func (q *Priority[T]) Push(item T, priority int, stop <-chan struct{}) bool {
+	select {
+	case q.slots <- struct{}{}:
+	case <-stop:
+		return false
+	}
+
+	q.mu.Lock()
+	heap.Push(&q.items, entry[T]{value: item, rank: q.rank(priority), seq: q.seq})
+	q.seq++
+	q.mu.Unlock()
+	q.ready <- struct{}{}
+
+	return true
+}
+
+// Pop pops the item with the highest priority, it blocks until an item is pushed or stop is closed.
+// It returns false if stop is closed before an item is popped.
+func (q *Priority[T]) Pop(stop <-chan struct{}) (T, bool) {
+	select {
+	case <-q.ready:
+	case <-stop:
+		var zero T
+		return zero, false
+	}
+
+	q.mu.Lock()
+	e := heap.Pop(&q.items).(entry[T])
+	q.mu.Unlock()
+	<-q.slots
+
+	return e.value, true
+}
+
+// Len returns the number of queued items
+func (q *Priority[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.items)
+}
+
+// rank is the order of an item pushed now. All queued items age at the same rate, so an item raised by one
+// every aging interval keeps its order: priority*aging - waited since start, which is fixed when it is pushed.
+func (q *Priority[T]) rank(priority int) int64 {
+	if q.aging <= 0 {
+		return int64(priority)
+	}
+
+	r := float64(priority)*float64(q.aging) - float64(time.Since(q.start))
+	switch {
+	case r > math.MaxInt64:
+		return math.MaxInt64
+	case r < math.MinInt64:
+		return math.MinInt64
+	default:
+		return int64(r)
+	}
+}
+
+type entry[T any] struct {
+	value T
+	rank  int64
+	seq   uint64
+}
+
+// items implements heap.Interface
+type items[T any] []entry[T]
+
+func (h items[T]) Len() int { return len(h) }
+
+func (h items[T]) Less(i, j int) bool {
+	if h[i].rank != h[j].rank {
+		return h[i].rank > h[j].rank
+	}
+
+	return h[i].seq < h[j].seq
+}
+
+func (h items[T]) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *items[T]) Push(x any) { *h = append(*h, x.(entry[T])) }
+
+func (h *items[T]) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	*h = old[:n-1]
+
+	return e
+}
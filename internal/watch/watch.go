@@ -0,0 +1,139 @@
+package watch
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Rovanta/rmodel/internal/memkey"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// maxQueued is the most changes queued for a watcher
+const maxQueued = 1024
+
+// Hub delivers memory changes to the watchers. A change is queued for every watcher and sent by its own goroutine,
+// so a slow watcher does not block the writer. When maxQueued changes are queued for a watcher, the oldest change
+// is dropped for the new one, and the number of dropped changes is kept in Dropped of the change after them.
+type Hub struct {
+	mu       sync.Mutex
+	watchers map[*watcher]struct{}
+}
+
+type watcher struct {
+	// keys to watch, all keys are watched if it is empty
+	keys map[any]struct{}
+	ch   chan processor.MemoryChange
+
+	mu     sync.Mutex
+	queued []processor.MemoryChange
+	signal chan struct{}
+}
+
+// Watch returns a channel of changes of keys, all keys are watched if keys is empty.
+// The channel is closed when ctx is done.
+func (h *Hub) Watch(ctx context.Context, keys ...any) <-chan processor.MemoryChange {
+	w := &watcher{
+		keys:   make(map[any]struct{}, len(keys)),
+		ch:     make(chan processor.MemoryChange),
+		signal: make(chan struct{}, 1),
+	}
+	for _, key := range keys {
+		if k, ok := memkey.Of(key); ok {
+			w.keys[k] = struct{}{}
+		}
+	}
+
+	h.mu.Lock()
+	if h.watchers == nil {
+		h.watchers = make(map[*watcher]struct{})
+	}
+	h.watchers[w] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		defer close(w.ch)
+		defer func() {
+			h.mu.Lock()
+			delete(h.watchers, w)
+			h.mu.Unlock()
+		}()
+		w.run(ctx)
+	}()
+
+	return w.ch
+}
+
+// Watching indicates whether any watcher watches key, the old value is read only if it is watched
+func (h *Hub) Watching(key any) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for w := range h.watchers {
+		if w.match(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Publish sends change to the watchers of its key, a change with Cleared is sent to all watchers
+func (h *Hub) Publish(change processor.MemoryChange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for w := range h.watchers {
+		if change.Cleared || w.match(change.Key) {
+			w.push(change)
+		}
+	}
+}
+
+func (w *watcher) match(key any) bool {
+	if len(w.keys) == 0 {
+		return true
+	}
+	k, ok := memkey.Of(key)
+	if !ok {
+		return false
+	}
+	_, ok = w.keys[k]
+
+	return ok
+}
+
+func (w *watcher) push(change processor.MemoryChange) {
+	w.mu.Lock()
+	w.queued = append(w.queued, change)
+	if len(w.queued) > maxQueued {
+		w.queued[1].Dropped += w.queued[0].Dropped + 1
+		w.queued[0] = processor.MemoryChange{}
+		w.queued = w.queued[1:]
+	}
+	w.mu.Unlock()
+
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.signal:
+		}
+
+		w.mu.Lock()
+		changes := w.queued
+		w.queued = nil
+		w.mu.Unlock()
+		for _, change := range changes {
+			select {
+			case w.ch <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -60,6 +60,17 @@ func (l *link) GetLabels() map[string]string {
 	return l.labels
 }
 
+// applyOptions applies opts to the new link, the ID set by core.WithLinkID is taken here only
+func (l *link) applyOptions(opts []core.LinkOption) {
+	for _, opt := range opts {
+		if o, ok := opt.(interface{ LinkID() string }); ok {
+			l.id = o.LinkID()
+			continue
+		}
+		opt.Apply(l)
+	}
+}
+
 func (l *link) SetLabels(labels map[string]string) {
 	l.labels = labels
 }
@@ -0,0 +1,272 @@
+package memstore
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/memkey"
+	"github.com/dgraph-io/ristretto"
+	"github.com/dgraph-io/ristretto/z"
+)
+
+// Cache is a MemoryStore keeping memories in a ristretto cache. The cost of a memory is the estimated size
+// of its value in bytes, and a memory may be evicted when the total cost exceeds maxCost,
+// or dropped by the admission policy. The dropped memories are reported to the func set by OnEvict.
+// Keys are strings, integers or byte slices, the types the cache can hash.
+type Cache struct {
+	cache *ristretto.Cache
+	// keys of memories by the keys made by mapKey, the cache can not be iterated
+	keys   map[any]struct{}
+	keysMu sync.Mutex
+	// clearing is set during Clear, the cache calls OnEvict for the cleared memories
+	clearing atomic.Bool
+	onEvict  atomic.Value
+}
+
+var (
+	_ core.EvictingMemoryStore = (*Cache)(nil)
+	_ core.TTLMemoryStore      = (*Cache)(nil)
+)
+
+// cacheEntry is the value kept in the cache, the cache only knows the hashed keys
+type cacheEntry struct {
+	key   any
+	value any
+}
+
+// NewCache creates a Cache store, numCounters is the number of keys to track frequency of,
+// 10 times of the expected memories, and maxCost is the maximum total cost in bytes
+func NewCache(numCounters, maxCost int64) (*Cache, error) {
+	s := &Cache{keys: make(map[any]struct{})}
+	lost := func(item *ristretto.Item) {
+		if e, ok := item.Value.(cacheEntry); ok && !s.clearing.Load() {
+			s.evicted(e.key, e.value)
+		}
+	}
+	cache, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters:        numCounters,
+		MaxCost:            maxCost,
+		BufferItems:        64, // number of keys per Get buffer.
+		IgnoreInternalCost: true,
+		KeyToHash:          cacheKeyToHash,
+		OnEvict:            lost,
+		OnReject:           lost,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.cache = cache
+
+	return s, nil
+}
+
+func (s *Cache) OnEvict(fn func(key, value any)) {
+	s.onEvict.Store(fn)
+}
+
+func (s *Cache) Get(key any) (any, bool, error) {
+	k, err := cacheKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	v, ok := s.cache.Get(k)
+	if !ok {
+		return nil, false, nil
+	}
+
+	return v.(cacheEntry).value, true, nil
+}
+
+func (s *Cache) Set(key, value any) error {
+	return s.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL sets memory of key which expires after ttl, it never expires if ttl is 0.
+// The expired memory is not got any more, and it is reported to OnEvict when the cache cleans it up.
+func (s *Cache) SetWithTTL(key, value any, ttl time.Duration) error {
+	k, err := cacheKey(key)
+	if err != nil {
+		return err
+	}
+
+	// the key is indexed before the cache may reject it
+	s.keysMu.Lock()
+	s.keys[k] = struct{}{}
+	s.keysMu.Unlock()
+	if !s.cache.SetWithTTL(k, cacheEntry{key: key, value: value}, memoryCost(value), ttl) {
+		// dropped by contention of the set buffer
+		s.evicted(key, value)
+		return nil
+	}
+	s.cache.Wait()
+
+	return nil
+}
+
+func (s *Cache) Delete(key any) error {
+	k, err := cacheKey(key)
+	if err != nil {
+		return err
+	}
+
+	s.cache.Del(k)
+	s.keysMu.Lock()
+	delete(s.keys, k)
+	s.keysMu.Unlock()
+
+	return nil
+}
+
+func (s *Cache) Clear() error {
+	s.clearing.Store(true)
+	defer s.clearing.Store(false)
+	s.cache.Clear()
+	s.keysMu.Lock()
+	s.keys = make(map[any]struct{})
+	s.keysMu.Unlock()
+
+	return nil
+}
+
+func (s *Cache) Keys() ([]any, error) {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+	keys := make([]any, 0, len(s.keys))
+	for k := range s.keys {
+		keys = append(keys, memkey.Key(k))
+	}
+
+	return keys, nil
+}
+
+func (s *Cache) Snapshot() (map[any]any, error) {
+	s.keysMu.Lock()
+	keys := make([]any, 0, len(s.keys))
+	for k := range s.keys {
+		keys = append(keys, k)
+	}
+	s.keysMu.Unlock()
+	m := make(map[any]any, len(keys))
+	for _, k := range keys {
+		if v, ok := s.cache.Get(k); ok {
+			m[snapshotKey(memkey.Key(k))] = v.(cacheEntry).value
+		}
+	}
+
+	return m, nil
+}
+
+func (s *Cache) Close() error {
+	s.cache.Close()
+
+	return nil
+}
+
+func (s *Cache) evicted(key, value any) {
+	k, _ := memkey.Of(key)
+	s.keysMu.Lock()
+	delete(s.keys, k)
+	s.keysMu.Unlock()
+	if fn, ok := s.onEvict.Load().(func(key, value any)); ok {
+		fn(key, value)
+	}
+}
+
+// cacheKey returns key as the key in the cache, checking whether the cache can hash it
+func cacheKey(key any) (any, error) {
+	switch key.(type) {
+	case string, int, int32, int64, uint32, uint64, uint8, []byte:
+		return mapKey(key)
+	default:
+		return nil, fmt.Errorf("unsupported key type %T of memory cache", key)
+	}
+}
+
+// bytesSeed sets apart the hashes of a key of bytes from the ones of the string key of the same bytes
+const bytesSeed = 0x9e3779b97f4a7c15
+
+// cacheKeyToHash hashes the keys made by cacheKey
+func cacheKeyToHash(key any) (uint64, uint64) {
+	if b, ok := key.(memkey.Bytes); ok {
+		h, conflict := z.KeyToHash(string(b))
+		return h ^ bytesSeed, conflict ^ bytesSeed
+	}
+
+	return z.KeyToHash(key)
+}
+
+// memoryCost estimates the size of v in bytes, the memory shared by pointers is counted once
+func memoryCost(v any) int64 {
+	if v == nil {
+		return 1
+	}
+	visited := make(map[uintptr]struct{})
+	rv := reflect.ValueOf(v)
+
+	return int64(rv.Type().Size()) + indirectCost(rv, visited)
+}
+
+// indirectCost estimates the size of memory referenced by v, the size of v itself is not included
+func indirectCost(v reflect.Value, visited map[uintptr]struct{}) int64 {
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Ptr:
+		if v.IsNil() || seen(v.Pointer(), visited) {
+			return 0
+		}
+		return int64(v.Type().Elem().Size()) + indirectCost(v.Elem(), visited)
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return int64(v.Elem().Type().Size()) + indirectCost(v.Elem(), visited)
+	case reflect.Slice:
+		if v.IsNil() || seen(v.Pointer(), visited) {
+			return 0
+		}
+		cost := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			cost += indirectCost(v.Index(i), visited)
+		}
+		return cost
+	case reflect.Array:
+		var cost int64
+		for i := 0; i < v.Len(); i++ {
+			cost += indirectCost(v.Index(i), visited)
+		}
+		return cost
+	case reflect.Map:
+		if v.IsNil() || seen(v.Pointer(), visited) {
+			return 0
+		}
+		cost := int64(v.Len()) * int64(v.Type().Key().Size()+v.Type().Elem().Size())
+		iter := v.MapRange()
+		for iter.Next() {
+			cost += indirectCost(iter.Key(), visited) + indirectCost(iter.Value(), visited)
+		}
+		return cost
+	case reflect.Struct:
+		var cost int64
+		for i := 0; i < v.NumField(); i++ {
+			cost += indirectCost(v.Field(i), visited)
+		}
+		return cost
+	default:
+		return 0
+	}
+}
+
+func seen(p uintptr, visited map[uintptr]struct{}) bool {
+	if _, ok := visited[p]; ok {
+		return true
+	}
+	visited[p] = struct{}{}
+
+	return false
+}
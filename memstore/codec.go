@@ -0,0 +1,123 @@
+package memstore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// type tags of values, the same as the ones of BrainLite memory, so the values can be read by Python processors
+const (
+	typeString = "string"
+	typeInt    = "int"
+	typeFloat  = "float"
+	typeBool   = "bool"
+	typeJSON   = "json"
+	// typeBytes tags a key of bytes, it is kept in base64
+	typeBytes = "bytes"
+)
+
+// encodeValue encodes value in JSON with its type tag
+func encodeValue(value any) ([]byte, string, error) {
+	var valueType string
+	switch value.(type) {
+	case string:
+		valueType = typeString
+	case int, int32, int64, uint32:
+		valueType = typeInt
+	case float64:
+		valueType = typeFloat
+	case bool:
+		valueType = typeBool
+	default:
+		valueType = typeJSON
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to serialize value: %v", err)
+	}
+
+	return data, valueType, nil
+}
+
+// decodeValue decodes value encoded by encodeValue, an int in the range of int32 is int, otherwise int64
+func decodeValue(data []byte, valueType string) (any, error) {
+	var value any
+	var err error
+	switch valueType {
+	case typeString:
+		var s string
+		err = json.Unmarshal(data, &s)
+		value = s
+	case typeInt:
+		var i int64
+		err = json.Unmarshal(data, &i)
+		if i >= math.MinInt32 && i <= math.MaxInt32 {
+			value = int(i)
+		} else {
+			value = i
+		}
+	case typeFloat:
+		var f float64
+		err = json.Unmarshal(data, &f)
+		value = f
+	case typeBool:
+		var b bool
+		err = json.Unmarshal(data, &b)
+		value = b
+	case typeJSON:
+		err = json.Unmarshal(data, &value)
+	default:
+		return nil, fmt.Errorf("unknown value type %q", valueType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse value: %v", err)
+	}
+
+	return value, nil
+}
+
+// encodeKey encodes key as "<type>:<key>", keys are strings, integers, floats, bools or byte slices in base64
+func encodeKey(key any) (string, error) {
+	switch k := key.(type) {
+	case string:
+		return typeString + ":" + k, nil
+	case int, int32, int64, uint32:
+		return fmt.Sprintf("%s:%d", typeInt, k), nil
+	case float64:
+		return typeFloat + ":" + strconv.FormatFloat(k, 'g', -1, 64), nil
+	case bool:
+		return typeBool + ":" + strconv.FormatBool(k), nil
+	case []byte:
+		return typeBytes + ":" + base64.StdEncoding.EncodeToString(k), nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// decodeKey decodes key encoded by encodeKey, integers are decoded as values
+func decodeKey(encoded string) (any, error) {
+	for _, valueType := range []string{typeString, typeInt, typeFloat, typeBool, typeBytes} {
+		if len(encoded) <= len(valueType) || encoded[:len(valueType)+1] != valueType+":" {
+			continue
+		}
+		k := encoded[len(valueType)+1:]
+		switch valueType {
+		case typeString:
+			return k, nil
+		case typeBool:
+			return strconv.ParseBool(k)
+		case typeFloat:
+			return strconv.ParseFloat(k, 64)
+		case typeBytes:
+			return base64.StdEncoding.DecodeString(k)
+		default:
+			return decodeValue([]byte(k), typeInt)
+		}
+	}
+
+	return nil, fmt.Errorf("invalid key %q", encoded)
+}
@@ -0,0 +1,95 @@
+package memstore
+
+import (
+	"sync"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/memkey"
+)
+
+// Map is a MemoryStore keeping memories in a map, a memory is kept until it is deleted.
+// Keys are comparable values or byte slices. It is the default store of BrainLocal.
+type Map struct {
+	mu sync.RWMutex
+	m  map[any]any
+}
+
+var _ core.MemoryStore = (*Map)(nil)
+
+// NewMap creates a Map store
+func NewMap() *Map {
+	return &Map{m: make(map[any]any)}
+}
+
+func (s *Map) Get(key any) (any, bool, error) {
+	k, err := mapKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.m[k]
+
+	return v, ok, nil
+}
+
+func (s *Map) Set(key, value any) error {
+	k, err := mapKey(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[k] = value
+
+	return nil
+}
+
+func (s *Map) Delete(key any) error {
+	k, err := mapKey(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, k)
+
+	return nil
+}
+
+func (s *Map) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m = make(map[any]any)
+
+	return nil
+}
+
+func (s *Map) Keys() ([]any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]any, 0, len(s.m))
+	for k := range s.m {
+		keys = append(keys, memkey.Key(k))
+	}
+
+	return keys, nil
+}
+
+func (s *Map) Snapshot() (map[any]any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m := make(map[any]any, len(s.m))
+	for k, v := range s.m {
+		m[snapshotKey(memkey.Key(k))] = v
+	}
+
+	return m, nil
+}
+
+func (s *Map) Close() error {
+	return nil
+}
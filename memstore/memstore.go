@@ -0,0 +1,27 @@
+// Package memstore provides the built-in implementations of core.MemoryStore.
+package memstore
+
+import (
+	"fmt"
+
+	"github.com/Rovanta/rmodel/internal/memkey"
+)
+
+// mapKey returns key as a map key, a key of bytes is tagged apart from the string key of the same bytes
+func mapKey(key any) (any, error) {
+	k, ok := memkey.Of(key)
+	if !ok {
+		return nil, fmt.Errorf("key type %T is not comparable", key)
+	}
+
+	return k, nil
+}
+
+// snapshotKey is key in a snapshot, a key of bytes is a string as a byte slice can not be a map key
+func snapshotKey(key any) any {
+	if b, ok := key.([]byte); ok {
+		return string(b)
+	}
+
+	return key
+}
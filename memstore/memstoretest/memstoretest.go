@@ -0,0 +1,239 @@
+// Package memstoretest provides the conformance tests of core.MemoryStore, every store should pass them.
+package memstoretest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/Rovanta/rmodel/core"
+)
+
+// TestMemoryStore runs the conformance tests against the stores created by newStore,
+// a new empty store is created for every test, and it is closed when the test is done.
+// The values are the ones every store can keep, including the stores encoding values in JSON.
+func TestMemoryStore(t *testing.T, newStore func(t *testing.T) core.MemoryStore) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s core.MemoryStore)
+	}{
+		{"GetMissing", testGetMissing},
+		{"SetGet", testSetGet},
+		{"Overwrite", testOverwrite},
+		{"Delete", testDelete},
+		{"Clear", testClear},
+		{"Keys", testKeys},
+		{"Snapshot", testSnapshot},
+		{"BytesKey", testBytesKey},
+		{"Concurrent", testConcurrent},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			defer func() {
+				if err := s.Close(); err != nil {
+					t.Errorf("close store error: %s", err)
+				}
+			}()
+			tt.fn(t, s)
+		})
+	}
+}
+
+var values = map[any]any{
+	"string": "hello",
+	"int":    42,
+	"float":  3.5,
+	"bool":   true,
+	"slice":  []any{"a", true},
+	"map":    map[string]any{"k": "v"},
+	7:        "int key",
+}
+
+func testGetMissing(t *testing.T, s core.MemoryStore) {
+	v, ok, err := s.Get("missing")
+	if err != nil || ok || v != nil {
+		t.Fatalf("expected missing memory, got %v, %v, %v", v, ok, err)
+	}
+}
+
+func testSetGet(t *testing.T, s core.MemoryStore) {
+	for k, v := range values {
+		if err := s.Set(k, v); err != nil {
+			t.Fatalf("set %v error: %s", k, err)
+		}
+	}
+	for k, want := range values {
+		got, ok, err := s.Get(k)
+		if err != nil || !ok {
+			t.Fatalf("get %v: ok %v, error %v", k, ok, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("get %v: expected %#v, got %#v", k, want, got)
+		}
+	}
+}
+
+func testOverwrite(t *testing.T, s core.MemoryStore) {
+	mustSet(t, s, "key", "old")
+	mustSet(t, s, "key", "new")
+	if v, _, _ := s.Get("key"); v != "new" {
+		t.Fatalf("expected new value, got %v", v)
+	}
+}
+
+func testDelete(t *testing.T, s core.MemoryStore) {
+	mustSet(t, s, "key", "value")
+	if err := s.Delete("key"); err != nil {
+		t.Fatalf("delete error: %s", err)
+	}
+	if _, ok, _ := s.Get("key"); ok {
+		t.Fatal("expected memory deleted")
+	}
+	if err := s.Delete("missing"); err != nil {
+		t.Fatalf("delete missing memory error: %s", err)
+	}
+}
+
+func testClear(t *testing.T, s core.MemoryStore) {
+	mustSet(t, s, "a", 1)
+	mustSet(t, s, "b", 2)
+	if err := s.Clear(); err != nil {
+		t.Fatalf("clear error: %s", err)
+	}
+	if keys, _ := s.Keys(); len(keys) != 0 {
+		t.Fatalf("expected no keys after clear, got %v", keys)
+	}
+	if _, ok, _ := s.Get("a"); ok {
+		t.Fatal("expected memory cleared")
+	}
+}
+
+func testKeys(t *testing.T, s core.MemoryStore) {
+	mustSet(t, s, "a", 1)
+	mustSet(t, s, "b", 2)
+	mustSet(t, s, 3, 3)
+	mustSet(t, s, "a", 4)
+	keys, err := s.Keys()
+	if err != nil {
+		t.Fatalf("keys error: %s", err)
+	}
+	if got, want := sortedKeys(keys), []string{"3", "a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected keys %v, got %v", want, got)
+	}
+	for _, k := range keys {
+		if _, ok, _ := s.Get(k); !ok {
+			t.Fatalf("listed key %#v is not found", k)
+		}
+	}
+}
+
+func testSnapshot(t *testing.T, s core.MemoryStore) {
+	for k, v := range values {
+		mustSet(t, s, k, v)
+	}
+	snap, err := s.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot error: %s", err)
+	}
+	if !reflect.DeepEqual(snap, values) {
+		t.Fatalf("expected snapshot %#v, got %#v", values, snap)
+	}
+
+	// snapshot is a copy
+	snap["string"] = "changed"
+	if v, _, _ := s.Get("string"); v != "hello" {
+		t.Fatalf("expected memory not changed by snapshot, got %v", v)
+	}
+}
+
+func testBytesKey(t *testing.T, s core.MemoryStore) {
+	mustSet(t, s, []byte("token"), "bytes")
+	mustSet(t, s, "token", "string")
+	if v, ok, err := s.Get([]byte("token")); err != nil || !ok || v != "bytes" {
+		t.Fatalf("get bytes key: expected bytes, got %v, %v, %v", v, ok, err)
+	}
+	if v, _, _ := s.Get("token"); v != "string" {
+		t.Fatalf("expected string key apart from bytes key, got %v", v)
+	}
+
+	keys, err := s.Keys()
+	if err != nil {
+		t.Fatalf("keys error: %s", err)
+	}
+	found := false
+	for _, k := range keys {
+		found = found || reflect.DeepEqual(k, []byte("token"))
+	}
+	if !found || len(keys) != 2 {
+		t.Fatalf("expected keys with bytes key, got %#v", keys)
+	}
+
+	if err = s.Delete([]byte("token")); err != nil {
+		t.Fatalf("delete bytes key error: %s", err)
+	}
+	if _, ok, _ := s.Get([]byte("token")); ok {
+		t.Fatal("expected memory of bytes key deleted")
+	}
+	if _, ok, _ := s.Get("token"); !ok {
+		t.Fatal("expected memory of string key kept")
+	}
+
+	// a key of bytes is a string in snapshot
+	mustSet(t, s, []byte("raw"), 1)
+	snap, err := s.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot error: %s", err)
+	}
+	if expected := map[any]any{"token": "string", "raw": 1}; !reflect.DeepEqual(snap, expected) {
+		t.Fatalf("expected snapshot %#v, got %#v", expected, snap)
+	}
+}
+
+func testConcurrent(t *testing.T, s core.MemoryStore) {
+	const writers, n = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		w := w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				k := fmt.Sprintf("w%d-%d", w, i)
+				if err := s.Set(k, i); err != nil {
+					t.Errorf("set %s error: %s", k, err)
+					return
+				}
+				if v, ok, err := s.Get(k); err != nil || !ok || v != i {
+					t.Errorf("get %s: expected %d, got %v, %v, %v", k, i, v, ok, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if keys, _ := s.Keys(); len(keys) != writers*n {
+		t.Fatalf("expected %d keys, got %d", writers*n, len(keys))
+	}
+}
+
+func mustSet(t *testing.T, s core.MemoryStore, key, value any) {
+	t.Helper()
+	if err := s.Set(key, value); err != nil {
+		t.Fatalf("set %v error: %s", key, err)
+	}
+}
+
+func sortedKeys(keys []any) []string {
+	ret := make([]string, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, fmt.Sprint(k))
+	}
+	sort.Strings(ret)
+
+	return ret
+}
@@ -0,0 +1,346 @@
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a MemoryStore keeping memories in Redis, so brains in many processes can share them.
+// A memory is a Redis hash with the fields value and type, value is in JSON and type is one of the type tags
+// of BrainLite memory, string, int, float, bool or json. It is kept at "rmodel:<brainID>:memory:<type>:<key>",
+// and keys are strings, integers, floats, bools or byte slices. The changes are published to "rmodel:<brainID>:changes",
+// and the changes made by the other stores are reported to the func set by OnChange.
+type Redis struct {
+	client redis.UniversalClient
+	// prefix of the Redis keys of brain
+	prefix string
+	// ttl of memories, memories never expire if it is 0
+	ttl time.Duration
+	// id of store, the changes published by the store itself are not reported
+	id string
+
+	mu       sync.Mutex
+	onChange func(change core.MemoryStoreChange)
+	pubsub   *redis.PubSub
+}
+
+var (
+	_ core.SharedMemoryStore = (*Redis)(nil)
+	_ core.TTLMemoryStore    = (*Redis)(nil)
+)
+
+// RedisOption configures a Redis store.
+type RedisOption interface {
+	apply(s *Redis)
+}
+
+// redisOptionFunc wraps a func, so it satisfies the RedisOption interface.
+type redisOptionFunc func(*Redis)
+
+func (f redisOptionFunc) apply(s *Redis) {
+	f(s)
+}
+
+// WithRedisTTL sets the TTL of memories, a memory expires ttl after it is set. Memories never expire by default
+func WithRedisTTL(ttl time.Duration) RedisOption {
+	return redisOptionFunc(func(s *Redis) {
+		s.ttl = ttl
+	})
+}
+
+// redisChange is the message of a change published to the changes channel
+type redisChange struct {
+	Source string `json:"source"`
+	// Op is set, delete or clear
+	Op    string `json:"op"`
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+// NewRedis creates a Redis store of brain brainID with client, the client is not closed by Close of store
+func NewRedis(client redis.UniversalClient, brainID string, opts ...RedisOption) *Redis {
+	s := &Redis{
+		client: client,
+		prefix: fmt.Sprintf("rmodel:%s:", brainID),
+		id:     utils.GenID(),
+	}
+	for _, opt := range opts {
+		opt.apply(s)
+	}
+
+	return s
+}
+
+func (s *Redis) Get(key any) (any, bool, error) {
+	redisKey, err := s.memoryKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return s.get(context.Background(), redisKey)
+}
+
+func (s *Redis) Set(key, value any) error {
+	return s.SetWithTTL(key, value, s.ttl)
+}
+
+// SetWithTTL sets memory of key which expires after ttl, it never expires if ttl is 0
+func (s *Redis) SetWithTTL(key, value any, ttl time.Duration) error {
+	redisKey, err := s.memoryKey(key)
+	if err != nil {
+		return err
+	}
+	data, valueType, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisKey, "value", data, "type", valueType)
+		if ttl > 0 {
+			pipe.PExpire(ctx, redisKey, ttl)
+		} else {
+			pipe.Persist(ctx, redisKey)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("set memory %v in redis failed: %v", key, err)
+	}
+
+	return s.publish(ctx, redisChange{Op: "set", Key: strings.TrimPrefix(redisKey, s.memoryPrefix()), Value: string(data), Type: valueType})
+}
+
+func (s *Redis) Delete(key any) error {
+	redisKey, err := s.memoryKey(key)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if err = s.client.Del(ctx, redisKey).Err(); err != nil {
+		return fmt.Errorf("delete memory %v in redis failed: %v", key, err)
+	}
+
+	return s.publish(ctx, redisChange{Op: "delete", Key: strings.TrimPrefix(redisKey, s.memoryPrefix())})
+}
+
+func (s *Redis) Clear() error {
+	ctx := context.Background()
+	redisKeys, err := s.scan(ctx)
+	if err != nil {
+		return err
+	}
+	if len(redisKeys) > 0 {
+		if err = s.client.Del(ctx, redisKeys...).Err(); err != nil {
+			return fmt.Errorf("clear memory in redis failed: %v", err)
+		}
+	}
+
+	return s.publish(ctx, redisChange{Op: "clear"})
+}
+
+func (s *Redis) Keys() ([]any, error) {
+	redisKeys, err := s.scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]any, 0, len(redisKeys))
+	for _, redisKey := range redisKeys {
+		key, err := decodeKey(strings.TrimPrefix(redisKey, s.memoryPrefix()))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (s *Redis) Snapshot() (map[any]any, error) {
+	ctx := context.Background()
+	redisKeys, err := s.scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[any]any, len(redisKeys))
+	for _, redisKey := range redisKeys {
+		key, err := decodeKey(strings.TrimPrefix(redisKey, s.memoryPrefix()))
+		if err != nil {
+			return nil, err
+		}
+		value, ok, err := s.get(ctx, redisKey)
+		if err != nil {
+			return nil, err
+		}
+		// it may expire or be deleted after scan
+		if ok {
+			m[snapshotKey(key)] = value
+		}
+	}
+
+	return m, nil
+}
+
+// Close stops reporting the changes, the client is not closed
+func (s *Redis) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pubsub == nil {
+		return nil
+	}
+	err := s.pubsub.Close()
+	s.pubsub = nil
+
+	return err
+}
+
+// OnChange sets fn, and subscribes the changes made by the other stores of brain
+func (s *Redis) OnChange(fn func(change core.MemoryStoreChange)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = fn
+	if s.pubsub != nil {
+		return
+	}
+
+	ctx := context.Background()
+	s.pubsub = s.client.Subscribe(ctx, s.changesChannel())
+	// wait for the subscription, the changes after OnChange returns are not missed
+	if _, err := s.pubsub.Receive(ctx); err != nil {
+		_ = s.pubsub.Close()
+		s.pubsub = nil
+		return
+	}
+	go s.receiveChanges(s.pubsub.Channel())
+}
+
+func (s *Redis) receiveChanges(messages <-chan *redis.Message) {
+	for msg := range messages {
+		var c redisChange
+		if err := json.Unmarshal([]byte(msg.Payload), &c); err != nil || c.Source == s.id {
+			continue
+		}
+
+		change := core.MemoryStoreChange{}
+		switch c.Op {
+		case "clear":
+			change.Cleared = true
+		case "delete":
+			change.Deleted = true
+			key, err := decodeKey(c.Key)
+			if err != nil {
+				continue
+			}
+			change.Key = key
+		case "set":
+			key, err := decodeKey(c.Key)
+			if err != nil {
+				continue
+			}
+			value, err := decodeValue([]byte(c.Value), c.Type)
+			if err != nil {
+				continue
+			}
+			change.Key, change.Value = key, value
+		default:
+			continue
+		}
+
+		s.mu.Lock()
+		fn := s.onChange
+		s.mu.Unlock()
+		if fn != nil {
+			fn(change)
+		}
+	}
+}
+
+func (s *Redis) get(ctx context.Context, redisKey string) (any, bool, error) {
+	fields, err := s.client.HMGet(ctx, redisKey, "value", "type").Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("get memory in redis failed: %v", err)
+	}
+	data, _ := fields[0].(string)
+	valueType, ok := fields[1].(string)
+	if !ok {
+		return nil, false, nil
+	}
+
+	value, err := decodeValue([]byte(data), valueType)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+// scan lists the Redis keys of memories of brain
+func (s *Redis) scan(ctx context.Context) ([]string, error) {
+	redisKeys := make([]string, 0)
+	iter := s.client.Scan(ctx, 0, escapePattern(s.memoryPrefix())+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		redisKeys = append(redisKeys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("scan memory in redis failed: %v", err)
+	}
+
+	return redisKeys, nil
+}
+
+func (s *Redis) publish(ctx context.Context, c redisChange) error {
+	c.Source = s.id
+	msg, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err = s.client.Publish(ctx, s.changesChannel(), msg).Err(); err != nil {
+		return fmt.Errorf("publish memory change failed: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Redis) memoryKey(key any) (string, error) {
+	encoded, err := encodeKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return s.memoryPrefix() + encoded, nil
+}
+
+func (s *Redis) memoryPrefix() string {
+	return s.prefix + "memory:"
+}
+
+func (s *Redis) changesChannel() string {
+	return s.prefix + "changes"
+}
+
+// escapePattern escapes the special characters of a Redis glob-style pattern
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
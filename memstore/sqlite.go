@@ -0,0 +1,184 @@
+package memstore
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Rovanta/rmodel/core"
+)
+
+// SQLite is a MemoryStore keeping memories in the table memstore of a SQLite database, so they survive restarts.
+// A row holds the key as "<type>:<key>", the value in JSON and its type tag, one of the type tags of BrainLite
+// memory, string, int, float, bool or json. Keys are strings, integers, floats, bools or byte slices.
+// Rows of brains are apart by brain ID, so brains can share a database. The table is not the one of BrainLite memory.
+type SQLite struct {
+	db      *sql.DB
+	brainID string
+}
+
+var _ core.TTLMemoryStore = (*SQLite)(nil)
+
+// NewSQLite creates a SQLite store of brain brainID in db, the table is created if it does not exist.
+// db is opened with a SQLite driver, e.g. github.com/mattn/go-sqlite3, and it is not closed by Close of store
+func NewSQLite(db *sql.DB, brainID string) (*SQLite, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS memstore (
+		brain_id TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		type TEXT NOT NULL,
+		expires_at INTEGER,
+		PRIMARY KEY (brain_id, key)
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("create memstore table failed: %v", err)
+	}
+
+	return &SQLite{db: db, brainID: brainID}, nil
+}
+
+func (s *SQLite) Get(key any) (any, bool, error) {
+	k, err := encodeKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var data, valueType string
+	err = s.db.QueryRow(`SELECT value, type FROM memstore WHERE brain_id = ? AND key = ? AND `+sqliteLive,
+		s.brainID, k, time.Now().UnixNano()).Scan(&data, &valueType)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("get memory %v in sqlite failed: %v", key, err)
+	}
+
+	value, err := decodeValue([]byte(data), valueType)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+func (s *SQLite) Set(key, value any) error {
+	return s.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL sets memory of key which expires after ttl, it never expires if ttl is 0
+func (s *SQLite) SetWithTTL(key, value any, ttl time.Duration) error {
+	k, err := encodeKey(key)
+	if err != nil {
+		return err
+	}
+	data, valueType, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	var expiresAt sql.NullInt64
+	if ttl > 0 {
+		expiresAt = sql.NullInt64{Int64: time.Now().Add(ttl).UnixNano(), Valid: true}
+	}
+
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO memstore (brain_id, key, value, type, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		s.brainID, k, string(data), valueType, expiresAt)
+	if err != nil {
+		return fmt.Errorf("set memory %v in sqlite failed: %v", key, err)
+	}
+
+	return nil
+}
+
+func (s *SQLite) Delete(key any) error {
+	k, err := encodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err = s.db.Exec(`DELETE FROM memstore WHERE brain_id = ? AND key = ?`, s.brainID, k); err != nil {
+		return fmt.Errorf("delete memory %v in sqlite failed: %v", key, err)
+	}
+
+	return nil
+}
+
+func (s *SQLite) Clear() error {
+	if _, err := s.db.Exec(`DELETE FROM memstore WHERE brain_id = ?`, s.brainID); err != nil {
+		return fmt.Errorf("clear memory in sqlite failed: %v", err)
+	}
+
+	return nil
+}
+
+func (s *SQLite) Keys() ([]any, error) {
+	keys := make([]any, 0)
+	err := s.scan(`SELECT key FROM memstore WHERE brain_id = ? AND `+sqliteLive, func(rows *sql.Rows) error {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return err
+		}
+		key, err := decodeKey(k)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (s *SQLite) Snapshot() (map[any]any, error) {
+	m := make(map[any]any)
+	err := s.scan(`SELECT key, value, type FROM memstore WHERE brain_id = ? AND `+sqliteLive, func(rows *sql.Rows) error {
+		var k, data, valueType string
+		if err := rows.Scan(&k, &data, &valueType); err != nil {
+			return err
+		}
+		key, err := decodeKey(k)
+		if err != nil {
+			return err
+		}
+		value, err := decodeValue([]byte(data), valueType)
+		if err != nil {
+			return err
+		}
+		m[snapshotKey(key)] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Close does nothing, the database is not closed
+func (s *SQLite) Close() error {
+	return nil
+}
+
+// sqliteLive filters the memories not expired, its argument is the current time in nanoseconds
+const sqliteLive = `(expires_at IS NULL OR expires_at > ?)`
+
+// scan runs query of the memories of brain alive now, and calls fn for every row
+func (s *SQLite) scan(query string, fn func(rows *sql.Rows) error) error {
+	rows, err := s.db.Query(query, s.brainID, time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("query memory in sqlite failed: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = fn(rows); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("query memory in sqlite failed: %v", err)
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -47,7 +48,7 @@ func main() {
 
 	answer := brain.GetMemory("answer").(string)
 	fmt.Printf("answer: %s\n", answer)
-	brain.Shutdown()
+	brain.Shutdown(context.Background())
 }
 
 func date(b processor.BrainContext) error {
@@ -168,6 +168,17 @@ func (n *neuron) ListCastGroups() map[string][]string {
 	return n.castGroups.format()
 }
 
+// applyOptions applies opts to the new neuron, the ID set by core.WithNeuronID is taken here only
+func (n *neuron) applyOptions(opts []core.NeuronOption) {
+	for _, opt := range opts {
+		if o, ok := opt.(interface{ NeuronID() string }); ok {
+			n.id = o.NeuronID()
+			continue
+		}
+		opt.Apply(n)
+	}
+}
+
 func (n *neuron) SetLabels(labels map[string]string) {
 	n.labels = labels
 }
@@ -0,0 +1,186 @@
+package rModel
+
+import (
+	"fmt"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/internal/errors"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// NewPatch new patch of brain topology, it is applied by Apply of brain
+func NewPatch() core.Patch {
+	return &patch{
+		neurons: make(map[string]*neuron),
+	}
+}
+
+// patch is implement Patch
+type patch struct {
+	// new neurons, neuronIDs keeps the order of neurons
+	neurons   map[string]*neuron
+	neuronIDs []string
+	// new links
+	links          []*link
+	removedNeurons []string
+	removedLinks   []string
+	groupChanges   []core.GroupChange
+}
+
+func (p *patch) AddNeuron(processFn func(bc processor.BrainContext) error, withOpts ...core.NeuronOption) core.Neuron {
+	return p.addNeuronWithProcessor(processor.NewFuncProcessor(processFn), withOpts...)
+}
+
+func (p *patch) AddNeuronWithProcessor(processor processor.Processor, withOpts ...core.NeuronOption) core.Neuron {
+	return p.addNeuronWithProcessor(processor, withOpts...)
+}
+
+func (p *patch) AddLink(from, to core.Neuron, withOpts ...core.LinkOption) (core.Link, error) {
+	if from == nil || to == nil {
+		return nil, fmt.Errorf("neuron of link is nil")
+	}
+	// new link, apply options before neurons set, the options may change link ID
+	l := newLink(from.GetID(), to.GetID())
+	l.applyOptions(withOpts)
+	if p.hasLink(l.GetID()) {
+		return nil, errors.ErrLinkExists(l.GetID())
+	}
+	// only neurons of patch are set, neurons of brain are set when patch is applied
+	if src, ok := p.neurons[from.GetID()]; ok {
+		src.addOutLink(l.GetID())
+	}
+	if dest, ok := p.neurons[to.GetID()]; ok {
+		dest.addInLink(l.GetID())
+	}
+	p.links = append(p.links, l)
+
+	return l, nil
+}
+
+func (p *patch) AddEntryLinkTo(to core.Neuron, withOpts ...core.LinkOption) (core.Link, error) {
+	if to == nil {
+		return nil, fmt.Errorf("neuron of link is nil")
+	}
+	l := newEntryLink(to.GetID())
+	l.applyOptions(withOpts)
+	if p.hasLink(l.GetID()) {
+		return nil, errors.ErrLinkExists(l.GetID())
+	}
+	if dest, ok := p.neurons[to.GetID()]; ok {
+		dest.addInLink(l.GetID())
+	}
+	p.links = append(p.links, l)
+
+	return l, nil
+}
+
+func (p *patch) AddEndLinkFrom(from core.Neuron, withOpts ...core.LinkOption) (core.Link, error) {
+	if from == nil {
+		return nil, fmt.Errorf("neuron of link is nil")
+	}
+	l := newEndLink(from.GetID())
+	l.applyOptions(withOpts)
+	if p.hasLink(l.GetID()) {
+		return nil, errors.ErrLinkExists(l.GetID())
+	}
+	if src, ok := p.neurons[from.GetID()]; ok {
+		src.addOutLink(l.GetID())
+	}
+	p.links = append(p.links, l)
+
+	return l, nil
+}
+
+func (p *patch) RemoveNeuron(neuronID string) {
+	p.removedNeurons = append(p.removedNeurons, neuronID)
+}
+
+func (p *patch) RemoveLink(linkID string) {
+	p.removedLinks = append(p.removedLinks, linkID)
+}
+
+func (p *patch) AddTriggerGroup(neuronID string, linkIDs ...string) {
+	p.groupChanges = append(p.groupChanges, core.GroupChange{
+		Kind:     core.GroupChangeAddTrigger,
+		NeuronID: neuronID,
+		LinkIDs:  linkIDs,
+	})
+}
+
+func (p *patch) RemoveTriggerGroup(neuronID string, linkIDs ...string) {
+	p.groupChanges = append(p.groupChanges, core.GroupChange{
+		Kind:     core.GroupChangeRemoveTrigger,
+		NeuronID: neuronID,
+		LinkIDs:  linkIDs,
+	})
+}
+
+func (p *patch) AddCastGroup(neuronID, groupName string, linkIDs ...string) {
+	p.groupChanges = append(p.groupChanges, core.GroupChange{
+		Kind:      core.GroupChangeAddCast,
+		NeuronID:  neuronID,
+		GroupName: groupName,
+		LinkIDs:   linkIDs,
+	})
+}
+
+func (p *patch) RemoveCastGroup(neuronID, groupName string) {
+	p.groupChanges = append(p.groupChanges, core.GroupChange{
+		Kind:      core.GroupChangeRemoveCast,
+		NeuronID:  neuronID,
+		GroupName: groupName,
+	})
+}
+
+func (p *patch) ListNeurons() []core.Neuron {
+	ret := make([]core.Neuron, 0, len(p.neuronIDs))
+	for _, id := range p.neuronIDs {
+		ret = append(ret, p.neurons[id])
+	}
+
+	return ret
+}
+
+func (p *patch) ListLinks() []core.Link {
+	ret := make([]core.Link, 0, len(p.links))
+	for _, l := range p.links {
+		ret = append(ret, l)
+	}
+
+	return ret
+}
+
+func (p *patch) ListRemovedNeurons() []string {
+	return append([]string(nil), p.removedNeurons...)
+}
+
+func (p *patch) ListRemovedLinks() []string {
+	return append([]string(nil), p.removedLinks...)
+}
+
+func (p *patch) ListGroupChanges() []core.GroupChange {
+	return append([]core.GroupChange(nil), p.groupChanges...)
+}
+
+func (p *patch) addNeuronWithProcessor(proc processor.Processor, withOpts ...core.NeuronOption) core.Neuron {
+	n := newNeuron(proc)
+	n.applyOptions(withOpts)
+	// AddNeuron has no error to return, a duplicate ID is a bug of the patch
+	if _, ok := p.neurons[n.GetID()]; ok {
+		panic(errors.ErrNeuronExists(n.GetID()))
+	}
+	p.neuronIDs = append(p.neuronIDs, n.GetID())
+	p.neurons[n.GetID()] = n
+
+	return n
+}
+
+func (p *patch) hasLink(linkID string) bool {
+	for _, l := range p.links {
+		if l.GetID() == linkID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,9 +1,18 @@
 package processor
 
+import (
+	"context"
+	"io"
+	"time"
+)
+
 type BrainContext interface {
 	// SetMemory set memories for brain, one key value pair is one memory.
 	// memory will lazy initial util `SetMemory` or any link trig
 	SetMemory(keysAndValues ...interface{}) error
+	// SetMemoryWithTTL sets memory of key which expires after ttl, ttl must be positive.
+	// An expired memory does not exist any more, and it is sent to the watchers with Expired set
+	SetMemoryWithTTL(key, value interface{}, ttl time.Duration) error
 	// GetMemory get memory by key
 	GetMemory(key interface{}) interface{}
 	// ExistMemory indicates whether there is a memory in the brain
@@ -22,8 +31,59 @@ type BrainContext interface {
 	GetBrainLabels() map[string]string
 	// ContinueCast keep current process running, and continue cast
 	ContinueCast()
-	// TODO Context extends context.Context
-	//context.Context
+	// WatchMemory returns a channel of changes of memories of keys, all memories are watched if keys is empty.
+	// The channel is closed when ctx is done, e.g. pass the BrainContext to watch until the process is cancelled.
+	// The oldest changes are dropped when the watcher falls too far behind, Dropped of the next change counts them
+	WatchMemory(ctx context.Context, keys ...interface{}) <-chan MemoryChange
+	// UpdateMemory runs fn in a memory transaction, the writes of tx are committed together only if fn returns nil.
+	// Reads of tx see the memories when the transaction began and its own writes
+	UpdateMemory(fn func(tx MemoryTx) error) error
+	// CompareAndSwapMemory sets key to new if its value deeply equals old, old is nil for a key not existing
+	CompareAndSwapMemory(key, old, new interface{}) (bool, error)
+	// ListMemoryKeys lists keys of all memories, in no particular order
+	ListMemoryKeys() ([]interface{}, error)
+	// RangeMemory calls fn for every memory until fn returns false, fn sees the memories when RangeMemory is called
+	RangeMemory(fn func(key, value interface{}) bool) error
+	// DumpMemory copies all memories
+	DumpMemory() (map[interface{}]interface{}, error)
+	// PutArtifact keeps the content of r in the artifact store of brain, and sets memory name to its reference
+	PutArtifact(name string, r io.Reader) error
+	// OpenArtifact opens the content of the artifact referenced by memory name, the caller closes it
+	OpenArtifact(name string) (io.ReadCloser, error)
+	// Context of the run, it is cancelled when brain is shut down before the processor returns
+	context.Context
+}
+
+// MemoryTx reads and writes memories in a transaction of UpdateMemory
+type MemoryTx interface {
+	// GetMemory get memory by key, it sees the writes of the transaction
+	GetMemory(key interface{}) interface{}
+	// ExistMemory indicates whether there is a memory in the brain, it sees the writes of the transaction
+	ExistMemory(key interface{}) bool
+	// SetMemory set memories in the transaction, the reducers of keys are applied
+	SetMemory(keysAndValues ...interface{}) error
+	// DeleteMemory delete one memory by key in the transaction
+	DeleteMemory(key interface{})
+}
+
+// MemoryChange is a change of memory, it is sent to the watchers of memory
+type MemoryChange struct {
+	Key interface{}
+	// OldValue is nil if the memory did not exist
+	OldValue interface{}
+	// NewValue is nil if the memory is deleted
+	NewValue interface{}
+	// Cleared indicates that all memories are cleared, Key is nil
+	Cleared bool
+	// Evicted indicates that the memory is evicted by the memory cache of BrainLocal, NewValue is nil
+	Evicted bool
+	// Expired indicates that the memory is deleted as its TTL passed, NewValue is nil
+	Expired bool
+	// NeuronID is the neuron which changed the memory, it is empty if the memory is changed outside of neurons
+	NeuronID string
+	// Dropped is the number of changes dropped right before this change, as too many changes were queued
+	// for the watcher which did not receive them in time
+	Dropped int
 }
 
 type BrainContextReader interface {
@@ -33,6 +93,6 @@ type BrainContextReader interface {
 	ExistMemory(key interface{}) bool
 	// GetCurrentNeuronID get current neuron id
 	GetCurrentNeuronID() string
-	// TODO Context extends context.Context
-	//context.Context
+	// Context of the run, it is cancelled when brain is shut down
+	context.Context
 }
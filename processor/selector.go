@@ -9,6 +9,22 @@ type Selector interface {
 	Clone() Selector
 }
 
+// SelectorWithError is a Selector which reports why it failed to select a cast group,
+// brains cast no link of neuron and report the error instead of casting nothing silently
+type SelectorWithError interface {
+	Selector
+	SelectWithError(ctx BrainContextReader) (string, error)
+}
+
+// Select selects the cast group by s, with the error of s if it is a SelectorWithError
+func Select(s Selector, ctx BrainContextReader) (string, error) {
+	if se, ok := s.(SelectorWithError); ok {
+		return se.SelectWithError(ctx)
+	}
+
+	return s.Select(ctx), nil
+}
+
 type DefaultSelector struct{}
 
 func (s *DefaultSelector) Select(ctx BrainContextReader) string {
@@ -1,12 +1,8 @@
 package pyprocessor
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
 	"strings"
 
 	"github.com/Rovanta/rmodel/processor"
@@ -18,112 +14,105 @@ func LoadPythonProcessor(pyCodePath, moduleName, processorClassName string, cons
 		moduleName:         moduleName,
 		processorClassName: processorClassName,
 		constructorArgs:    constructorArgs,
-		scriptPath:         "temp_exec_script.py",
-		pythonCmd:          "/usr/bin/python3",
+		pythonCmd:          DefaultPythonCmd,
 	}
 }
 
+// LoadPythonProcessorWithPool loads a python processor which runs in the workers of pool,
+// the neuron label python_cmd is ignored
+func LoadPythonProcessorWithPool(pool *WorkerPool, pyCodePath, moduleName, processorClassName string, constructorArgs map[string]interface{}) *ExecPyProcessor {
+	p := LoadPythonProcessor(pyCodePath, moduleName, processorClassName, constructorArgs)
+	p.pool = pool
+
+	return p
+}
+
 type ExecPyProcessor struct {
 	pyCodePath         string
 	moduleName         string
 	processorClassName string
 	constructorArgs    map[string]interface{}
-	scriptPath         string
 	pythonCmd          string
+	// pool runs the processor, it is the default pool of pythonCmd if nil
+	pool *WorkerPool
+}
+
+// classSpec is the Python class of a processor or selector in a request to Python workers,
+// the instances of the same spec are shared
+type classSpec struct {
+	Module string                 `json:"module"`
+	Class  string                 `json:"class"`
+	Args   map[string]interface{} `json:"args"`
+}
+
+// processParams are the params of the process method of Python workers, the BrainContext of the processor
+// reads and writes memory by requests to the Go side
+type processParams struct {
+	Processor   classSpec `json:"processor"`
+	BrainID     string    `json:"brain_id"`
+	ArtifactDir string    `json:"artifact_dir"`
+	NeuronID    string    `json:"neuron_id"`
 }
 
 func (p *ExecPyProcessor) Process(ctx processor.BrainContext) error {
-	if l := ctx.GetCurrentNeuronLabels(); l != nil {
-		if v, ok := l["python_cmd"]; ok {
-			p.pythonCmd = v
-		}
+	params := processParams{
+		Processor:   newClassSpec(p.pyCodePath, p.moduleName, p.processorClassName, p.constructorArgs),
+		BrainID:     ctx.GetBrainID(),
+		ArtifactDir: artifactDir(ctx),
+		NeuronID:    ctx.GetCurrentNeuronID(),
 	}
-
-	err := p.createTempPythonScript()
-	if err != nil {
-		return fmt.Errorf("create temp python script failed: %s", err)
+	if err := workerPoolOf(p.pool, p.pythonCmd, ctx).call(ctx, "process", params, nil, memoryHandler(ctx)); err != nil {
+		return fmt.Errorf("python processor %s.%s failed: %w", params.Processor.Module, p.processorClassName, err)
 	}
-	defer os.Remove(p.scriptPath)
 
-	return p.execPythonScript(fmt.Sprintf("%s.db", ctx.GetBrainID()))
+	return nil
 }
 
-func (p *ExecPyProcessor) Clone() processor.Processor {
-	return &ExecPyProcessor{
-		pyCodePath:         p.pyCodePath,
-		moduleName:         p.moduleName,
-		processorClassName: p.processorClassName,
-		constructorArgs:    p.constructorArgs,
-		scriptPath:         p.scriptPath,
-		pythonCmd:          p.pythonCmd,
+// workerPoolOf returns pool, or the default pool of pythonCmd if pool is nil,
+// the neuron label python_cmd of ctx overrides pythonCmd
+func workerPoolOf(pool *WorkerPool, pythonCmd string, ctx processor.BrainContextReader) *WorkerPool {
+	if pool != nil {
+		return pool
 	}
+	if c, ok := ctx.(interface{ GetCurrentNeuronLabels() map[string]string }); ok {
+		if v, ok := c.GetCurrentNeuronLabels()["python_cmd"]; ok {
+			pythonCmd = v
+		}
+	}
+
+	return DefaultWorkerPool(pythonCmd)
 }
 
-func (p *ExecPyProcessor) createTempPythonScript() error {
-	importPath := strings.ReplaceAll(p.pyCodePath, string(os.PathSeparator), ".")
+// newClassSpec returns the spec of a Python class, the module is imported from the path relative to the working directory
+func newClassSpec(pyCodePath, moduleName, className string, constructorArgs map[string]interface{}) classSpec {
+	importPath := strings.ReplaceAll(pyCodePath, string(os.PathSeparator), ".")
 	importPath = strings.TrimSuffix(importPath, ".")
 	importPath = strings.TrimPrefix(importPath, ".")
-
-	content := fmt.Sprintf(`
-import sys
-import json
-import os
-
-from %s.%s import %s
-from rModel import BrainContext
-
-if __name__ == "__main__":
-    if len(sys.argv) != 3:
-        print("Usage: python script.py <db_path> <params_json>")
-        sys.exit(1)
-
-    db_path = sys.argv[1]
-    params_json = sys.argv[2]
-
-    params = json.loads(params_json)
-
-    processor = %s(**params)
-    ctx = BrainContext(db_path)
-    processor.process(ctx)
-`, importPath, p.moduleName, p.processorClassName, p.processorClassName)
-
-	return os.WriteFile(p.scriptPath, []byte(content), 0644)
-}
-
-func (p *ExecPyProcessor) execPythonScript(sqliteDBPath string) error {
-	paramsJSON, err := json.Marshal(p.constructorArgs)
-	if err != nil {
-		return fmt.Errorf("Parameter serialization error: %s", err)
+	module := moduleName
+	if importPath != "" {
+		module = importPath + "." + moduleName
 	}
 
-	cmd := exec.Command(p.pythonCmd, p.scriptPath, sqliteDBPath, string(paramsJSON))
-
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("Unable to obtain standard output pipe: %s", err)
-	}
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("Unable to obtain standard error output pipe: %s", err)
-	}
+	return classSpec{Module: module, Class: className, Args: constructorArgs}
+}
 
-	err = cmd.Start()
-	if err != nil {
-		return fmt.Errorf("Failed to start Python process: %s", err)
+// artifactDir returns the directory of the artifact store of brain, the BrainContext of brains knows it,
+// otherwise it is artifacts in the working directory
+func artifactDir(ctx processor.BrainContextReader) string {
+	if a, ok := ctx.(interface{ GetArtifactDir() string }); ok {
+		return a.GetArtifactDir()
 	}
 
-	reader := io.MultiReader(stdoutPipe, stderrPipe)
-
-	fmt.Println("python processor:")
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		fmt.Println("  " + scanner.Text())
-	}
+	return "artifacts"
+}
 
-	err = cmd.Wait()
-	if err != nil {
-		return fmt.Errorf("Python process execution error: %s", err)
+func (p *ExecPyProcessor) Clone() processor.Processor {
+	return &ExecPyProcessor{
+		pyCodePath:         p.pyCodePath,
+		moduleName:         p.moduleName,
+		processorClassName: p.processorClassName,
+		constructorArgs:    p.constructorArgs,
+		pythonCmd:          p.pythonCmd,
+		pool:               p.pool,
 	}
-
-	return nil
 }
@@ -0,0 +1,145 @@
+package pyprocessor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// memoryParams are the params of the memory requests of Python workers, keys and values are their JSON views
+type memoryParams struct {
+	Key           json.RawMessage   `json:"key"`
+	KeysAndValues []json.RawMessage `json:"keys_and_values"`
+}
+
+// memoryHandler serves the memory requests of the BrainContext of Python processors with ctx, so Python processors
+// read and write memory through the brain running them, whatever its implementation and memory store is.
+// The writes are refused if ctx is a BrainContextReader only, e.g. the one of selectors.
+func memoryHandler(ctx processor.BrainContextReader) rpcHandler {
+	return func(method string, raw json.RawMessage) (any, error) {
+		var params memoryParams
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &params); err != nil {
+				return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+			}
+		}
+
+		switch method {
+		case "memory.get", "memory.exist":
+			key, err := decodeKey(params.Key)
+			if err != nil {
+				return nil, err
+			}
+			if method == "memory.exist" {
+				return ctx.ExistMemory(key), nil
+			}
+			return ctx.GetMemory(key), nil
+		}
+
+		bc, ok := ctx.(processor.BrainContext)
+		if !ok {
+			return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %s is not allowed, memory is read only", method)}
+		}
+		switch method {
+		case "memory.set":
+			keysAndValues := make([]interface{}, 0, len(params.KeysAndValues))
+			for i, v := range params.KeysAndValues {
+				decode := decodeValue
+				if i%2 == 0 {
+					decode = decodeKey
+				}
+				value, err := decode(v)
+				if err != nil {
+					return nil, err
+				}
+				keysAndValues = append(keysAndValues, value)
+			}
+			return nil, bc.SetMemory(keysAndValues...)
+		case "memory.delete":
+			key, err := decodeKey(params.Key)
+			if err != nil {
+				return nil, err
+			}
+			bc.DeleteMemory(key)
+			return nil, nil
+		case "memory.clear":
+			bc.ClearMemory()
+			return nil, nil
+		case "continue_cast":
+			bc.ContinueCast()
+			return nil, nil
+		default:
+			return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", method)}
+		}
+	}
+}
+
+// decodeKey decodes the JSON view of a key of Python, which is a string, a number or a bool.
+// Integers are decoded as int, the same as the keys of Go
+func decodeKey(raw json.RawMessage) (interface{}, error) {
+	var key interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&key); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid key: %v", err)}
+	}
+
+	switch k := key.(type) {
+	case string, bool:
+		return k, nil
+	case json.Number:
+		if i, err := k.Int64(); err == nil {
+			return int(i), nil
+		}
+		f, err := k.Float64()
+		if err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid key %s", k)}
+		}
+		return f, nil
+	default:
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unsupported key type %T", key)}
+	}
+}
+
+// decodeValue decodes the JSON view of a value of Python as BrainLite reads the memories written by Python,
+// an integer is an int if it fits in 32 bits, otherwise an int64, also in objects and arrays, other numbers are float64
+func decodeValue(raw json.RawMessage) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	var value interface{}
+	if err := d.Decode(&value); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid value: %v", err)}
+	}
+
+	return convertNumbers(value), nil
+}
+
+// convertNumbers replaces the json.Number in value with int, int64 or float64
+func convertNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			if i >= math.MinInt32 && i <= math.MaxInt32 {
+				return int(i)
+			}
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = convertNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = convertNumbers(e)
+		}
+	}
+
+	return value
+}
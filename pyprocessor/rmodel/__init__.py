@@ -1,5 +1,8 @@
+from .base import BaseBrainContext, BaseBrainContextReader
 from .brain_context import BrainContext, BrainContextReader
+from .remote_context import RemoteBrainContext, RemoteBrainContextReader, RPCError
 from .processor import Processor
 from .selector import Selector, DEFAULT_CAST_GROUP_NAME
 
-__all__ = ['Processor', 'BrainContext', 'BrainContextReader', 'Selector', 'DEFAULT_CAST_GROUP_NAME']
\ No newline at end of file
+__all__ = ['Processor', 'BaseBrainContext', 'BaseBrainContextReader', 'BrainContext', 'BrainContextReader', 'RemoteBrainContext', 'RemoteBrainContextReader', 'RPCError',
+           'Selector', 'DEFAULT_CAST_GROUP_NAME']
\ No newline at end of file
@@ -0,0 +1,95 @@
+import hashlib
+import os
+import tempfile
+from abc import ABC, abstractmethod
+from typing import Any, BinaryIO, Optional, Union
+
+# algorithm prefix of artifact digests, the content of an artifact is kept at sha256/<2 hex>/<hex>
+DIGEST_PREFIX = "sha256:"
+
+
+class BaseBrainContextReader(ABC):
+    """Reads memory of a brain, the memory is read from the SQLite database of BrainLite by BrainContextReader,
+    or through the Go brain running the worker by RemoteBrainContextReader"""
+
+    def __init__(self, brain_id: str, artifact_dir: Optional[str] = None, neuron_id: str = ""):
+        self.brain_id = brain_id
+        self.artifact_dir = artifact_dir
+        self.current_neuron_id = neuron_id
+
+    @abstractmethod
+    def get_memory(self, key: Any) -> Any:
+        pass
+
+    @abstractmethod
+    def exist_memory(self, key: Any) -> bool:
+        pass
+
+    def get_current_neuron_id(self) -> str:
+        return self.current_neuron_id
+
+    def open_artifact(self, name: str) -> BinaryIO:
+        """Opens the content of the artifact referenced by memory name, the caller closes it"""
+        ref = self.get_memory(name)
+        if not isinstance(ref, dict) or not ref.get("digest"):
+            raise KeyError(f"memory {name} is not an artifact")
+        return open(self.artifact_path(ref["digest"]), "rb")
+
+    def artifact_path(self, digest: str) -> str:
+        if self.artifact_dir is None:
+            raise RuntimeError("artifact directory of brain is unknown")
+        hex_digest = digest[len(DIGEST_PREFIX):] if digest.startswith(DIGEST_PREFIX) else ""
+        if len(hex_digest) != 64:
+            raise ValueError(f"invalid artifact digest {digest!r}")
+        return os.path.join(self.artifact_dir, "sha256", hex_digest[:2], hex_digest)
+
+
+class BaseBrainContext(BaseBrainContextReader):
+    """Reads and writes memory of a brain, processors take a BaseBrainContext"""
+
+    @abstractmethod
+    def set_memory(self, *keys_and_values: Any) -> None:
+        pass
+
+    @abstractmethod
+    def delete_memory(self, key: Any) -> None:
+        pass
+
+    @abstractmethod
+    def clear_memory(self) -> None:
+        pass
+
+    def continue_cast(self) -> None:
+        pass
+
+    def put_artifact(self, name: str, content: Union[bytes, BinaryIO]) -> dict:
+        """Keeps content, bytes or a binary file, in the artifact store of brain, and sets memory name to its
+        reference, the same as PutArtifact of Go brains"""
+        if self.artifact_dir is None:
+            raise RuntimeError("artifact directory of brain is unknown")
+        os.makedirs(self.artifact_dir, exist_ok=True)
+
+        # the content is written to a temporary file first, and moved to its digest path when it is complete
+        h = hashlib.sha256()
+        size = 0
+        fd, tmp_path = tempfile.mkstemp(prefix=".tmp-", dir=self.artifact_dir)
+        try:
+            with os.fdopen(fd, "wb") as tmp:
+                chunks = [content] if isinstance(content, (bytes, bytearray)) else iter(lambda: content.read(1 << 16), b"")
+                for chunk in chunks:
+                    h.update(chunk)
+                    tmp.write(chunk)
+                    size += len(chunk)
+            digest = DIGEST_PREFIX + h.hexdigest()
+            path = self.artifact_path(digest)
+            if not os.path.exists(path):
+                os.makedirs(os.path.dirname(path), exist_ok=True)
+                os.chmod(tmp_path, 0o644)
+                os.replace(tmp_path, path)
+        finally:
+            if os.path.exists(tmp_path):
+                os.remove(tmp_path)
+
+        ref = {"name": name, "digest": digest, "size": size}
+        self.set_memory(name, ref)
+        return ref
@@ -1,16 +1,30 @@
 import sqlite3
 import json
 import sys
+import base64
 import hashlib
 import os
-from typing import Any
-from abc import ABC
+import time
+from typing import Any, Optional
 
-class BrainContextReader(ABC):
-    def __init__(self, db_path: str):
+from .base import BaseBrainContext, BaseBrainContextReader
+
+# seconds a writer waits for the write lock of database, the same as Go brains
+BUSY_TIMEOUT = 5.0
+
+# filters out the memories expired, expires_at is in unix nanoseconds
+LIVE_MEMORY = "(expires_at IS NULL OR expires_at > ?)"
+
+# finds the memory of a key by its hash, or by its legacy hash if it was written without the original key
+MEMORY_OF_KEY = "(key = ? OR key = ? AND key_type IS NULL)"
+
+class BrainContextReader(BaseBrainContextReader):
+    """Reads memory from the SQLite database of a BrainLite directly, e.g. in a script outside of brains"""
+
+    def __init__(self, db_path: str, brain_id: str, artifact_dir: Optional[str] = None):
+        super().__init__(brain_id, artifact_dir)
         self.db_path = db_path
         self.conn = self.init_db()
-        self.current_neuron_id = ""
 
     def init_db(self):
         if not os.path.exists(self.db_path):
@@ -18,7 +32,7 @@ class BrainContextReader(ABC):
             sys.exit(1)
         
         try:
-            conn = sqlite3.connect(self.db_path)
+            conn = sqlite3.connect(self.db_path, timeout=BUSY_TIMEOUT)
             cursor = conn.cursor()
             cursor.execute("SELECT name FROM sqlite_master WHERE type='table' AND name='memory'")
             if cursor.fetchone() is None:
@@ -32,8 +46,9 @@ class BrainContextReader(ABC):
     def get_memory(self, key: Any) -> Any:
         try:
             cursor = self.conn.cursor()
-            hashed_key = self.hash_key(key)
-            cursor.execute("SELECT value, type FROM memory WHERE key = ?", (str(hashed_key),))
+            cursor.execute("SELECT value, type FROM memory WHERE brain_id = ? AND " + MEMORY_OF_KEY + " AND " +
+                           LIVE_MEMORY + " ORDER BY key_type IS NULL LIMIT 1",
+                           (self.brain_id, *self.key_hashes(key), time.time_ns()))
             result = cursor.fetchone()
             
             if result is None:
@@ -55,27 +70,50 @@ class BrainContextReader(ABC):
 
     def exist_memory(self, key: Any) -> bool:
         cursor = self.conn.cursor()
-        hashed_key = self.hash_key(key)
-        cursor.execute("SELECT 1 FROM memory WHERE key = ?", (str(hashed_key),))
+        cursor.execute("SELECT 1 FROM memory WHERE brain_id = ? AND " + MEMORY_OF_KEY + " AND " + LIVE_MEMORY,
+                       (self.brain_id, *self.key_hashes(key), time.time_ns()))
         return cursor.fetchone() is not None
 
-    def get_current_neuron_id(self) -> str:
-        return self.current_neuron_id
-
     @staticmethod
     def hash_key(key):
+        """Hashes the type and the text of key the same as Go, so 1 and "1" are different memories.
+        A float key is hashed by its text only, as its text may differ from Go"""
         if not isinstance(key, (int, float, str, bytes, bytearray)):
             raise TypeError("Unsupported key type")
-        
-        key_bytes = str(key).encode('utf-8')
-        hash_bytes = hashlib.sha256(key_bytes).digest()
-        hash_value = int.from_bytes(hash_bytes[:8], 'big', signed=True)
-        
-        return hash_value
 
-class BrainContext(BrainContextReader):
-    def __init__(self, db_path: str):
-        super().__init__(db_path)
+        key_type, key_text = BrainContextReader.original_key(key)
+        if key_type is None:
+            return BrainContextReader.hash_text(str(key))
+        return BrainContextReader.hash_text(key_type + ":" + key_text)
+
+    @staticmethod
+    def key_hashes(key):
+        """The hash of key, and the legacy hash of its text the memories written by older versions have"""
+        return str(BrainContextReader.hash_key(key)), str(BrainContextReader.hash_text(str(key)))
+
+    @staticmethod
+    def hash_text(text: str) -> int:
+        hash_bytes = hashlib.sha256(text.encode('utf-8')).digest()
+        return int.from_bytes(hash_bytes[:8], 'big', signed=True)
+
+    @staticmethod
+    def original_key(key):
+        # the original key is stored for the keys whose texts are the same as Go
+        if isinstance(key, str):
+            return "string", key
+        if isinstance(key, bool):
+            return "bool", "true" if key else "false"
+        if isinstance(key, int):
+            return "int", str(key)
+        if isinstance(key, (bytes, bytearray)):
+            return "bytes", base64.b64encode(bytes(key)).decode("ascii")
+        return None, None
+
+class BrainContext(BrainContextReader, BaseBrainContext):
+    """Reads and writes memory in the SQLite database of a BrainLite directly"""
+
+    def __init__(self, db_path: str, brain_id: str, artifact_dir: Optional[str] = None):
+        super().__init__(db_path, brain_id, artifact_dir)
 
     def set_memory(self, *keys_and_values: Any) -> None:
         if len(keys_and_values) % 2 != 0:
@@ -101,23 +139,25 @@ class BrainContext(BrainContextReader):
             
             value_json = json.dumps(value)
             
-            hashed_key = self.hash_key(key)
-            cursor.execute("INSERT OR REPLACE INTO memory (key, value, type) VALUES (?, ?, ?)",
-                           (str(hashed_key), value_json, value_type))
+            hashed_key, legacy_key = self.key_hashes(key)
+            key_type, key_text = self.original_key(key)
+            cursor.execute("INSERT OR REPLACE INTO memory (brain_id, key, key_type, key_text, value, type) "
+                           "VALUES (?, ?, ?, ?, ?, ?)",
+                           (self.brain_id, hashed_key, key_type, key_text, value_json, value_type))
+            if key_type is not None:
+                # the memory written without the original key by an older version is replaced
+                cursor.execute("DELETE FROM memory WHERE brain_id = ? AND key = ? AND key_type IS NULL",
+                               (self.brain_id, legacy_key))
             self.conn.commit()
         except (sqlite3.Error, json.JSONDecodeError) as e:
             print(f"Set memory error ({key}): {e}", file=sys.stderr)
 
     def delete_memory(self, key: Any) -> None:
         cursor = self.conn.cursor()
-        hashed_key = self.hash_key(key)
-        cursor.execute("DELETE FROM memory WHERE key = ?", (str(hashed_key),))
+        cursor.execute("DELETE FROM memory WHERE brain_id = ? AND " + MEMORY_OF_KEY, (self.brain_id, *self.key_hashes(key)))
         self.conn.commit()
 
     def clear_memory(self) -> None:
         cursor = self.conn.cursor()
-        cursor.execute("DELETE FROM memory")
+        cursor.execute("DELETE FROM memory WHERE brain_id = ?", (self.brain_id,))
         self.conn.commit()
-
-    def continue_cast(self) -> None:
-        pass
@@ -1,8 +1,8 @@
 from abc import ABC, abstractmethod
-from .brain_context import BrainContext
+from .base import BaseBrainContext
 
 class Processor(ABC):
     @abstractmethod
-    def process(self, ctx: BrainContext):
+    def process(self, ctx: BaseBrainContext):
         pass
 
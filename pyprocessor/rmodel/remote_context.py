@@ -0,0 +1,56 @@
+from typing import Any, Callable, Optional
+
+from .base import BaseBrainContext, BaseBrainContextReader
+
+# Call sends a request to the Go side and returns its result, it raises RPCError if the request failed
+Call = Callable[[str, dict], Any]
+
+# key types of the Go side, the same as the ones of memory documents
+KEY_TYPES = (str, int, float, bool)
+
+
+class RPCError(Exception):
+    def __init__(self, code: int, message: str):
+        super().__init__(message)
+        self.code = code
+
+
+class RemoteBrainContextReader(BaseBrainContextReader):
+    """Reads memory through the Go brain running the worker, instead of the SQLite database of BrainLite,
+    so processors and selectors work with any brain and memory backend"""
+
+    def __init__(self, call: Call, brain_id: str, artifact_dir: Optional[str] = None, neuron_id: str = ""):
+        super().__init__(brain_id, artifact_dir, neuron_id)
+        self.call = call
+
+    def get_memory(self, key: Any) -> Any:
+        return self.call("memory.get", {"key": self.check_key(key)})
+
+    def exist_memory(self, key: Any) -> bool:
+        return bool(self.call("memory.exist", {"key": self.check_key(key)}))
+
+    @staticmethod
+    def check_key(key: Any) -> Any:
+        if not isinstance(key, KEY_TYPES):
+            raise TypeError(f"Unsupported key type {type(key).__name__}")
+        return key
+
+
+class RemoteBrainContext(RemoteBrainContextReader, BaseBrainContext):
+    """Reads and writes memory through the Go brain running the worker, the reducers and watchers of brain apply"""
+
+    def set_memory(self, *keys_and_values: Any) -> None:
+        if len(keys_and_values) % 2 != 0:
+            raise ValueError("The number of key-value pairs must be an even number")
+        for key in keys_and_values[::2]:
+            self.check_key(key)
+        self.call("memory.set", {"keys_and_values": list(keys_and_values)})
+
+    def delete_memory(self, key: Any) -> None:
+        self.call("memory.delete", {"key": self.check_key(key)})
+
+    def clear_memory(self) -> None:
+        self.call("memory.clear", {})
+
+    def continue_cast(self) -> None:
+        self.call("continue_cast", {})
@@ -1,10 +1,10 @@
 from abc import ABC, abstractmethod
-from .brain_context import BrainContextReader
+from .base import BaseBrainContextReader
 
 DEFAULT_CAST_GROUP_NAME = "__DEFAULT_CAST_GROUP__"
 
 class Selector(ABC):
     @abstractmethod
-    def select(self, ctx: BrainContextReader) -> str:
+    def select(self, ctx: BaseBrainContextReader) -> str:
         pass
 
@@ -0,0 +1,125 @@
+"""Worker process of Python processors and selectors, it is started by the Go side as `python3 -m rmodel.worker`.
+
+The worker reads JSON-RPC 2.0 requests from stdin and writes the responses to stdout, every message is framed
+by a Content-Length header. While serving a request, the worker sends the memory requests of the BrainContext
+of processors and selectors to the Go side the same way. The output of them is written to stderr, stdout carries
+the protocol only.
+"""
+import importlib
+import json
+import os
+import sys
+import traceback
+from typing import Any, BinaryIO, Dict, Optional
+
+from .remote_context import RemoteBrainContext, RemoteBrainContextReader, RPCError
+
+# JSON-RPC error codes
+METHOD_NOT_FOUND = -32601
+PROCESSOR_ERROR = -32000
+
+
+def read_message(stream: BinaryIO) -> Optional[dict]:
+    headers = {}
+    while True:
+        line = stream.readline()
+        if not line:
+            return None
+        line = line.decode("ascii").strip()
+        if not line:
+            break
+        name, _, value = line.partition(":")
+        headers[name.strip().lower()] = value.strip()
+
+    length = int(headers["content-length"])
+    body = stream.read(length)
+    if len(body) < length:
+        return None
+    return json.loads(body)
+
+
+def write_message(stream: BinaryIO, message: dict) -> None:
+    message["jsonrpc"] = "2.0"
+    body = json.dumps(message).encode("utf-8")
+    stream.write(b"Content-Length: %d\r\n\r\n" % len(body))
+    stream.write(body)
+    stream.flush()
+
+
+class Worker:
+    def __init__(self, rx: BinaryIO, tx: BinaryIO):
+        self.rx = rx
+        self.tx = tx
+        # processor instances by module, class and constructor args, they are kept warm between activations
+        self.instances: Dict[str, Any] = {}
+        self.next_id = 0
+
+    def call(self, method: str, params: dict) -> Any:
+        """Sends a request to the Go side while serving one, and waits for its response"""
+        self.next_id += 1
+        request_id = self.next_id
+        write_message(self.tx, {"id": request_id, "method": method, "params": params})
+        response = read_message(self.rx)
+        if response is None:
+            raise EOFError("Go side closed the worker")
+        if response.get("id") != request_id:
+            raise RPCError(PROCESSOR_ERROR, f"unexpected response {response.get('id')} to request {request_id}")
+        if response.get("error") is not None:
+            raise RPCError(response["error"].get("code", PROCESSOR_ERROR), response["error"].get("message", ""))
+        return response.get("result")
+
+    def instance(self, spec: dict) -> Any:
+        key = json.dumps([spec["module"], spec["class"], spec.get("args") or {}], sort_keys=True)
+        if key not in self.instances:
+            module = importlib.import_module(spec["module"])
+            cls = getattr(module, spec["class"])
+            self.instances[key] = cls(**(spec.get("args") or {}))
+        return self.instances[key]
+
+    def process(self, params: dict) -> None:
+        processor = self.instance(params["processor"])
+        ctx = RemoteBrainContext(self.call, params["brain_id"], params.get("artifact_dir"), params.get("neuron_id", ""))
+        processor.process(ctx)
+
+    def select(self, params: dict) -> str:
+        selector = self.instance(params["selector"])
+        ctx = RemoteBrainContextReader(self.call, params["brain_id"], params.get("artifact_dir"),
+                                       params.get("neuron_id", ""))
+        group = selector.select(ctx)
+        if not isinstance(group, str):
+            raise TypeError(f"select returned {type(group).__name__}, not the name of a cast group")
+        return group
+
+    def serve(self) -> None:
+        methods = {"process": self.process, "select": self.select}
+        while True:
+            request = read_message(self.rx)
+            if request is None:
+                return
+            response = {"id": request.get("id")}
+            method = methods.get(request.get("method"))
+            if method is None:
+                response["error"] = {"code": METHOD_NOT_FOUND, "message": f"method not found: {request.get('method')}"}
+            else:
+                try:
+                    response["result"] = method(request.get("params") or {})
+                # SystemExit of processors and BrainContext fails the activation, not the worker
+                except BaseException as e:
+                    if isinstance(e, KeyboardInterrupt):
+                        raise
+                    response["error"] = {"code": PROCESSOR_ERROR, "message": f"{type(e).__name__}: {e}",
+                                         "data": traceback.format_exc()}
+            write_message(self.tx, response)
+
+
+def main() -> None:
+    # the protocol is moved to a private descriptor, so the output of processors, even the one written to the file
+    # descriptor 1 by extensions and subprocesses, goes to stderr
+    rx, tx = sys.stdin.buffer, os.fdopen(os.dup(1), "wb")
+    os.dup2(2, 1)
+    sys.stdout = sys.stderr
+    Worker(rx, tx).serve()
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,104 @@
+package pyprocessor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// rpcMessage is a JSON-RPC 2.0 request or response between Go and a Python worker, both sides send requests.
+// Every message is framed by a Content-Length header, as in the Language Server Protocol
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is the error of a JSON-RPC response, Data is the Python traceback of a failed processor
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"`
+}
+
+// JSON-RPC error codes
+const (
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeServerError    = -32000
+)
+
+// rpcHandler serves a request sent by a Python worker during a call, the result is serialized as JSON
+type rpcHandler func(method string, params json.RawMessage) (any, error)
+
+func (e *rpcError) Error() string {
+	if e.Data != "" {
+		return fmt.Sprintf("%s\n%s", e.Message, e.Data)
+	}
+
+	return e.Message
+}
+
+func writeMessage(w io.Writer, msg *rpcMessage) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+
+	return err
+}
+
+func readMessage(r *bufio.Reader) (*rpcMessage, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	msg := &rpcMessage{}
+	if err = json.Unmarshal(body, msg); err != nil {
+		return nil, fmt.Errorf("invalid JSON-RPC message: %v", err)
+	}
+
+	return msg, nil
+}
+
+// replyMessage returns the response to the request of id, with the result or error of its handler
+func replyMessage(id *int64, result any, err error) *rpcMessage {
+	reply := &rpcMessage{ID: id}
+	if err != nil {
+		rpcErr, ok := err.(*rpcError)
+		if !ok {
+			rpcErr = &rpcError{Code: codeServerError, Message: err.Error()}
+		}
+		reply.Error = rpcErr
+		return reply
+	}
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		reply.Error = &rpcError{Code: codeServerError, Message: fmt.Sprintf("unable to serialize result: %v", err)}
+		return reply
+	}
+	reply.Result = raw
+
+	return reply
+}
@@ -0,0 +1,92 @@
+package pyprocessor
+
+import (
+	"fmt"
+
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// LoadPythonSelector loads a python selector, whose select(ctx) returns the name of the cast group of a neuron.
+// It runs in the workers of the default pool of the python command, which the neuron label python_cmd overrides
+func LoadPythonSelector(pyCodePath, moduleName, selectorClassName string, constructorArgs map[string]interface{}) *ExecPySelector {
+	return &ExecPySelector{
+		pyCodePath:        pyCodePath,
+		moduleName:        moduleName,
+		selectorClassName: selectorClassName,
+		constructorArgs:   constructorArgs,
+		pythonCmd:         DefaultPythonCmd,
+	}
+}
+
+// LoadPythonSelectorWithPool loads a python selector which runs in the workers of pool,
+// the neuron label python_cmd is ignored
+func LoadPythonSelectorWithPool(pool *WorkerPool, pyCodePath, moduleName, selectorClassName string, constructorArgs map[string]interface{}) *ExecPySelector {
+	s := LoadPythonSelector(pyCodePath, moduleName, selectorClassName, constructorArgs)
+	s.pool = pool
+
+	return s
+}
+
+var _ processor.SelectorWithError = (*ExecPySelector)(nil)
+
+type ExecPySelector struct {
+	pyCodePath        string
+	moduleName        string
+	selectorClassName string
+	constructorArgs   map[string]interface{}
+	pythonCmd         string
+	// pool runs the selector, it is the default pool of pythonCmd if nil
+	pool *WorkerPool
+}
+
+// selectParams are the params of the select method of Python workers, the BrainContextReader of the selector
+// reads memory by requests to the Go side
+type selectParams struct {
+	Selector    classSpec `json:"selector"`
+	BrainID     string    `json:"brain_id"`
+	ArtifactDir string    `json:"artifact_dir"`
+	NeuronID    string    `json:"neuron_id"`
+}
+
+// readOnlyContext hides the writes of the BrainContext of brains from selectors
+type readOnlyContext struct {
+	processor.BrainContextReader
+}
+
+// Select returns the cast group selected by the Python selector, no cast group is selected if it failed
+func (s *ExecPySelector) Select(ctx processor.BrainContextReader) string {
+	group, _ := s.SelectWithError(ctx)
+
+	return group
+}
+
+// SelectWithError returns the cast group selected by the Python selector, or the error of the selector
+func (s *ExecPySelector) SelectWithError(ctx processor.BrainContextReader) (string, error) {
+	params := selectParams{
+		Selector:    newClassSpec(s.pyCodePath, s.moduleName, s.selectorClassName, s.constructorArgs),
+		ArtifactDir: artifactDir(ctx),
+		NeuronID:    ctx.GetCurrentNeuronID(),
+	}
+	if b, ok := ctx.(interface{ GetBrainID() string }); ok {
+		params.BrainID = b.GetBrainID()
+	}
+
+	var group string
+	reader := readOnlyContext{BrainContextReader: ctx}
+	if err := workerPoolOf(s.pool, s.pythonCmd, ctx).call(ctx, "select", params, &group, memoryHandler(reader)); err != nil {
+		return "", fmt.Errorf("python selector %s.%s failed: %w", params.Selector.Module, s.selectorClassName, err)
+	}
+
+	return group, nil
+}
+
+func (s *ExecPySelector) Clone() processor.Selector {
+	return &ExecPySelector{
+		pyCodePath:        s.pyCodePath,
+		moduleName:        s.moduleName,
+		selectorClassName: s.selectorClassName,
+		constructorArgs:   s.constructorArgs,
+		pythonCmd:         s.pythonCmd,
+		pool:              s.pool,
+	}
+}
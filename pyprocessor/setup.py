@@ -2,7 +2,7 @@ from setuptools import setup, find_packages
 
 setup(
     name="rModel",
-    version="0.1.13",
+    version="0.1.16",
     author="Clay",
     author_email="clay.lan@outlook.com",
     description="A Python processor for rModel",
@@ -13,7 +13,7 @@ setup(
         "License :: OSI Approved :: MIT License",
         "Operating System :: OS Independent",
     ],
-    python_requires='>=3.6',
+    python_requires='>=3.7',
     install_requires=[
     ],
 )
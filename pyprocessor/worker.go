@@ -0,0 +1,358 @@
+package pyprocessor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultPythonCmd is the python command of workers, the neuron label python_cmd overrides it
+const DefaultPythonCmd = "/usr/bin/python3"
+
+// workerModule is the module of the rmodel Python package run by workers
+const workerModule = "rmodel.worker"
+
+// ErrWorkerPoolClosed is returned by the processors run in a closed WorkerPool
+var ErrWorkerPoolClosed = errors.New("python worker pool is closed")
+
+// WorkerPool runs Python processors in long-lived worker processes. A worker imports the module of a processor once,
+// and keeps the processor instances of the same class and constructor args warm between activations.
+// Workers are started on demand up to the size of pool, a worker runs one activation at a time,
+// and a worker which crashed or was cancelled is replaced by a new one.
+type WorkerPool struct {
+	pythonCmd  string
+	pythonPath []string
+	size       int
+
+	// slots limits the number of workers, idle keeps the started workers not in use
+	slots chan struct{}
+	idle  chan *worker
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// WorkerPoolOption configures a WorkerPool in NewWorkerPool.
+type WorkerPoolOption interface {
+	apply(pool *WorkerPool)
+}
+
+// optionFunc wraps a func, so it satisfies the WorkerPoolOption interface.
+type optionFunc func(*WorkerPool)
+
+func (f optionFunc) apply(pool *WorkerPool) {
+	f(pool)
+}
+
+// WithPythonCmd sets the python command of workers, DefaultPythonCmd by default
+func WithPythonCmd(pythonCmd string) WorkerPoolOption {
+	return optionFunc(func(pool *WorkerPool) {
+		pool.pythonCmd = pythonCmd
+	})
+}
+
+// WithPythonPath prepends dirs to the PYTHONPATH of workers, e.g. the directory of the rmodel package
+// which is not installed
+func WithPythonPath(dirs ...string) WorkerPoolOption {
+	return optionFunc(func(pool *WorkerPool) {
+		pool.pythonPath = append(pool.pythonPath, dirs...)
+	})
+}
+
+// NewWorkerPool creates a WorkerPool of at most size workers, one worker if size <= 0
+func NewWorkerPool(size int, opts ...WorkerPoolOption) *WorkerPool {
+	if size <= 0 {
+		size = 1
+	}
+	pool := &WorkerPool{
+		pythonCmd: DefaultPythonCmd,
+		size:      size,
+		slots:     make(chan struct{}, size),
+		idle:      make(chan *worker, size),
+	}
+	for _, opt := range opts {
+		opt.apply(pool)
+	}
+
+	return pool
+}
+
+// Size returns the maximum number of workers of pool
+func (p *WorkerPool) Size() int {
+	return p.size
+}
+
+// PythonCmd returns the python command of workers
+func (p *WorkerPool) PythonCmd() string {
+	return p.pythonCmd
+}
+
+// Close stops the idle workers, the workers in use are stopped when their activations return
+func (p *WorkerPool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	for {
+		select {
+		case w := <-p.idle:
+			w.stop()
+		default:
+			return nil
+		}
+	}
+}
+
+// call runs method in a worker of pool, it waits for a free worker until ctx is done
+func (p *WorkerPool) call(ctx context.Context, method string, params, result any, handler rpcHandler) error {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-p.slots }()
+
+	w, err := p.acquire()
+	if err != nil {
+		return err
+	}
+	err = w.call(ctx, method, params, result, handler)
+	p.release(w)
+
+	return err
+}
+
+// acquire returns an idle worker, or starts a new one, a slot of pool is held
+func (p *WorkerPool) acquire() (*worker, error) {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return nil, ErrWorkerPoolClosed
+	}
+
+	select {
+	case w := <-p.idle:
+		return w, nil
+	default:
+		return startWorker(p.pythonCmd, p.pythonPath)
+	}
+}
+
+// release returns w to pool, w is stopped if it is broken or pool is closed
+func (p *WorkerPool) release(w *worker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if w.broken || p.closed {
+		w.stop()
+		return
+	}
+	p.idle <- w
+}
+
+var (
+	defaultPoolsMu sync.Mutex
+	// defaultPools are the pools of python commands used by the processors without a pool
+	defaultPools = make(map[string]*WorkerPool)
+)
+
+// DefaultWorkerPool returns the pool of the processors with pythonCmd which are not given a pool,
+// it is created with a worker per CPU by the first call
+func DefaultWorkerPool(pythonCmd string) *WorkerPool {
+	defaultPoolsMu.Lock()
+	defer defaultPoolsMu.Unlock()
+	pool, ok := defaultPools[pythonCmd]
+	if !ok {
+		pool = NewWorkerPool(runtime.NumCPU(), WithPythonCmd(pythonCmd))
+		defaultPools[pythonCmd] = pool
+	}
+
+	return pool
+}
+
+// SetDefaultWorkerPool makes pool the default pool of its python command, e.g. to change the number of workers.
+// The replaced pool is closed.
+func SetDefaultWorkerPool(pool *WorkerPool) {
+	defaultPoolsMu.Lock()
+	old := defaultPools[pool.pythonCmd]
+	defaultPools[pool.pythonCmd] = pool
+	defaultPoolsMu.Unlock()
+
+	if old != nil && old != pool {
+		_ = old.Close()
+	}
+}
+
+// worker is a Python worker process, it is used by one activation at a time
+type worker struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	nextID int64
+	// output logs the output of the processors run by the worker
+	output *outputWriter
+	// broken indicates that the process is not usable any more, e.g. it crashed or an activation was cancelled
+	broken bool
+}
+
+func startWorker(pythonCmd string, pythonPath []string) (*worker, error) {
+	cmd := exec.Command(pythonCmd, "-u", "-m", workerModule)
+	if len(pythonPath) > 0 {
+		dirs := pythonPath
+		if env := os.Getenv("PYTHONPATH"); env != "" {
+			dirs = append(dirs[:len(dirs):len(dirs)], env)
+		}
+		cmd.Env = append(os.Environ(), "PYTHONPATH="+strings.Join(dirs, string(os.PathListSeparator)))
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to obtain standard input pipe: %s", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to obtain standard output pipe: %s", err)
+	}
+	// the output of processors is written to stderr by workers, stdout carries the protocol only
+	output := &outputWriter{}
+	cmd.Stderr = output
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Failed to start Python process: %s", err)
+	}
+
+	return &worker{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout), output: output}, nil
+}
+
+// call sends a request of method to the worker and waits for its response, the requests of the worker meanwhile
+// are served by handler. The worker is killed if ctx is done before the response,
+// as a running Python processor can not be interrupted
+func (w *worker) call(ctx context.Context, method string, params, result any, handler rpcHandler) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("Parameter serialization error: %s", err)
+	}
+	w.output.setLogger(loggerOf(ctx))
+	w.nextID++
+	id := w.nextID
+	if err = writeMessage(w.stdin, &rpcMessage{ID: &id, Method: method, Params: raw}); err != nil {
+		w.broken = true
+		return fmt.Errorf("unable to send %s to python worker: %v", method, err)
+	}
+
+	type reply struct {
+		msg *rpcMessage
+		err error
+	}
+	replies := make(chan reply, 1)
+	go func() {
+		for {
+			msg, err := readMessage(w.stdout)
+			if err != nil || (msg.Method == "" && msg.ID != nil && *msg.ID == id) {
+				replies <- reply{msg: msg, err: err}
+				return
+			}
+			if msg.Method == "" || msg.ID == nil {
+				continue
+			}
+			// a request of the Python side during the call, e.g. a memory request of the processor
+			var resp *rpcMessage
+			if handler == nil {
+				resp = replyMessage(msg.ID, nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", msg.Method)})
+			} else {
+				result, err := handler(msg.Method, msg.Params)
+				resp = replyMessage(msg.ID, result, err)
+			}
+			if err = writeMessage(w.stdin, resp); err != nil {
+				replies <- reply{err: err}
+				return
+			}
+		}
+	}()
+
+	var r reply
+	select {
+	case r = <-replies:
+	case <-ctx.Done():
+		w.broken = true
+		_ = w.cmd.Process.Kill()
+		<-replies
+		return ctx.Err()
+	}
+	if r.err != nil {
+		w.broken = true
+		return fmt.Errorf("Python process execution error: %v", r.err)
+	}
+	if r.msg.Error != nil {
+		return r.msg.Error
+	}
+	if result != nil && len(r.msg.Result) > 0 {
+		if err = json.Unmarshal(r.msg.Result, result); err != nil {
+			return fmt.Errorf("invalid result of %s: %v", method, err)
+		}
+	}
+
+	return nil
+}
+
+// stop closes the stdin of the worker, which makes it exit, a broken worker is killed
+func (w *worker) stop() {
+	_ = w.stdin.Close()
+	if w.broken {
+		_ = w.cmd.Process.Kill()
+	}
+	_ = w.cmd.Wait()
+}
+
+// defaultLogger logs the output of Python processors run outside a brain
+var defaultLogger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Logger()
+
+// loggerOf returns the logger of the brain running ctx, or defaultLogger if ctx does not know it
+func loggerOf(ctx context.Context) zerolog.Logger {
+	if l, ok := ctx.(interface{ GetLogger() zerolog.Logger }); ok {
+		return l.GetLogger()
+	}
+
+	return defaultLogger
+}
+
+// outputWriter logs the output of Python processors line by line, by the logger of the brain whose activation
+// the worker runs. The output written after an activation is logged by the logger of it, until the next one
+type outputWriter struct {
+	mu     sync.Mutex
+	logger zerolog.Logger
+	buf    []byte
+}
+
+func (o *outputWriter) setLogger(logger zerolog.Logger) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.logger = logger
+}
+
+func (o *outputWriter) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf = append(o.buf, p...)
+	for {
+		i := bytes.IndexByte(o.buf, '\n')
+		if i < 0 {
+			break
+		}
+		o.logger.Info().Str("output", string(o.buf[:i])).Msg("python processor")
+		o.buf = o.buf[i+1:]
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Rovanta/rmodel/artifact"
+	"github.com/Rovanta/rmodel/brainlite"
+)
+
+func TestArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	brain := brainlite.BuildBrain(newCounterBlueprint(), brainlite.WithDataDir(dir))
+	defer brain.Shutdown(context.Background())
+
+	if err := brain.PutArtifact("report", strings.NewReader("%PDF-1.7 ...")); err != nil {
+		t.Fatal(err)
+	}
+	// the reference is read back as artifact.Ref, it is registered in codec.Default
+	ref, ok := brain.GetMemory("report").(artifact.Ref)
+	if !ok || ref.Size != 12 {
+		t.Fatalf("expected reference of artifact, got %#v", brain.GetMemory("report"))
+	}
+
+	// a reference written by Python processors is the JSON view of artifact.Ref
+	_ = brain.SetMemory("from_python", map[string]any{"name": "from_python", "digest": ref.Digest, "size": 12})
+	r, err := brain.OpenArtifact("from_python")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if content, _ := io.ReadAll(r); string(content) != "%PDF-1.7 ..." {
+		t.Errorf("expected content of artifact, got %q", content)
+	}
+
+	if _, err = artifact.NewStore(dir).Open("sha256:../../etc/passwd"); err == nil {
+		t.Error("expected error of invalid digest")
+	}
+}
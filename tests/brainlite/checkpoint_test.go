@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlite"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	var crashed atomic.Bool
+	started := make(chan struct{})
+	block := make(chan struct{})
+	released := make(chan struct{})
+	// the crashed neuron writes memory when it is released, it is waited before the data dir is removed
+	defer func() {
+		close(block)
+		<-released
+	}()
+
+	// the blueprint is rebuilt for resume as a new process would do, IDs must be stable
+	newBlueprint := func() core.Blueprint {
+		bp := rModel.NewBlueprint()
+		greet := bp.AddNeuron(func(bc processor.BrainContext) error {
+			return bc.SetMemory("greeting", "hello")
+		}, core.WithNeuronID("greet"))
+		answer := bp.AddNeuron(func(bc processor.BrainContext) error {
+			if !crashed.Load() {
+				close(started)
+				defer close(released)
+				<-block // the process crashes while the neuron is running
+			}
+			greeting, _ := bc.GetMemory("greeting").(string)
+			return bc.SetMemory("answer", greeting+" world")
+		}, core.WithNeuronID("answer"))
+
+		_, _ = bp.AddEntryLinkTo(greet, core.WithLinkID("entry"))
+		_, _ = bp.AddLink(greet, answer, core.WithLinkID("greet-answer"))
+		_, _ = bp.AddEndLinkFrom(answer, core.WithLinkID("end"))
+		return bp
+	}
+
+	opts := []brainlite.Option{brainlite.WithDataDir(t.TempDir()), brainlite.WithKeepMemory()}
+	brain := brainlite.BuildBrain(newBlueprint(), append(opts, brainlite.WithID("resume-from-checkpoint"))...)
+	if err := brain.Entry(); err != nil {
+		t.Fatalf("entry error: %s", err)
+	}
+	<-started
+	crashed.Store(true)
+	// the process stops without waiting the running neuron, the checkpoint saved by shutdown has it activated
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = brain.Shutdown(ctx)
+
+	resumed, err := brainlite.Resume(newBlueprint(), "resume-from-checkpoint", opts...)
+	if err != nil {
+		t.Fatalf("resume error: %s", err)
+	}
+	defer resumed.Shutdown(context.Background())
+	resumed.Wait()
+
+	if answer, _ := resumed.GetMemory("answer").(string); answer != "hello world" {
+		t.Fatalf("unexpected answer after resume: %v", resumed.GetMemory("answer"))
+	}
+}
+
+func TestResumeWithoutCheckpoint(t *testing.T) {
+	if _, err := brainlite.Resume(rModel.NewBlueprint(), "brain-never-built"); err == nil {
+		t.Fatalf("expected error when resuming a brain without database")
+	}
+}
+
+func TestDuplicateIDs(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	greet := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return nil
+	}, core.WithNeuronID("greet"))
+	if _, err := bp.AddEntryLinkTo(greet, core.WithLinkID("entry")); err != nil {
+		t.Fatalf("add link error: %s", err)
+	}
+	if _, err := bp.AddEntryLinkTo(greet, core.WithLinkID("entry")); err == nil {
+		t.Error("expected error when adding a link with a duplicate ID")
+	}
+
+	if err := bp.Err(); err != nil {
+		t.Fatalf("unexpected blueprint error: %s", err)
+	}
+	bp.AddNeuron(func(bc processor.BrainContext) error {
+		return errors.New("duplicate neuron")
+	}, core.WithNeuronID("greet"))
+	if err := bp.Err(); err == nil {
+		t.Fatal("expected error when adding a neuron with a duplicate ID")
+	}
+	if n, _ := bp.GetNeuron("greet"); n != greet {
+		t.Error("expected the first neuron of ID kept")
+	}
+	if _, err := brainlite.Resume(bp, "duplicate-ids"); err == nil {
+		t.Error("expected error when resuming with an invalid blueprint")
+	}
+}
+
+func TestResumeSharedDatasourceURI(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	greet := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("greeting", "hello")
+	}, core.WithNeuronID("greet"))
+	_, _ = bp.AddEntryLinkTo(greet, core.WithLinkID("entry"))
+
+	// the datasource is a URI with a query string, which is not a file path
+	dsn := "file:" + filepath.Join(t.TempDir(), "brains.db") + "?_foreign_keys=1"
+	opts := []brainlite.Option{brainlite.WithDatasource(dsn), brainlite.WithKeepMemory()}
+	if _, err := brainlite.Resume(bp, "resume-uri", opts...); err == nil {
+		t.Fatal("expected error when resuming a brain without checkpoint")
+	}
+
+	brain := brainlite.BuildBrain(bp, append(opts, brainlite.WithID("resume-uri"))...)
+	_ = brain.Entry()
+	brain.Wait()
+	_ = brain.Shutdown(context.Background())
+
+	resumed, err := brainlite.Resume(bp, "resume-uri", opts...)
+	if err != nil {
+		t.Fatalf("resume error: %s", err)
+	}
+	defer resumed.Shutdown(context.Background())
+	if greeting := resumed.GetMemory("greeting"); greeting != "hello" {
+		t.Fatalf("unexpected memory after resume: %v", greeting)
+	}
+}
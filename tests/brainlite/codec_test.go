@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlite"
+	"github.com/Rovanta/rmodel/codec"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+type step struct {
+	Task   string
+	Result string
+}
+
+type pastSteps []step
+
+type plan struct {
+	Goal  string
+	Steps []string
+	Done  map[string]bool
+}
+
+func TestCodecRegistry(t *testing.T) {
+	for _, encoding := range []codec.Encoding{codec.JSON, codec.Gob, codec.Msgpack} {
+		t.Run(string(encoding), func(t *testing.T) {
+			registry := codec.NewRegistry()
+			if err := registry.Register("pastSteps", pastSteps{}, encoding); err != nil {
+				t.Fatal(err)
+			}
+			if err := registry.Register("plan", &plan{}, encoding); err != nil {
+				t.Fatal(err)
+			}
+
+			bp := rModel.NewBlueprint()
+			_, _ = bp.AddEntryLinkTo(bp.AddNeuron(func(bc processor.BrainContext) error { return nil }))
+			brain := brainlite.BuildBrain(bp, brainlite.WithCodecRegistry(registry))
+			defer brain.Shutdown(context.Background())
+
+			steps := pastSteps{{Task: "search", Result: "found"}, {Task: "answer", Result: "42"}}
+			p := &plan{Goal: "answer", Steps: []string{"search", "answer"}, Done: map[string]bool{"search": true}}
+			_ = brain.SetMemory("steps", steps, "plan", p)
+
+			if got, ok := brain.GetMemory("steps").(pastSteps); !ok || !reflect.DeepEqual(got, steps) {
+				t.Errorf("expected steps %v, got %#v", steps, brain.GetMemory("steps"))
+			}
+			if got, ok := brain.GetMemory("plan").(*plan); !ok || !reflect.DeepEqual(got, p) {
+				t.Errorf("expected plan %v, got %#v", p, brain.GetMemory("plan"))
+			}
+		})
+	}
+}
+
+func TestCodecUnregisteredType(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	_, _ = bp.AddEntryLinkTo(bp.AddNeuron(func(bc processor.BrainContext) error { return nil }))
+	brain := brainlite.BuildBrain(bp, brainlite.WithCodecRegistry(codec.NewRegistry()))
+	defer brain.Shutdown(context.Background())
+
+	_ = brain.SetMemory("step", step{Task: "search", Result: "found"})
+	expected := map[string]any{"Task": "search", "Result": "found"}
+	if got := brain.GetMemory("step"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected JSON view %v, got %#v", expected, got)
+	}
+}
+
+func TestCodecRegisterConflict(t *testing.T) {
+	registry := codec.NewRegistry()
+	if err := registry.Register("step", step{}, codec.JSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register("step", step{}, codec.Gob); err != nil {
+		t.Errorf("expected re-registering to change encoding, got %v", err)
+	}
+	if err := registry.Register("step", plan{}, codec.JSON); err == nil {
+		t.Error("expected error registering name with another type")
+	}
+	if err := registry.Register("other", step{}, codec.JSON); err == nil {
+		t.Error("expected error registering type with another name")
+	}
+	if err := registry.Register("plan", plan{}, codec.Encoding("xml")); err == nil {
+		t.Error("expected error of unknown encoding")
+	}
+}
@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlite"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func newCounterBlueprint() core.Blueprint {
+	bp := rModel.NewBlueprint()
+	n := bp.AddNeuron(func(bc processor.BrainContext) error {
+		runs, _ := bc.GetMemory("runs").(int)
+		return bc.SetMemory("runs", runs+1)
+	}, core.WithNeuronID("count"))
+	_, _ = bp.AddEntryLinkTo(n, core.WithLinkID("entry"))
+	_, _ = bp.AddEndLinkFrom(n, core.WithLinkID("end"))
+	return bp
+}
+
+func TestSharedDatasource(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "brains.db")
+	a := brainlite.BuildBrain(newCounterBlueprint(), brainlite.WithDatasource(dsn), brainlite.WithID("a"))
+	b := brainlite.BuildBrain(newCounterBlueprint(), brainlite.WithDatasource(dsn), brainlite.WithID("b"))
+	defer b.Shutdown(context.Background())
+
+	// brains write the same database concurrently
+	var wg sync.WaitGroup
+	for _, brain := range []*brainlite.BrainLite{a, b} {
+		wg.Add(1)
+		go func(brain *brainlite.BrainLite) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := brain.SetMemory(i, i); err != nil {
+					t.Errorf("set memory error: %s", err)
+					return
+				}
+			}
+		}(brain)
+	}
+	wg.Wait()
+
+	_ = a.SetMemory("name", "a")
+	_ = b.SetMemory("name", "b")
+	if name := a.GetMemory("name"); name != "a" {
+		t.Errorf("expected memory of brain a, got %v", name)
+	}
+	if keys, _ := b.ListMemoryKeys(); len(keys) != 51 {
+		t.Errorf("expected 51 keys of brain b, got %d", len(keys))
+	}
+
+	// rows of brain a are deleted, the database and brain b are kept
+	_ = a.Shutdown(context.Background())
+	if _, err := os.Stat(dsn); err != nil {
+		t.Fatalf("expected shared database kept: %s", err)
+	}
+	if name := b.GetMemory("name"); name != "b" {
+		t.Errorf("expected memory of brain b kept, got %v", name)
+	}
+	a = brainlite.BuildBrain(newCounterBlueprint(), brainlite.WithDatasource(dsn), brainlite.WithID("a"))
+	defer a.Shutdown(context.Background())
+	_ = a.SetMemory("runs", 0)
+	if a.ExistMemory("name") {
+		t.Error("expected memory of brain a deleted by shutdown")
+	}
+}
+
+func TestDataDirAndKeepMemory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	brain := brainlite.BuildBrain(newCounterBlueprint(),
+		brainlite.WithDataDir(dir), brainlite.WithID("keep"), brainlite.WithKeepMemory())
+	_ = brain.Entry()
+	brain.Wait()
+	_ = brain.Shutdown(context.Background())
+
+	if _, err := os.Stat(filepath.Join(dir, "keep.db")); err != nil {
+		t.Fatalf("expected database kept in data dir: %s", err)
+	}
+	resumed, err := brainlite.Resume(newCounterBlueprint(), "keep", brainlite.WithDataDir(dir))
+	if err != nil {
+		t.Fatalf("resume error: %s", err)
+	}
+	resumed.Wait()
+	if runs := resumed.GetMemory("runs"); runs != 1 {
+		t.Errorf("expected memory kept, got runs %v", runs)
+	}
+	_ = resumed.Shutdown(context.Background())
+
+	if _, err = os.Stat(filepath.Join(dir, "keep.db")); !os.IsNotExist(err) {
+		t.Errorf("expected database deleted without WithKeepMemory, got %v", err)
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "legacy.db")
+
+	// the schema of a database before migrations
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte("greeting"))
+	for _, stmt := range []string{
+		"CREATE TABLE memory (key INTEGER PRIMARY KEY, value JSON, type TEXT)",
+		"CREATE TABLE checkpoint_link (id TEXT PRIMARY KEY, state TEXT)",
+		"CREATE TABLE checkpoint_neuron (id TEXT PRIMARY KEY, state TEXT)",
+		"CREATE TABLE checkpoint_pending (queue TEXT, kind TEXT, action TEXT, id TEXT, count INTEGER)",
+		fmt.Sprintf(`INSERT INTO memory (key, value, type) VALUES (%d, '"hello"', 'string')`,
+			int64(binary.BigEndian.Uint64(hash[:8]))),
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = db.Close()
+
+	brain := brainlite.BuildBrain(newCounterBlueprint(),
+		brainlite.WithDataDir(dir), brainlite.WithID("legacy"), brainlite.WithKeepMemory())
+	_ = brain.SetMemory("runs", 0)
+	if greeting := brain.GetMemory("greeting"); greeting != "hello" {
+		t.Errorf("expected memory of legacy database, got %v", greeting)
+	}
+	_ = brain.Shutdown(context.Background())
+
+	db, err = sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var version int
+	if err = db.QueryRow("PRAGMA user_version").Scan(&version); err != nil || version != 4 {
+		t.Errorf("expected schema version 4, got %d (%v)", version, err)
+	}
+}
+
+func TestMigrateKeyHashes(t *testing.T) {
+	dir := t.TempDir()
+	brain := brainlite.BuildBrain(newCounterBlueprint(),
+		brainlite.WithDataDir(dir), brainlite.WithID("hashes"), brainlite.WithKeepMemory())
+	_ = brain.SetMemory("greeting", "hello", 7, "seven")
+	_ = brain.Shutdown(context.Background())
+
+	// keys were hashed by their texts only before schema version 4
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "hashes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"greeting", "7"} {
+		hash := sha256.Sum256([]byte(text))
+		if _, err = db.Exec("UPDATE memory SET key = ? WHERE key_text = ?", int64(binary.BigEndian.Uint64(hash[:8])), text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = db.Exec("PRAGMA user_version = 3"); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	brain = brainlite.BuildBrain(newCounterBlueprint(),
+		brainlite.WithDataDir(dir), brainlite.WithID("hashes"), brainlite.WithKeepMemory())
+	defer brain.Shutdown(context.Background())
+	// memory is opened by the first write
+	_ = brain.SetMemory("runs", 0)
+	if greeting, seven := brain.GetMemory("greeting"), brain.GetMemory(7); greeting != "hello" || seven != "seven" {
+		t.Errorf("expected memories rehashed, got %v, %v", greeting, seven)
+	}
+	// "7" was the same memory as 7 before
+	if brain.ExistMemory("7") {
+		t.Errorf("expected no memory of string key 7")
+	}
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Rovanta/rmodel/brainlite"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/codec"
+)
+
+const memoryFixture = `{
+  "version": 1,
+  "memories": [
+    {"key": "7", "key_type": "int", "type": "string", "value": "int key"},
+    {"key": "plan", "key_type": "string", "type": "json", "type_name": "plan", "value": {"Goal": "answer", "Steps": ["search"], "Done": null}},
+    {"key": "question", "key_type": "string", "type": "string", "value": "hi"},
+    {"key": "turns", "key_type": "string", "type": "int", "value": 2}
+  ]
+}`
+
+func TestImportMemoryFixture(t *testing.T) {
+	registry := codec.NewRegistry()
+	_ = registry.Register("plan", &plan{}, codec.Gob)
+	brain := brainlite.BuildBrain(newCounterBlueprint(), brainlite.WithCodecRegistry(registry))
+	defer brain.Shutdown(context.Background())
+
+	if err := brain.ImportMemory(strings.NewReader(memoryFixture)); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := brain.GetMemory("plan").(*plan); !ok || p.Goal != "answer" {
+		t.Errorf("expected plan decoded to its type, got %#v", brain.GetMemory("plan"))
+	}
+	if brain.GetMemory(7) != "int key" || brain.GetMemory("turns") != 2 {
+		t.Errorf("unexpected memory %v, %v", brain.GetMemory(7), brain.GetMemory("turns"))
+	}
+
+	// the document of BrainLite is read by BrainLocal
+	var doc bytes.Buffer
+	if err := brain.ExportMemory(&doc); err != nil {
+		t.Fatal(err)
+	}
+	local := brainlocal.BuildBrain(newCounterBlueprint())
+	defer local.Shutdown(context.Background())
+	if err := local.ImportMemory(&doc); err != nil {
+		t.Fatal(err)
+	}
+	dump, _ := local.DumpMemory()
+	expected := map[any]any{
+		7:          "int key",
+		"plan":     map[string]any{"Goal": "answer", "Steps": []any{"search"}, "Done": nil},
+		"question": "hi",
+		"turns":    2,
+	}
+	if !reflect.DeepEqual(dump, expected) {
+		t.Errorf("expected memory %v, got %v", expected, dump)
+	}
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlite"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func newApprovalBlueprint() core.Blueprint {
+	bp := rModel.NewBlueprint()
+	draft := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("draft", "hi")
+	}, core.WithNeuronID("draft"))
+	send := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("sent", bc.GetMemory("draft"))
+	}, core.WithNeuronID("send"), core.WithInterruptBefore())
+	_, _ = bp.AddEntryLinkTo(draft, core.WithLinkID("entry"))
+	_, _ = bp.AddLink(draft, send, core.WithLinkID("draft-send"))
+	_, _ = bp.AddEndLinkFrom(send, core.WithLinkID("end"))
+
+	return bp
+}
+
+func TestInterruptSurvivesRestart(t *testing.T) {
+	brain := brainlite.BuildBrain(newApprovalBlueprint(), brainlite.WithID("interrupt-survives-restart"))
+	_ = brain.Entry()
+	brain.Wait()
+	if state := brain.GetState(); state != core.BrainStateInterrupted {
+		t.Fatalf("expected brain interrupted, got %s", state)
+	}
+
+	// the process restarts while waiting for approval
+	resumed, err := brainlite.Resume(newApprovalBlueprint(), "interrupt-survives-restart")
+	if err != nil {
+		t.Fatalf("resume brain error: %s", err)
+	}
+	resumed.Wait()
+	interrupts := resumed.ListInterrupts()
+	if len(interrupts) != 1 || interrupts[0].NeuronID != "send" {
+		t.Fatalf("unexpected interrupts after restart: %+v", interrupts)
+	}
+	if err = resumed.Resume(interrupts[0].RunID, "draft", "hello"); err != nil {
+		t.Fatalf("resume run error: %s", err)
+	}
+
+	resumed.Wait()
+	if sent := resumed.GetMemory("sent"); sent != "hello" {
+		t.Fatalf("unexpected sent: %v", sent)
+	}
+	resumed.Shutdown(context.Background())
+}
+
+func TestResumeConcurrently(t *testing.T) {
+	bp := newApprovalBlueprint()
+	// the memory of Resume is set slowly, the others try to resume the run meanwhile
+	bp.SetMemoryReducer("approval", func(current, update any) (any, error) {
+		time.Sleep(20 * time.Millisecond)
+		return update, nil
+	})
+	brain := brainlite.BuildBrain(bp)
+	defer brain.Shutdown(context.Background())
+	_ = brain.Entry()
+	brain.Wait()
+	runID := brain.ListInterrupts()[0].RunID
+
+	// the interrupt points are resumed once
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	start := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			if err := brain.Resume(runID, "approval", i); err == nil {
+				succeeded.Add(1)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	if n := succeeded.Load(); n != 1 {
+		t.Fatalf("expected one Resume succeeded, got %d", n)
+	}
+	brain.Wait()
+	if !brain.ExistMemory("approval") {
+		t.Fatal("expected memory of Resume set")
+	}
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlite"
+	"github.com/Rovanta/rmodel/internal/utils"
+	"github.com/Rovanta/rmodel/processor"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type memoryKey string
+
+func TestListMemoryKeys(t *testing.T) {
+	var listed []any
+	bp := rModel.NewBlueprint()
+	_, _ = bp.AddEntryLinkTo(bp.AddNeuron(func(bc processor.BrainContext) error {
+		var err error
+		listed, err = bc.ListMemoryKeys()
+		return err
+	}))
+	brain := brainlite.BuildBrain(bp)
+	defer brain.Shutdown(context.Background())
+
+	_ = brain.SetMemory("name", "rmodel", 7, 1.5, true, "yes", memoryKey("typed"), []any{"a"})
+	_ = brain.Entry()
+	brain.Wait()
+
+	expected := []any{true, 7, "name", "typed"}
+	if !reflect.DeepEqual(listed, expected) {
+		t.Fatalf("expected keys %v, got %v", expected, listed)
+	}
+
+	dump, err := brain.DumpMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedDump := map[any]any{"name": "rmodel", 7: 1.5, true: "yes", "typed": []any{"a"}}
+	if !reflect.DeepEqual(dump, expectedDump) {
+		t.Errorf("expected dump %v, got %v", expectedDump, dump)
+	}
+
+	ranged := 0
+	_ = brain.RangeMemory(func(key, value any) bool {
+		ranged++
+		// fn may write memory
+		_ = brain.SetMemory(key, value)
+		return ranged < 2
+	})
+	if ranged != 2 {
+		t.Errorf("expected range to stop after 2 memories, got %d", ranged)
+	}
+}
+
+func TestMemoryKeyCollision(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	_, _ = bp.AddEntryLinkTo(bp.AddNeuron(func(bc processor.BrainContext) error { return nil }))
+	brainID := utils.GenID()
+	brain := brainlite.BuildBrain(bp, brainlite.WithID(brainID))
+	defer brain.Shutdown(context.Background())
+	_ = brain.SetMemory("a", 1)
+
+	// a colliding key is simulated by rewriting the original key of the row of "a"
+	db, err := sql.Open("sqlite3", brainID+".db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err = db.Exec("UPDATE memory SET key_text = 'b' WHERE key_text = 'a'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = brain.SetMemory("a", 2); err == nil {
+		t.Error("expected error setting a colliding key")
+	}
+	if brain.ExistMemory("a") {
+		t.Error("expected colliding key not found")
+	}
+	brain.DeleteMemory("a")
+	if keys, _ := brain.ListMemoryKeys(); !reflect.DeepEqual(keys, []any{"b"}) {
+		t.Errorf("expected memory of the other key kept, got keys %v", keys)
+	}
+}
+
+func TestMemoryKeysOfDifferentTypes(t *testing.T) {
+	brain := brainlite.BuildBrain(rModel.NewBlueprint())
+	defer brain.Shutdown(context.Background())
+
+	// the keys have the same text, but they are different memories
+	if err := brain.SetMemory(1, "int", "1", "string", 1.0, "float", true, "bool", "true", "string true"); err != nil {
+		t.Fatalf("set memory error: %s", err)
+	}
+	for key, expected := range map[any]any{1: "int", "1": "string", 1.0: "float", true: "bool", "true": "string true"} {
+		if v := brain.GetMemory(key); v != expected {
+			t.Errorf("expected memory of %T %v is %v, got %v", key, key, expected, v)
+		}
+	}
+
+	brain.DeleteMemory("1")
+	if brain.ExistMemory("1") || !brain.ExistMemory(1) || !brain.ExistMemory(1.0) {
+		t.Errorf("expected only memory of string key deleted")
+	}
+	keys, _ := brain.ListMemoryKeys()
+	if expected := []any{true, 1.0, 1, "true"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected keys %v, got %v", expected, keys)
+	}
+}
+
+func TestBytesMemoryKey(t *testing.T) {
+	brain := brainlite.BuildBrain(rModel.NewBlueprint())
+	defer brain.Shutdown(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := brain.WatchMemory(ctx, []byte("token"))
+
+	if err := brain.SetMemory([]byte("token"), "bytes", "token", "string"); err != nil {
+		t.Fatalf("set memory error: %s", err)
+	}
+	if v := brain.GetMemory([]byte("token")); v != "bytes" {
+		t.Errorf("expected memory of bytes key, got %v", v)
+	}
+	if v := brain.GetMemory("token"); v != "string" {
+		t.Errorf("expected memory of string key apart from bytes key, got %v", v)
+	}
+	if c := <-changes; !reflect.DeepEqual(c.Key, []byte("token")) || c.NewValue != "bytes" {
+		t.Errorf("unexpected change of bytes key: %+v", c)
+	}
+
+	keys, _ := brain.ListMemoryKeys()
+	if expected := []any{[]byte("token"), "token"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected keys %v, got %v", expected, keys)
+	}
+	brain.DeleteMemory([]byte("token"))
+	if brain.ExistMemory([]byte("token")) || !brain.ExistMemory("token") {
+		t.Errorf("expected only memory of bytes key deleted")
+	}
+}
@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel/brainlite"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/memstore"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := memstore.NewMap()
+	// the store holds a memory before brain opens it
+	_ = store.Set("question", "q")
+	brain := brainlite.BuildBrain(newCounterBlueprint(), brainlite.WithKeepMemory(),
+		brainlite.WithMemoryStore(func() (core.MemoryStore, error) {
+			return store, nil
+		}))
+
+	if question := brain.GetMemory("question"); question != "q" {
+		t.Fatalf("expected memory held by store, got %v", question)
+	}
+	_ = brain.Entry()
+	brain.Wait()
+	if runs, ok, _ := store.Get("runs"); !ok || runs != 1 {
+		t.Fatalf("expected memory written to store, got %v", runs)
+	}
+	// keys of named types are kept as the basic types
+	type name string
+	_ = brain.SetMemory(name("answer"), "a")
+	if answer, _, _ := store.Get("answer"); answer != "a" {
+		t.Fatalf("expected key of named type kept as string, got %v", answer)
+	}
+
+	keys, err := brain.ListMemoryKeys()
+	if err != nil {
+		t.Fatalf("list memory keys error: %s", err)
+	}
+	if expected := []any{"answer", "question", "runs"}; !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected keys %v, got %v", expected, keys)
+	}
+	brain.DeleteMemory("question")
+	if _, ok, _ := store.Get("question"); ok {
+		t.Fatal("expected memory deleted from store")
+	}
+
+	// the memories are kept in store by WithKeepMemory
+	_ = brain.Shutdown(context.Background())
+	if runs, ok, _ := store.Get("runs"); !ok || runs != 1 {
+		t.Fatalf("expected memory kept after shutdown, got %v", runs)
+	}
+}
+
+func TestMemoryStoreTTL(t *testing.T) {
+	store := memstore.NewMap()
+	brain := brainlite.BuildBrain(newCounterBlueprint(), brainlite.WithMemorySweepInterval(20*time.Millisecond),
+		brainlite.WithMemoryStore(func() (core.MemoryStore, error) {
+			return store, nil
+		}))
+	defer brain.Shutdown(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := brain.WatchMemory(ctx, "session")
+	_ = brain.SetMemoryWithTTL("session", "s", 50*time.Millisecond)
+
+	timeout := time.After(time.Second)
+	for expired := false; !expired; {
+		select {
+		case c := <-changes:
+			expired = c.Expired
+		case <-timeout:
+			t.Fatal("expected memory expired")
+		}
+	}
+	if _, ok, _ := store.Get("session"); ok {
+		t.Error("expected expired memory deleted from store")
+	}
+	if n := brain.ExpiredMemoryCount(); n != 1 {
+		t.Errorf("expected 1 expired memory, got %d", n)
+	}
+}
+
+// failingStore fails to set the memory of key bad
+type failingStore struct {
+	*memstore.Map
+}
+
+func (s failingStore) Set(key, value any) error {
+	if key == "bad" {
+		return errors.New("store failed")
+	}
+
+	return s.Map.Set(key, value)
+}
+
+func TestMemoryStoreRollback(t *testing.T) {
+	store := failingStore{Map: memstore.NewMap()}
+	brain := brainlite.BuildBrain(newCounterBlueprint(), brainlite.WithMemoryStore(func() (core.MemoryStore, error) {
+		return store, nil
+	}))
+	defer brain.Shutdown(context.Background())
+	_ = brain.SetMemory("from", 10)
+
+	err := brain.UpdateMemory(func(tx processor.MemoryTx) error {
+		return tx.SetMemory("from", 5, "to", 5, "bad", 0)
+	})
+	if err == nil {
+		t.Fatal("expected error of store")
+	}
+	if brain.GetMemory("from") != 10 || brain.ExistMemory("to") {
+		t.Fatalf("expected memories rolled back, got from %v, to %v", brain.GetMemory("from"), brain.GetMemory("to"))
+	}
+}
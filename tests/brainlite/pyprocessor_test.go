@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlite"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+	"github.com/Rovanta/rmodel/pyprocessor"
+)
+
+const countProcessor = `from rmodel import Processor
+
+
+class CountProcessor(Processor):
+    def __init__(self, key):
+        self.key = key
+        self.calls = 0
+
+    def process(self, ctx):
+        self.calls += 1
+        print(f"{ctx.get_current_neuron_id()}: call {self.calls}")
+        ctx.set_memory(self.key, self.calls)
+
+
+class FailProcessor(Processor):
+    def process(self, ctx):
+        raise ValueError("no luck")
+`
+
+// newWorkerPool returns a pool running the rmodel package of this repository and the processors in dir
+func newWorkerPool(t *testing.T, size int, dir string) *pyprocessor.WorkerPool {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+	pkg, err := filepath.Abs("../../pyprocessor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "count_processor.py"), []byte(countProcessor), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pool := pyprocessor.NewWorkerPool(size, pyprocessor.WithPythonCmd(python), pyprocessor.WithPythonPath(pkg, dir))
+	t.Cleanup(func() { _ = pool.Close() })
+	return pool
+}
+
+func TestPythonWorkers(t *testing.T) {
+	dir := t.TempDir()
+	// one worker runs the parallel neurons in turn, the neurons of the same class and args share an instance in it,
+	// so count is set by its second call
+	pool := newWorkerPool(t, 1, dir)
+
+	bp := rModel.NewMultiLangBlueprint()
+	newCount := func(key, id string) core.Neuron {
+		p := pyprocessor.LoadPythonProcessorWithPool(pool, "", "count_processor", "CountProcessor", map[string]interface{}{"key": key})
+		return bp.AddNeuronWithProcessor(p, core.WithNeuronID(id))
+	}
+	first, second, other := newCount("count", "first"), newCount("count", "second"), newCount("other", "other")
+	_, _ = bp.AddEntryLinkTo(first)
+	_, _ = bp.AddEntryLinkTo(other)
+	_, _ = bp.AddLink(first, second)
+
+	brain := brainlite.BuildMultiLangBrain(bp, brainlite.WithDataDir(dir))
+	defer brain.Shutdown(context.Background())
+	if err := brain.Entry(); err != nil {
+		t.Fatal(err)
+	}
+	brain.Wait()
+
+	if count := brain.GetMemory("count"); count != 2 {
+		t.Errorf("expected count 2 of the warm instance, got %v", count)
+	}
+	if other := brain.GetMemory("other"); other != 1 {
+		t.Errorf("expected other 1, got %v", other)
+	}
+	if _, err := os.Stat("temp_exec_script.py"); !os.IsNotExist(err) {
+		t.Error("expected no script written to the working directory")
+	}
+}
+
+func TestPythonWorkerError(t *testing.T) {
+	dir := t.TempDir()
+	pool := newWorkerPool(t, 1, dir)
+	fail := pyprocessor.LoadPythonProcessorWithPool(pool, "", "count_processor", "FailProcessor", nil)
+	count := pyprocessor.LoadPythonProcessorWithPool(pool, "", "count_processor", "CountProcessor", map[string]interface{}{"key": "count"})
+
+	var failErr, countErr error
+	bp := rModel.NewBlueprint()
+	n := bp.AddNeuron(func(bc processor.BrainContext) error {
+		failErr = fail.Process(bc)
+		// the worker survives the exception of processor
+		countErr = count.Process(bc)
+		return nil
+	})
+	_, _ = bp.AddEntryLinkTo(n)
+
+	brain := brainlite.BuildBrain(bp, brainlite.WithDataDir(dir))
+	defer brain.Shutdown(context.Background())
+	if err := brain.Entry(); err != nil {
+		t.Fatal(err)
+	}
+	brain.Wait()
+
+	if failErr == nil || !strings.Contains(failErr.Error(), "ValueError: no luck") {
+		t.Errorf("expected error of the failed processor, got %v", failErr)
+	}
+	if countErr != nil {
+		t.Fatal(countErr)
+	}
+	if v := brain.GetMemory("count"); v != 1 {
+		t.Errorf("expected count 1, got %v", v)
+	}
+
+	_ = pool.Close()
+	_ = brain.Entry()
+	brain.Wait()
+	if !errors.Is(countErr, pyprocessor.ErrWorkerPoolClosed) {
+		t.Errorf("expected ErrWorkerPoolClosed, got %v", countErr)
+	}
+}
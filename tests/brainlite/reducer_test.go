@@ -0,0 +1,38 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlite"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestMemoryReducers(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	bp.SetMemoryReducer("past_steps", core.AppendReducer())
+	bp.SetMemoryReducer("steps", core.SumReducer())
+
+	const parallel = 4
+	for i := 0; i < parallel; i++ {
+		n := bp.AddNeuron(func(bc processor.BrainContext) error {
+			return bc.SetMemory("past_steps", bc.GetCurrentNeuronID(), "steps", 1)
+		})
+		_, _ = bp.AddEntryLinkTo(n)
+	}
+
+	brain := brainlite.BuildBrain(bp, brainlite.WithNeuronWorkerNum(parallel))
+	defer brain.Shutdown(context.Background())
+	_ = brain.Entry()
+	brain.Wait()
+
+	if steps := brain.GetMemory("steps"); steps != parallel {
+		t.Fatalf("unexpected sum: %v", steps)
+	}
+	// values are stored as JSON, the slice is []any
+	if pastSteps, _ := brain.GetMemory("past_steps").([]any); len(pastSteps) != parallel {
+		t.Fatalf("expected %d past steps, got %v", parallel, brain.GetMemory("past_steps"))
+	}
+}
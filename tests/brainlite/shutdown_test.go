@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlite"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestShutdownAndRestart(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	n := bp.AddNeuron(func(bc processor.BrainContext) error {
+		runs, _ := bc.GetMemory("runs").(int)
+		return bc.SetMemory("runs", runs+1)
+	})
+	_, _ = bp.AddEntryLinkTo(n)
+	_, _ = bp.AddEndLinkFrom(n)
+
+	brain := brainlite.BuildBrain(bp)
+	// brain never started
+	if err := brain.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown error: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := brain.Entry(); err != nil {
+			t.Fatalf("entry error: %s", err)
+		}
+		brain.Wait()
+		// memory is removed with database when brain is shut down
+		if runs := brain.GetMemory("runs"); runs != 1 {
+			t.Fatalf("unexpected runs: %v", runs)
+		}
+		for j := 0; j < 2; j++ {
+			if err := brain.Shutdown(context.Background()); err != nil {
+				t.Fatalf("shutdown error: %s", err)
+			}
+		}
+	}
+}
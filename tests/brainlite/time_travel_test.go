@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlite"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestForkFromSnapshot(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	prepare := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("query", "bad query")
+	})
+	tool := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("result", fmt.Sprintf("result of %s", bc.GetMemory("query")))
+	})
+	_, _ = bp.AddEntryLinkTo(prepare)
+	toolLink, _ := bp.AddLink(prepare, tool)
+	_, _ = bp.AddEndLinkFrom(tool)
+
+	brain := brainlite.BuildBrain(bp, brainlite.WithSnapshots(0))
+	_ = brain.Entry()
+	brain.Wait()
+	if result := brain.GetMemory("result"); result != "result of bad query" {
+		t.Fatalf("unexpected result: %v", result)
+	}
+
+	snapshots, err := brain.ListSnapshots()
+	if err != nil {
+		t.Fatalf("list snapshots error: %s", err)
+	}
+	// rewind to the step just before the tool is called
+	snapshotID := 0
+	for _, snap := range snapshots {
+		if snap.Step == fmt.Sprintf("link link_ready %s", toolLink.GetID()) {
+			snapshotID = snap.ID
+		}
+	}
+	if snapshotID == 0 {
+		t.Fatalf("snapshot before tool call not found in %d snapshots", len(snapshots))
+	}
+
+	forked, err := brain.Fork(snapshotID)
+	if err != nil {
+		t.Fatalf("fork error: %s", err)
+	}
+	if forked.GetMemory("result") != nil {
+		t.Fatalf("forked brain should not have the result of tool")
+	}
+	_ = forked.SetMemory("query", "good query")
+	if err = forked.Continue(); err != nil {
+		t.Fatalf("continue error: %s", err)
+	}
+	forked.Wait()
+
+	if result := forked.GetMemory("result"); result != "result of good query" {
+		t.Fatalf("unexpected result of forked brain: %v", result)
+	}
+	if result := brain.GetMemory("result"); result != "result of bad query" {
+		t.Fatalf("origin brain should not be changed by fork: %v", result)
+	}
+	forked.Shutdown(context.Background())
+	brain.Shutdown(context.Background())
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel/brainlite"
+)
+
+func TestMemoryTTL(t *testing.T) {
+	brain := brainlite.BuildBrain(newCounterBlueprint(), brainlite.WithMemorySweepInterval(20*time.Millisecond))
+	defer brain.Shutdown(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := brain.WatchMemory(ctx, "session")
+
+	if err := brain.SetMemoryWithTTL("session", "s", -time.Second); err == nil {
+		t.Error("expected error of negative ttl")
+	}
+	_ = brain.SetMemoryWithTTL("session", "s", 50*time.Millisecond)
+	_ = brain.SetMemoryWithTTL("token", "t", 50*time.Millisecond)
+	// set again without TTL, it never expires
+	_ = brain.SetMemory("token", "t2")
+	if session := brain.GetMemory("session"); session != "s" {
+		t.Fatalf("expected memory before expiry, got %v", session)
+	}
+
+	timeout := time.After(time.Second)
+	for expired := false; !expired; {
+		select {
+		case c := <-changes:
+			if !c.Expired {
+				continue
+			}
+			if c.OldValue != "s" || c.NewValue != nil {
+				t.Errorf("unexpected expiry: %+v", c)
+			}
+			expired = true
+		case <-timeout:
+			t.Fatal("expected memory expired")
+		}
+	}
+	if brain.ExistMemory("session") {
+		t.Error("expected expired memory deleted")
+	}
+	if token := brain.GetMemory("token"); token != "t2" {
+		t.Errorf("expected memory set without TTL kept, got %v", token)
+	}
+	if n := brain.ExpiredMemoryCount(); n != 1 {
+		t.Errorf("expected 1 expired memory, got %d", n)
+	}
+}
+
+func TestMemoryTTLLazy(t *testing.T) {
+	brain := brainlite.BuildBrain(newCounterBlueprint(), brainlite.WithMemorySweepInterval(time.Hour))
+	defer brain.Shutdown(context.Background())
+
+	_ = brain.SetMemoryWithTTL("session", "s", 20*time.Millisecond)
+	_ = brain.SetMemory("question", "hi")
+	time.Sleep(40 * time.Millisecond)
+
+	// the expired memory is not read before the sweeper deletes it
+	if brain.GetMemory("session") != nil {
+		t.Error("expected expired memory not read")
+	}
+	if keys, _ := brain.ListMemoryKeys(); len(keys) != 1 || keys[0] != "question" {
+		t.Errorf("expected expired memory not listed, got %v", keys)
+	}
+	// an expired key is set again
+	_ = brain.SetMemory("session", "new")
+	if session := brain.GetMemory("session"); session != "new" {
+		t.Errorf("expected memory set again, got %v", session)
+	}
+	if n := brain.ExpiredMemoryCount(); n != 0 {
+		t.Errorf("expected no memory swept, got %d", n)
+	}
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlite"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestCompareAndSwapMemory(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	const parallel = 4
+	for i := 0; i < parallel; i++ {
+		n := bp.AddNeuron(func(bc processor.BrainContext) error {
+			// increase the counter by CAS retries
+			for {
+				count, _ := bc.GetMemory("count").(int)
+				var old any
+				if bc.ExistMemory("count") {
+					old = count
+				}
+				swapped, err := bc.CompareAndSwapMemory("count", old, count+1)
+				if err != nil || swapped {
+					return err
+				}
+			}
+		})
+		_, _ = bp.AddEntryLinkTo(n)
+	}
+
+	brain := brainlite.BuildBrain(bp, brainlite.WithNeuronWorkerNum(parallel))
+	defer brain.Shutdown(context.Background())
+	_ = brain.Entry()
+	brain.Wait()
+
+	if count := brain.GetMemory("count"); count != parallel {
+		t.Fatalf("expected count %d, got %v", parallel, count)
+	}
+}
+
+func TestUpdateMemoryRollback(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	_, _ = bp.AddEntryLinkTo(bp.AddNeuron(func(bc processor.BrainContext) error { return nil }))
+	brain := brainlite.BuildBrain(bp)
+	defer brain.Shutdown(context.Background())
+	_ = brain.SetMemory("from", 10, "to", 0)
+
+	errRollback := errors.New("rollback")
+	err := brain.UpdateMemory(func(tx processor.MemoryTx) error {
+		_ = tx.SetMemory("from", 5, "to", 5)
+		tx.DeleteMemory("from")
+		if tx.ExistMemory("from") || tx.GetMemory("to") != 5 {
+			t.Error("expected transaction sees its own writes")
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("expected rollback error, got %v", err)
+	}
+	if brain.GetMemory("from") != 10 || brain.GetMemory("to") != 0 {
+		t.Fatalf("expected memories not changed, got from %v, to %v", brain.GetMemory("from"), brain.GetMemory("to"))
+	}
+}
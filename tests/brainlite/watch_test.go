@@ -0,0 +1,37 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlite"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestWatchMemory(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	n := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("answer", "hello")
+	})
+	_, _ = bp.AddEntryLinkTo(n)
+	_, _ = bp.AddEndLinkFrom(n)
+
+	brain := brainlite.BuildBrain(bp)
+	defer brain.Shutdown(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	changes := brain.WatchMemory(ctx, "answer")
+
+	_ = brain.Entry()
+	brain.Wait()
+	brain.ClearMemory()
+
+	if c := <-changes; c.Key != "answer" || c.NewValue != "hello" || c.NeuronID != n.GetID() {
+		t.Fatalf("unexpected change: %+v", c)
+	}
+	if c := <-changes; !c.Cleared {
+		t.Fatalf("expected memory cleared, got %+v", c)
+	}
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/artifact"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestArtifacts(t *testing.T) {
+	image := bytes.Repeat([]byte{0x89, 'P', 'N', 'G'}, 1024)
+	bp := rModel.NewBlueprint()
+	n := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.PutArtifact("image", bytes.NewReader(image))
+	})
+	_, _ = bp.AddEntryLinkTo(n)
+	_, _ = bp.AddEndLinkFrom(n)
+
+	dir := t.TempDir()
+	brain := brainlocal.BuildBrain(bp, brainlocal.WithArtifactDir(dir))
+	defer brain.Shutdown(context.Background())
+	_ = brain.Entry()
+	brain.Wait()
+
+	// memory keeps the reference only
+	ref, ok := brain.GetMemory("image").(artifact.Ref)
+	if !ok || ref.Name != "image" || ref.Size != int64(len(image)) {
+		t.Fatalf("expected reference of artifact, got %#v", brain.GetMemory("image"))
+	}
+	r, err := brain.OpenArtifact("image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(r)
+	_ = r.Close()
+	if !bytes.Equal(content, image) {
+		t.Error("expected content of artifact")
+	}
+
+	// the same content is kept once
+	if err = brain.PutArtifact("copy", bytes.NewReader(image)); err != nil {
+		t.Fatal(err)
+	}
+	if copyRef, _ := brain.GetMemory("copy").(artifact.Ref); copyRef.Digest != ref.Digest {
+		t.Errorf("expected the same digest, got %s and %s", copyRef.Digest, ref.Digest)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "sha256", "*", "*"))
+	if len(files) != 1 {
+		t.Errorf("expected one artifact file, got %v", files)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected no temporary file left, got %d entries", len(entries))
+	}
+
+	_ = brain.SetMemory("text", "not an artifact")
+	if _, err = brain.OpenArtifact("text"); err == nil {
+		t.Error("expected error opening memory which is not an artifact")
+	}
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestExplainStuckRun(t *testing.T) {
+	nop := func(bc processor.BrainContext) error { return nil }
+	bp := rModel.NewBlueprint()
+	// route selects one of search and answer, but join waits both of them
+	route := bp.AddNeuron(nop, core.WithNeuronID("route"), core.WithSelectFn(func(bcr processor.BrainContextReader) string {
+		return "search"
+	}))
+	search := bp.AddNeuron(nop, core.WithNeuronID("search"))
+	answer := bp.AddNeuron(nop, core.WithNeuronID("answer"))
+	join := bp.AddNeuron(nop, core.WithNeuronID("join"))
+	_, _ = bp.AddEntryLinkTo(route)
+	toSearch, _ := bp.AddLink(route, search, core.WithLinkID("route-search"))
+	toAnswer, _ := bp.AddLink(route, answer, core.WithLinkID("route-answer"))
+	searchJoin, _ := bp.AddLink(search, join, core.WithLinkID("search-join"))
+	answerJoin, _ := bp.AddLink(answer, join, core.WithLinkID("answer-join"))
+	_, _ = bp.AddEndLinkFrom(join)
+	_ = route.AddCastGroup("search", toSearch)
+	_ = route.AddCastGroup("answer", toAnswer)
+	_ = join.AddTriggerGroup(searchJoin, answerJoin)
+
+	brain := brainlocal.BuildBrain(bp)
+	defer brain.Shutdown(context.Background())
+
+	blocked := brain.Explain().BlockedTriggerGroups
+	if len(blocked) != 1 || blocked[0].NeuronID != "join" {
+		t.Fatalf("expected trigger group of join blocked, got %+v", blocked)
+	}
+
+	// join waits answer-join forever, nothing is processing
+	_ = brain.Entry()
+	var exp core.Explanation
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if exp = brain.Explain(); len(exp.Neurons) > 0 && exp.Neurons[0].NeuronID == "join" {
+			break
+		}
+	}
+	if exp.ReachedEnd || exp.State != core.BrainStateRunning {
+		t.Fatalf("expected run stuck without reaching END, got %+v", exp)
+	}
+	neurons := make(map[string]core.NeuronExplanation)
+	for _, n := range exp.Neurons {
+		neurons[n.NeuronID] = n
+	}
+	cast := neurons["route"].Cast
+	if cast == nil || cast.SelectedGroup != "search" || len(cast.SkippedLinkIDs) != 1 || cast.SkippedLinkIDs[0] != "route-answer" {
+		t.Fatalf("unexpected cast of route: %+v", cast)
+	}
+	partial := neurons["join"].PartialTriggerGroups
+	if len(partial) != 1 || len(partial[0].ReadyLinkIDs) != 1 || partial[0].ReadyLinkIDs[0] != "search-join" ||
+		len(partial[0].InitLinkIDs) != 1 || partial[0].InitLinkIDs[0] != "answer-join" {
+		t.Fatalf("unexpected partial trigger groups of join: %+v", partial)
+	}
+}
+
+func TestExplainReachedEnd(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint())
+	defer brain.Shutdown(context.Background())
+
+	_ = brain.EntryWithMemory("question", "hi")
+	brain.Wait()
+
+	exp := brain.Explain()
+	if !exp.ReachedEnd {
+		t.Fatalf("expected run reached END, got %+v", exp)
+	}
+	if len(exp.BlockedTriggerGroups) != 0 {
+		t.Fatalf("expected no blocked trigger groups, got %+v", exp.BlockedTriggerGroups)
+	}
+}
+
+func TestExplainLinkInManyCastGroups(t *testing.T) {
+	nop := func(bc processor.BrainContext) error { return nil }
+	bp := rModel.NewBlueprint()
+	// route-search and route-answer are both cast when route selects "both"
+	route := bp.AddNeuron(nop, core.WithNeuronID("route"), core.WithSelectFn(func(bcr processor.BrainContextReader) string {
+		return "both"
+	}))
+	search := bp.AddNeuron(nop, core.WithNeuronID("search"))
+	answer := bp.AddNeuron(nop, core.WithNeuronID("answer"))
+	join := bp.AddNeuron(nop, core.WithNeuronID("join"))
+	_, _ = bp.AddEntryLinkTo(route)
+	toSearch, _ := bp.AddLink(route, search, core.WithLinkID("route-search"))
+	toAnswer, _ := bp.AddLink(route, answer, core.WithLinkID("route-answer"))
+	searchJoin, _ := bp.AddLink(search, join, core.WithLinkID("search-join"))
+	answerJoin, _ := bp.AddLink(answer, join, core.WithLinkID("answer-join"))
+	_, _ = bp.AddEndLinkFrom(join)
+	_ = route.AddCastGroup("search", toSearch)
+	_ = route.AddCastGroup("answer", toAnswer)
+	_ = route.AddCastGroup("both", toSearch, toAnswer)
+	_ = join.AddTriggerGroup(searchJoin, answerJoin)
+
+	brain := brainlocal.BuildBrain(bp)
+	defer brain.Shutdown(context.Background())
+
+	if blocked := brain.Explain().BlockedTriggerGroups; len(blocked) != 0 {
+		t.Fatalf("expected no blocked trigger groups, got %+v", blocked)
+	}
+
+	_ = brain.Entry()
+	brain.Wait()
+	exp := brain.Explain()
+	if !exp.ReachedEnd {
+		t.Fatalf("expected run reached END, got %+v", exp)
+	}
+	for _, n := range exp.Neurons {
+		if n.NeuronID == "route" && (n.Cast == nil || len(n.Cast.CastLinkIDs) != 2 || len(n.Cast.SkippedLinkIDs) != 0) {
+			t.Fatalf("unexpected cast of route: %+v", n.Cast)
+		}
+	}
+}
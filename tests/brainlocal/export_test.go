@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/codec"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+type exportPlan struct {
+	Goal  string
+	Steps []string
+}
+
+func init() {
+	_ = codec.Register("tests.exportPlan", exportPlan{}, codec.Msgpack)
+}
+
+func TestExportImportMemory(t *testing.T) {
+	source := brainlocal.BuildBrain(newEchoBlueprint())
+	defer source.Shutdown(context.Background())
+	plan := exportPlan{Goal: "answer", Steps: []string{"search", "answer"}}
+	_ = source.SetMemory(
+		"question", "hi",
+		"turns", 2,
+		"score", 0.5,
+		"done", false,
+		7, "int key",
+		"history", []any{"a", "b"},
+		"plan", plan,
+	)
+
+	var doc bytes.Buffer
+	if err := source.ExportMemory(&doc); err != nil {
+		t.Fatal(err)
+	}
+	// the document is ordered, so documents of the same memories are the same
+	var again bytes.Buffer
+	_ = source.ExportMemory(&again)
+	if doc.String() != again.String() {
+		t.Errorf("expected the same documents, got\n%s\n%s", doc.String(), again.String())
+	}
+	if !strings.Contains(doc.String(), `"type_name": "tests.exportPlan"`) {
+		t.Errorf("expected type name of plan in document:\n%s", doc.String())
+	}
+
+	// memories are set as they are, the reducers are not applied
+	bp := rModel.NewBlueprint()
+	bp.SetMemoryReducer("turns", core.SumReducer())
+	_, _ = bp.AddEntryLinkTo(bp.AddNeuron(func(bc processor.BrainContext) error { return nil }))
+	target := brainlocal.BuildBrain(bp)
+	defer target.Shutdown(context.Background())
+	_ = target.SetMemory("turns", 1, "kept", true)
+	if err := target.ImportMemory(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	dump, _ := target.DumpMemory()
+	expected := map[any]any{
+		"question": "hi", "turns": 2, "score": 0.5, "done": false, 7: "int key",
+		"history": []any{"a", "b"}, "plan": plan, "kept": true,
+	}
+	if !reflect.DeepEqual(dump, expected) {
+		t.Errorf("expected imported memory %v, got %v", expected, dump)
+	}
+}
+
+func TestExportMemoryUnsupportedKey(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint())
+	defer brain.Shutdown(context.Background())
+
+	_ = brain.SetMemory(struct{ ID int }{1}, "value")
+	if err := brain.ExportMemory(&bytes.Buffer{}); err == nil {
+		t.Error("expected error of struct key")
+	}
+	if err := brain.ImportMemory(strings.NewReader(`{"version": 99, "memories": []}`)); err == nil {
+		t.Error("expected error of newer document version")
+	}
+}
+
+func TestExportImportNumbers(t *testing.T) {
+	source := brainlocal.BuildBrain(newEchoBlueprint())
+	defer source.Shutdown(context.Background())
+	numbers := map[any]any{
+		"int": 7, "big int": 1 << 40, "int8": int8(-8), "int16": int16(16), "int32": int32(32), "int64": int64(64),
+		"uint": uint(1), "uint8": uint8(8), "uint16": uint16(16), "uint32": uint32(32),
+		"uint64": uint64(math.MaxUint64), "float32": float32(0.1), "float64": 0.1,
+	}
+	for k, v := range numbers {
+		_ = source.SetMemory(k, v)
+	}
+
+	var doc bytes.Buffer
+	if err := source.ExportMemory(&doc); err != nil {
+		t.Fatal(err)
+	}
+	target := brainlocal.BuildBrain(newEchoBlueprint())
+	defer target.Shutdown(context.Background())
+	if err := target.ImportMemory(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// numbers are read back as their Go types
+	dump, _ := target.DumpMemory()
+	if !reflect.DeepEqual(dump, numbers) {
+		t.Errorf("expected imported numbers %v, got %v", numbers, dump)
+	}
+	for k, v := range dump {
+		if reflect.TypeOf(v) != reflect.TypeOf(numbers[k]) {
+			t.Errorf("expected %v of type %T, got %T", k, numbers[k], v)
+		}
+	}
+
+	// a number out of the range of its kind is refused
+	invalid := `{"version": 1, "memories": [{"key": "n", "key_type": "string", "type": "int", "kind": "uint8", "value": 300}]}`
+	if err := target.ImportMemory(strings.NewReader(invalid)); err == nil {
+		t.Error("expected error of number out of range")
+	}
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func newApprovalBlueprint() core.Blueprint {
+	bp := rModel.NewBlueprint()
+	draft := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("draft", "hi")
+	}, core.WithInterruptAfter())
+	send := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("sent", bc.GetMemory("draft"))
+	}, core.WithInterruptBefore())
+	_, _ = bp.AddEntryLinkTo(draft)
+	_, _ = bp.AddLink(draft, send)
+	_, _ = bp.AddEndLinkFrom(send)
+
+	return bp
+}
+
+func TestInterruptAndResume(t *testing.T) {
+	brain := brainlocal.BuildBrain(newApprovalBlueprint())
+	_ = brain.Entry()
+
+	// parked after draft is processed
+	brain.Wait()
+	if state := brain.GetState(); state != core.BrainStateInterrupted {
+		t.Fatalf("expected brain interrupted, got %s", state)
+	}
+	interrupts := brain.ListInterrupts()
+	if len(interrupts) != 1 || interrupts[0].Point != core.InterruptPointAfter {
+		t.Fatalf("unexpected interrupts: %+v", interrupts)
+	}
+	if draft := interrupts[0].Memory.GetMemory("draft"); draft != "hi" {
+		t.Fatalf("unexpected memory in interrupt notice: %v", draft)
+	}
+	if err := brain.Resume(interrupts[0].RunID); err != nil {
+		t.Fatalf("resume error: %s", err)
+	}
+
+	// parked before send is activated, edit the draft and approve
+	brain.Wait()
+	interrupts = brain.ListInterrupts()
+	if len(interrupts) != 1 || interrupts[0].Point != core.InterruptPointBefore {
+		t.Fatalf("unexpected interrupts: %+v", interrupts)
+	}
+	if brain.ExistMemory("sent") {
+		t.Fatalf("neuron should not run before approved")
+	}
+	if err := brain.Resume(interrupts[0].RunID, "draft", "hello"); err != nil {
+		t.Fatalf("resume error: %s", err)
+	}
+
+	brain.Wait()
+	if state := brain.GetState(); state != core.BrainStateSleeping {
+		t.Fatalf("expected brain sleeping, got %s", state)
+	}
+	if sent := brain.GetMemory("sent"); sent != "hello" {
+		t.Fatalf("unexpected sent: %v", sent)
+	}
+	brain.Shutdown(context.Background())
+}
+
+func TestInterruptAndReject(t *testing.T) {
+	brain := brainlocal.BuildBrain(newApprovalBlueprint())
+	_ = brain.Entry()
+
+	brain.Wait()
+	runID := brain.ListInterrupts()[0].RunID
+	if err := brain.Reject(runID); err != nil {
+		t.Fatalf("reject error: %s", err)
+	}
+
+	brain.Wait()
+	if state := brain.GetState(); state != core.BrainStateSleeping {
+		t.Fatalf("expected brain sleeping, got %s", state)
+	}
+	if brain.ExistMemory("sent") {
+		t.Fatalf("rejected run should not continue")
+	}
+	if err := brain.Resume(runID); err == nil {
+		t.Fatalf("expected error when resuming a run which is not interrupted")
+	}
+	brain.Shutdown(context.Background())
+}
+
+func TestResumeConcurrently(t *testing.T) {
+	bp := newApprovalBlueprint()
+	// the memory of Resume is set slowly, the others try to resume the run meanwhile
+	bp.SetMemoryReducer("approval", func(current, update any) (any, error) {
+		time.Sleep(20 * time.Millisecond)
+		return update, nil
+	})
+	brain := brainlocal.BuildBrain(bp)
+	defer brain.Shutdown(context.Background())
+	_ = brain.Entry()
+	brain.Wait()
+	runID := brain.ListInterrupts()[0].RunID
+
+	// the interrupt points are resumed once
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	start := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			if err := brain.Resume(runID, "approval", i); err == nil {
+				succeeded.Add(1)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	if n := succeeded.Load(); n != 1 {
+		t.Fatalf("expected one Resume succeeded, got %d", n)
+	}
+	brain.Wait()
+	if !brain.ExistMemory("approval") {
+		t.Fatal("expected memory of Resume set")
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Rovanta/rmodel/brainlocal"
+)
+
+func TestListMemoryKeys(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint())
+	defer brain.Shutdown(context.Background())
+
+	_ = brain.SetMemory("question", "hi", "turns", 1)
+	thread, err := brain.Run(context.Background(), "user-1", map[string]any{"question": "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := brain.ListMemoryKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].(string) < keys[j].(string) })
+	if expected := []any{"question", "turns"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected keys %v, got %v", expected, keys)
+	}
+
+	dump, err := thread.DumpMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[any]any{"question": "hello", "answer": "echo: hello", "turns": 1}
+	if !reflect.DeepEqual(dump, expected) {
+		t.Errorf("expected thread memory %v, got %v", expected, dump)
+	}
+
+	ranged := 0
+	_ = brain.RangeMemory(func(key, value any) bool {
+		ranged++
+		return false
+	})
+	if ranged != 1 {
+		t.Errorf("expected range to stop after 1 memory, got %d", ranged)
+	}
+}
+
+func TestBytesMemoryKey(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint())
+	defer brain.Shutdown(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := brain.WatchMemory(ctx, []byte("token"))
+
+	if err := brain.SetMemory([]byte("token"), "bytes", "token", "string"); err != nil {
+		t.Fatalf("set memory error: %s", err)
+	}
+	if v := brain.GetMemory([]byte("token")); v != "bytes" {
+		t.Errorf("expected memory of bytes key, got %v", v)
+	}
+	if v := brain.GetMemory("token"); v != "string" {
+		t.Errorf("expected memory of string key apart from bytes key, got %v", v)
+	}
+	if c := <-changes; !reflect.DeepEqual(c.Key, []byte("token")) || c.NewValue != "bytes" {
+		t.Errorf("unexpected change of bytes key: %+v", c)
+	}
+
+	keys, _ := brain.ListMemoryKeys()
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprintf("%T", keys[i]) < fmt.Sprintf("%T", keys[j]) })
+	if expected := []any{[]byte("token"), "token"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected keys %v, got %v", expected, keys)
+	}
+	brain.DeleteMemory([]byte("token"))
+	if brain.ExistMemory([]byte("token")) || !brain.ExistMemory("token") {
+		t.Errorf("expected only memory of bytes key deleted")
+	}
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/memstore"
+)
+
+func TestMemoryNotEvicted(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint())
+	defer brain.Shutdown(context.Background())
+
+	const n = 10000
+	value := strings.Repeat("x", 1024)
+	for i := 0; i < n; i++ {
+		_ = brain.SetMemory(i, value)
+	}
+	for i := 0; i < n; i++ {
+		if !brain.ExistMemory(i) {
+			t.Fatalf("memory %d is lost", i)
+		}
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint(), brainlocal.WithMemoryCache(1e4, 4<<10))
+	defer brain.Shutdown(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := brain.WatchMemory(ctx)
+
+	// the values cost more than the cache can keep
+	value := strings.Repeat("x", 1024)
+	for i := 0; i < 16; i++ {
+		_ = brain.SetMemory(i, value)
+	}
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case c := <-changes:
+			if !c.Evicted {
+				continue
+			}
+			if c.OldValue != value || c.NewValue != nil {
+				t.Fatalf("unexpected eviction: %+v", c)
+			}
+			if brain.ExistMemory(c.Key) {
+				t.Fatalf("evicted memory %v still exists", c.Key)
+			}
+			return
+		case <-timeout:
+			t.Fatal("expected memory evicted")
+		}
+	}
+}
+
+func TestWithMemoryStore(t *testing.T) {
+	store := memstore.NewMap()
+	brain := brainlocal.BuildBrain(newEchoBlueprint(), brainlocal.WithMemoryStore(func() (core.MemoryStore, error) {
+		return store, nil
+	}))
+	defer brain.Shutdown(context.Background())
+
+	_ = brain.EntryWithMemory("question", "hi")
+	brain.Wait()
+
+	snap, _ := store.Snapshot()
+	if snap["question"] != "hi" || snap["answer"] == nil {
+		t.Fatalf("expected memories kept in the store, got %v", snap)
+	}
+}
+
+func TestMemoryStoreWithMemories(t *testing.T) {
+	store := memstore.NewMap()
+	_ = store.Set("question", "hi")
+	_ = store.Set([]byte("token"), "bytes")
+	brain := brainlocal.BuildBrain(newEchoBlueprint(), brainlocal.WithMemoryStore(func() (core.MemoryStore, error) {
+		return store, nil
+	}))
+	defer brain.Shutdown(context.Background())
+
+	// the memories kept by the store are read before the first write
+	if v := brain.GetMemory("question"); v != "hi" {
+		t.Errorf("expected memory kept by the store, got %v", v)
+	}
+	keys, _ := brain.ListMemoryKeys()
+	if len(keys) != 2 {
+		t.Errorf("expected keys of the memories kept by the store, got %v", keys)
+	}
+	dump, _ := brain.DumpMemory()
+	if dump["question"] != "hi" || dump["token"] != "bytes" {
+		t.Errorf("expected dump of the memories kept by the store, got %v", dump)
+	}
+
+	if _, err := brain.Run(context.Background(), "user-1", map[string]any{"question": "hello", "turn": 1}); err != nil {
+		t.Fatal(err)
+	}
+	// another brain sharing the store finds the memories of the thread
+	other := brainlocal.BuildBrain(newEchoBlueprint(), brainlocal.WithMemoryStore(func() (core.MemoryStore, error) {
+		return store, nil
+	}))
+	defer other.Shutdown(context.Background())
+	thread, err := other.Run(context.Background(), "user-1", map[string]any{"question": "hi again"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dump, _ := thread.DumpMemory(); dump["turn"] != 1 || dump["answer"] != "echo: hi again" {
+		t.Errorf("expected memories of thread kept by the store, got %v", dump)
+	}
+}
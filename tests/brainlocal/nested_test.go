@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -24,7 +25,7 @@ func TestNested(t *testing.T) {
 	result := brain.GetMemory("nested_result").(string)
 	fmt.Printf("Nested result: %s\n", result)
 
-	brain.Shutdown()
+	brain.Shutdown(context.Background())
 }
 
 func nestedBrain(outerBrain processor.BrainContext) error {
@@ -45,7 +46,7 @@ func nestedBrain(outerBrain processor.BrainContext) error {
 	result := brain.GetMemory("result").(string)
 	_ = outerBrain.SetMemory("nested_result", result)
 	
-	brain.Shutdown()
+	brain.Shutdown(context.Background())
 
 	return nil
 }
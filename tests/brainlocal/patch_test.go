@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestApplyPatchWhileRunning(t *testing.T) {
+	var brain *brainlocal.BrainLocal
+
+	bp := rModel.NewBlueprint()
+	plan := bp.AddNeuron(func(bc processor.BrainContext) error {
+		// the agent learns a new tool, and routes the plan to it instead of END
+		patch := rModel.NewPatch()
+		tool := patch.AddNeuron(func(bc processor.BrainContext) error {
+			return bc.SetMemory("result", "tool called with "+bc.GetMemory("plan").(string))
+		})
+		planNeuron, _ := bp.GetNeuron(bc.GetCurrentNeuronID())
+		_, _ = patch.AddLink(planNeuron, tool)
+		_, _ = patch.AddEndLinkFrom(tool)
+		patch.RemoveLink("plan-end")
+		if err := brain.Apply(patch); err != nil {
+			return err
+		}
+
+		return bc.SetMemory("plan", "search")
+	})
+	_, _ = bp.AddEntryLinkTo(plan)
+	_, _ = bp.AddEndLinkFrom(plan, core.WithLinkID("plan-end"))
+
+	brain = brainlocal.BuildBrain(bp)
+	_ = brain.Entry()
+	brain.Wait()
+
+	if result := brain.GetMemory("result"); result != "tool called with search" {
+		t.Fatalf("expected new neuron processed, got %v", result)
+	}
+	brain.Shutdown(context.Background())
+}
+
+func TestApplyPatchGroups(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	a := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("a", true)
+	})
+	join := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("joined", bc.ExistMemory("a") && bc.ExistMemory("b"))
+	})
+	_, _ = bp.AddEntryLinkTo(a)
+	_, _ = bp.AddLink(a, join, core.WithLinkID("a-join"))
+	_, _ = bp.AddEndLinkFrom(join)
+
+	brain := brainlocal.BuildBrain(bp)
+	defer brain.Shutdown(context.Background())
+
+	// add a parallel branch b, and wait both branches before join
+	patch := rModel.NewPatch()
+	b := patch.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("b", true)
+	})
+	_, _ = patch.AddEntryLinkTo(b)
+	bJoin, _ := patch.AddLink(b, join)
+	patch.AddTriggerGroup(join.GetID(), "a-join", bJoin.GetID())
+	if err := brain.Apply(patch); err != nil {
+		t.Fatalf("apply error: %s", err)
+	}
+
+	_ = brain.Entry()
+	brain.Wait()
+	if joined := brain.GetMemory("joined"); joined != true {
+		t.Fatalf("expected join waits both branches, got %v", joined)
+	}
+}
+
+func TestApplyInvalidPatch(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	n := bp.AddNeuron(func(bc processor.BrainContext) error { return nil })
+	_, _ = bp.AddEntryLinkTo(n)
+	_, _ = bp.AddEndLinkFrom(n)
+	brain := brainlocal.BuildBrain(bp)
+
+	patch := rModel.NewPatch()
+	patch.RemoveNeuron("not-exist")
+	if err := brain.Apply(patch); err == nil {
+		t.Fatalf("expected error when removing a neuron not exist")
+	}
+
+	patch = rModel.NewPatch()
+	patch.RemoveNeuron(core.EndNeuronID)
+	if err := brain.Apply(patch); err == nil {
+		t.Fatalf("expected error when removing END neuron")
+	}
+
+	// links of an invalid patch are not added
+	patch = rModel.NewPatch()
+	other := patch.AddNeuron(func(bc processor.BrainContext) error { return nil })
+	_, _ = patch.AddLink(n, other)
+	patch.AddCastGroup(n.GetID(), "")
+	if err := brain.Apply(patch); err == nil {
+		t.Fatalf("expected error when cast group name is empty")
+	}
+	patch = rModel.NewPatch()
+	patch.RemoveNeuron(other.GetID())
+	if err := brain.Apply(patch); err == nil {
+		t.Fatalf("neuron of invalid patch should not be added")
+	}
+}
+
+func TestApplyPatchInvalidForThread(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	review := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return nil
+	}, core.WithNeuronID("review"), core.WithInterruptBefore())
+	_, _ = bp.AddEntryLinkTo(review)
+	_, _ = bp.AddEndLinkFrom(review)
+
+	brain := brainlocal.BuildBrain(bp)
+	defer brain.Shutdown(context.Background())
+	if _, err := brain.Run(context.Background(), "u1", nil); err != nil {
+		t.Fatalf("run error: %s", err)
+	}
+
+	// the neuron is inactive in brain, but parked in the thread
+	patch := rModel.NewPatch()
+	patch.RemoveNeuron("review")
+	if err := brain.Apply(patch); err == nil {
+		t.Fatal("expected error when removing a neuron parked in a thread")
+	}
+
+	// brain is not patched either
+	_ = brain.Entry()
+	brain.Wait()
+	interrupts := brain.ListInterrupts()
+	found := false
+	for _, i := range interrupts {
+		if i.RunID != "u1" && i.NeuronID == "review" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected brain parked before review, got %+v", interrupts)
+	}
+}
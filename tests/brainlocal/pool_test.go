@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/brainpool"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func buildSmallBrain(bp core.Blueprint) core.Brain {
+	return brainlocal.BuildBrain(bp, brainlocal.WithMemorySetting(1e4, 1<<20))
+}
+
+func TestBrainPoolReuse(t *testing.T) {
+	pool := brainpool.NewBrainPool(newEchoBlueprint(), buildSmallBrain, brainpool.WithMinIdle(2))
+	defer pool.Close()
+	if stats := pool.Stats(); stats.Idle != 2 || stats.Built != 2 {
+		t.Fatalf("expected 2 pre-built brains, got %+v", stats)
+	}
+
+	for i := 0; i < 5; i++ {
+		brain, err := pool.Get(context.Background())
+		if err != nil {
+			t.Fatalf("get error: %s", err)
+		}
+		if brain.ExistMemory("answer") {
+			t.Fatalf("memory of returned brain should be cleared")
+		}
+		question := fmt.Sprintf("question %d", i)
+		_ = brain.EntryWithMemory("question", question)
+		brain.Wait()
+		if answer := brain.GetMemory("answer"); answer != "echo: "+question {
+			t.Fatalf("unexpected answer: %v", answer)
+		}
+		if err = pool.Put(brain); err != nil {
+			t.Fatalf("put error: %s", err)
+		}
+	}
+
+	if stats := pool.Stats(); stats.Built != 2 || stats.InUse != 0 {
+		t.Fatalf("expected brains reused, got %+v", stats)
+	}
+}
+
+func TestBrainPoolMaxSize(t *testing.T) {
+	pool := brainpool.NewBrainPool(newEchoBlueprint(), buildSmallBrain, brainpool.WithMaxSize(1))
+	defer pool.Close()
+
+	brain, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("get error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err = pool.Get(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected get blocked by max size, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = pool.Put(brain)
+	}()
+	got, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("get error: %s", err)
+	}
+	if got != brain {
+		t.Fatalf("expected the returned brain")
+	}
+	_ = pool.Put(got)
+}
+
+func TestBrainPoolConcurrentGetMaxSize(t *testing.T) {
+	pool := brainpool.NewBrainPool(newEchoBlueprint(), buildSmallBrain, brainpool.WithMaxSize(1))
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// only one Get gets a brain, it is never returned
+			_, _ = pool.Get(ctx)
+		}()
+	}
+	wg.Wait()
+
+	if stats := pool.Stats(); stats.InUse != 1 || stats.Built != 1 {
+		t.Fatalf("expected one brain built under max size 1, got %+v", stats)
+	}
+}
+
+func TestBrainPoolPutRunningBrain(t *testing.T) {
+	pool := brainpool.NewBrainPool(newEchoBlueprint(), buildSmallBrain)
+	defer pool.Close()
+
+	brain, _ := pool.Get(context.Background())
+	_ = brain.EntryWithMemory("question", "hi")
+	// the brain is returned while its neuron is running
+	if err := pool.Put(brain); err != nil {
+		t.Fatalf("put error: %s", err)
+	}
+	if state := brain.GetState(); state != core.BrainStateSleeping {
+		t.Fatalf("expected returned brain sleeping, got %s", state)
+	}
+	if brain.ExistMemory("answer") {
+		t.Fatalf("memory written by the running neuron should be cleared")
+	}
+	if stats := pool.Stats(); stats.Idle != 1 || stats.Unhealthy != 0 {
+		t.Fatalf("expected the brain kept idle, got %+v", stats)
+	}
+}
+
+func TestBrainPoolEvict(t *testing.T) {
+	pool := brainpool.NewBrainPool(newEchoBlueprint(), buildSmallBrain,
+		brainpool.WithMinIdle(1),
+		brainpool.WithIdleTimeout(20*time.Millisecond),
+	)
+	defer pool.Close()
+
+	brains := make([]core.Brain, 3)
+	for i := range brains {
+		brains[i], _ = pool.Get(context.Background())
+	}
+	for _, brain := range brains {
+		_ = pool.Put(brain)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if stats := pool.Stats(); stats.Idle != 1 || stats.Evicted != 2 {
+		t.Fatalf("expected idle brains evicted to min idle, got %+v", stats)
+	}
+}
+
+func TestBrainPoolHealthCheck(t *testing.T) {
+	var broken sync.Map
+	pool := brainpool.NewBrainPool(newEchoBlueprint(), buildSmallBrain,
+		brainpool.WithHealthCheck(func(brain core.Brain) error {
+			if _, ok := broken.Load(brain); ok {
+				return fmt.Errorf("brain is broken")
+			}
+			return brainpool.DefaultHealthCheck(brain)
+		}),
+	)
+	defer pool.Close()
+
+	brain, _ := pool.Get(context.Background())
+	broken.Store(brain, true)
+	_ = pool.Put(brain)
+	if stats := pool.Stats(); stats.Idle != 0 || stats.Unhealthy != 1 {
+		t.Fatalf("expected unhealthy brain dropped, got %+v", stats)
+	}
+	if err := pool.Put(brain); err == nil {
+		t.Fatalf("expected error when putting a brain not in use")
+	}
+
+	got, _ := pool.Get(context.Background())
+	if got == brain {
+		t.Fatalf("expected a new brain")
+	}
+	_ = pool.Put(got)
+}
+
+func TestBrainPoolClosed(t *testing.T) {
+	pool := brainpool.NewBrainPool(newEchoBlueprint(), buildSmallBrain, brainpool.WithMinIdle(1))
+	pool.Close()
+	pool.Close()
+	if _, err := pool.Get(context.Background()); err != brainpool.ErrPoolClosed {
+		t.Fatalf("expected pool closed error, got %v", err)
+	}
+}
+
+func TestBrainPoolPutStuckBrain(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	// the neuron runs until the brain is shut down
+	_, _ = bp.AddEntryLinkTo(bp.AddNeuron(func(bc processor.BrainContext) error {
+		<-bc.Done()
+		return bc.Err()
+	}))
+	pool := brainpool.NewBrainPool(bp, buildSmallBrain, brainpool.WithResetTimeout(20*time.Millisecond))
+	defer pool.Close()
+
+	brain, _ := pool.Get(context.Background())
+	_ = brain.Entry()
+	done := make(chan error)
+	go func() {
+		done <- pool.Put(brain)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("put error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("put blocked by a stuck brain")
+	}
+	if stats := pool.Stats(); stats.Idle != 0 || stats.InUse != 0 || stats.Unhealthy != 1 {
+		t.Fatalf("expected stuck brain dropped, got %+v", stats)
+	}
+}
+
+func TestBrainPoolPutTwice(t *testing.T) {
+	pool := brainpool.NewBrainPool(newEchoBlueprint(), buildSmallBrain)
+	defer pool.Close()
+
+	brain, _ := pool.Get(context.Background())
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- pool.Put(brain)
+		}()
+	}
+	failed := 0
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("expected one of puts of the same brain failed, got %d", failed)
+	}
+	if stats := pool.Stats(); stats.Idle != 1 || stats.InUse != 0 {
+		t.Fatalf("expected brain idle once, got %+v", stats)
+	}
+}
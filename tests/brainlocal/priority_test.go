@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// newPriorityBrain builds a brain with one worker, the busy neuron keeps the worker busy,
+// so the neurons triggered meanwhile are queued, and the order they are processed in is recorded
+func newPriorityBrain(withOpts ...brainlocal.Option) (*brainlocal.BrainLocal, map[string]core.Link, func() []string) {
+	var mu sync.Mutex
+	order := make([]string, 0)
+	record := func(name string) func(bc processor.BrainContext) error {
+		return func(bc processor.BrainContext) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	bp := rModel.NewBlueprint()
+	busy := bp.AddNeuron(func(bc processor.BrainContext) error {
+		time.Sleep(150 * time.Millisecond)
+		return nil
+	})
+	_, _ = bp.AddEntryLinkTo(busy)
+	// src is never activated, its links are triggered by the test
+	src := bp.AddNeuron(record("src"))
+	links := make(map[string]core.Link)
+	for name, priority := range map[string]int{"background1": 0, "background2": 0, "responder": 10} {
+		n := bp.AddNeuron(record(name), core.WithNeuronPriority(priority))
+		links[name], _ = bp.AddLink(src, n)
+	}
+
+	brain := brainlocal.BuildBrain(bp, append([]brainlocal.Option{brainlocal.WithNeuronWorkerNum(1)}, withOpts...)...)
+	_ = brain.Entry()
+	time.Sleep(20 * time.Millisecond)
+
+	return brain, links, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), order...)
+	}
+}
+
+func TestNeuronPriority(t *testing.T) {
+	brain, links, order := newPriorityBrain()
+	defer brain.Shutdown(context.Background())
+
+	_ = brain.TrigLinks(links["background1"])
+	time.Sleep(10 * time.Millisecond)
+	_ = brain.TrigLinks(links["background2"])
+	time.Sleep(10 * time.Millisecond)
+	_ = brain.TrigLinks(links["responder"])
+	brain.Wait()
+
+	expected := []string{"responder", "background1", "background2"}
+	got := order()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestNeuronPriorityAging(t *testing.T) {
+	// responder gains 10 priority after waiting 10ms, background1 waits much longer
+	brain, links, order := newPriorityBrain(brainlocal.WithPriorityAging(time.Millisecond))
+	defer brain.Shutdown(context.Background())
+
+	_ = brain.TrigLinks(links["background1"])
+	time.Sleep(80 * time.Millisecond)
+	_ = brain.TrigLinks(links["responder"])
+	brain.Wait()
+
+	got := order()
+	if len(got) != 2 || got[0] != "background1" {
+		t.Fatalf("expected background1 not starved, got %v", got)
+	}
+}
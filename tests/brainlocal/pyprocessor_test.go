@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+	"github.com/Rovanta/rmodel/pyprocessor"
+	"github.com/rs/zerolog"
+)
+
+const greetProcessor = `from rmodel import Processor, Selector
+
+
+class GreetProcessor(Processor):
+    def __init__(self, greeting):
+        self.greeting = greeting
+
+    def process(self, ctx):
+        name = ctx.get_memory("name")
+        print(f"greeting {name}")
+        ctx.set_memory("greeting", f"{self.greeting}, {name}", 7, {"visits": 1}, "had_name", ctx.exist_memory("name"))
+        ctx.delete_memory("name")
+        ctx.set_memory("missing", ctx.get_memory("nothing"))
+
+
+class ScoreSelector(Selector):
+    def __init__(self, threshold):
+        self.threshold = threshold
+
+    def select(self, ctx):
+        return "high" if ctx.get_memory("score") > self.threshold else "low"
+`
+
+// setPythonWorkers sets the default pool of python3 to the workers running the rmodel package of this repository
+// and the Python code of tests, it returns the python command
+func setPythonWorkers(t *testing.T) string {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+	pkg, err := filepath.Abs("../../pyprocessor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err = os.WriteFile(filepath.Join(dir, "greet_processor.py"), []byte(greetProcessor), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// the processors and selectors with the label python_cmd run in the default pool of the command
+	pyprocessor.SetDefaultWorkerPool(pyprocessor.NewWorkerPool(1, pyprocessor.WithPythonCmd(python), pyprocessor.WithPythonPath(pkg, dir)))
+
+	return python
+}
+
+func TestPythonProcessor(t *testing.T) {
+	python := setPythonWorkers(t)
+
+	bp := rModel.NewMultiLangBlueprint()
+	name := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("name", "Clay")
+	})
+	greet := bp.AddNeuronWithPyProcessor("", "greet_processor", "GreetProcessor", map[string]interface{}{"greeting": "Hello"},
+		core.WithPyProcessExecCmd(python))
+	_, _ = bp.AddEntryLinkTo(name)
+	_, _ = bp.AddLink(name, greet)
+	_, _ = bp.AddEndLinkFrom(greet)
+
+	// BrainLocal has no database, the Python processor reads and writes memory through the worker
+	brain := brainlocal.BuildMultiLangBrain(bp)
+	defer brain.Shutdown(context.Background())
+	if err := brain.Entry(); err != nil {
+		t.Fatal(err)
+	}
+	brain.Wait()
+
+	if greeting := brain.GetMemory("greeting"); greeting != "Hello, Clay" {
+		t.Errorf("expected greeting, got %v", greeting)
+	}
+	if v := brain.GetMemory(7); !reflect.DeepEqual(v, map[string]interface{}{"visits": 1}) {
+		t.Errorf("expected memory of int key, got %#v", v)
+	}
+	if v := brain.GetMemory("had_name"); v != true {
+		t.Errorf("expected had_name true, got %v", v)
+	}
+	if brain.ExistMemory("name") {
+		t.Error("expected name deleted by the Python processor")
+	}
+	if !brain.ExistMemory("missing") || brain.GetMemory("missing") != nil {
+		t.Errorf("expected nil memory of missing key, got %v", brain.GetMemory("missing"))
+	}
+}
+
+func TestPythonSelector(t *testing.T) {
+	python := setPythonWorkers(t)
+
+	bp := rModel.NewMultiLangBlueprint()
+	judge := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return nil
+	}, core.WithPySelector("", "greet_processor", "ScoreSelector", map[string]interface{}{"threshold": 5}),
+		core.WithPyProcessExecCmd(python))
+	newBranch := func(result string) core.Link {
+		n := bp.AddNeuron(func(bc processor.BrainContext) error {
+			return bc.SetMemory("result", result)
+		})
+		l, _ := bp.AddLink(judge, n)
+		_, _ = bp.AddEndLinkFrom(n)
+		return l
+	}
+	high, low := newBranch("high"), newBranch("low")
+	_ = judge.AddCastGroup("high", high)
+	_ = judge.AddCastGroup("low", low)
+	_, _ = bp.AddEntryLinkTo(judge)
+
+	brain := brainlocal.BuildMultiLangBrain(bp)
+	defer brain.Shutdown(context.Background())
+	for score, expected := range map[int]string{7: "high", 3: "low"} {
+		if err := brain.EntryWithMemory("score", score); err != nil {
+			t.Fatal(err)
+		}
+		brain.Wait()
+		if result := brain.GetMemory("result"); result != expected {
+			t.Errorf("expected branch %s of score %d, got %v", expected, score, result)
+		}
+	}
+}
+
+// lockedBuffer is a buffer which the brain logs to while the test reads it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestPythonSelectorError(t *testing.T) {
+	python := setPythonWorkers(t)
+
+	bp := rModel.NewMultiLangBlueprint()
+	// score is not set, the comparison of selector raises
+	judge := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return nil
+	}, core.WithPySelector("", "greet_processor", "ScoreSelector", map[string]interface{}{"threshold": 5}),
+		core.WithPyProcessExecCmd(python))
+	n := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("result", "high")
+	})
+	l, _ := bp.AddLink(judge, n)
+	_ = judge.AddCastGroup("high", l)
+	_, _ = bp.AddEntryLinkTo(judge)
+
+	logs := &lockedBuffer{}
+	brain := brainlocal.BuildMultiLangBrain(bp, brainlocal.WithLogger(zerolog.New(logs)))
+	defer brain.Shutdown(context.Background())
+	if err := brain.Entry(); err != nil {
+		t.Fatal(err)
+	}
+	brain.Wait()
+
+	if v := brain.GetMemory("result"); v != nil {
+		t.Errorf("expected no link cast by the failed selector, got result %v", v)
+	}
+	if out := logs.String(); !strings.Contains(out, "select cast group error") || !strings.Contains(out, "TypeError") {
+		t.Errorf("expected error of the failed selector in brain logs, got %s", out)
+	}
+}
+
+func TestPythonProcessorOutput(t *testing.T) {
+	python := setPythonWorkers(t)
+
+	bp := rModel.NewMultiLangBlueprint()
+	greet := bp.AddNeuronWithPyProcessor("", "greet_processor", "GreetProcessor", map[string]interface{}{"greeting": "Hello"},
+		core.WithPyProcessExecCmd(python))
+	_, _ = bp.AddEntryLinkTo(greet)
+
+	logs := &lockedBuffer{}
+	brain := brainlocal.BuildMultiLangBrain(bp, brainlocal.WithLogger(zerolog.New(logs)))
+	defer brain.Shutdown(context.Background())
+	if err := brain.EntryWithMemory("name", "Clay"); err != nil {
+		t.Fatal(err)
+	}
+	brain.Wait()
+
+	// the output is read from the worker concurrently with its response
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(logs.String(), "greeting Clay") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if out := logs.String(); !strings.Contains(out, `"output":"greeting Clay"`) || !strings.Contains(out, greet.GetID()) {
+		t.Errorf("expected output of processor in brain logs, got %s", out)
+	}
+}
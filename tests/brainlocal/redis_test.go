@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/memstore"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSharedRedisMemory(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	// brains in two processes share memory of "agent"
+	withRedis := brainlocal.WithMemoryStore(func() (core.MemoryStore, error) {
+		return memstore.NewRedis(client, "agent"), nil
+	})
+	a := brainlocal.BuildBrain(newEchoBlueprint(), withRedis)
+	defer a.Shutdown(context.Background())
+	b := brainlocal.BuildBrain(newEchoBlueprint(), withRedis)
+	defer b.Shutdown(context.Background())
+
+	// memory is initialized lazily by the first write
+	_ = b.SetMemory("reader", "b")
+	_ = a.SetMemory("question", "hi")
+	if v := b.GetMemory("question"); v != "hi" {
+		t.Fatalf("expected memory shared, got %v", v)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	changes := a.WatchMemory(ctx, "status")
+	_ = b.SetMemory("status", "done")
+	select {
+	case c := <-changes:
+		if c.NewValue != "done" {
+			t.Fatalf("unexpected change: %+v", c)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected change made by the other brain")
+	}
+
+	if _, err := a.WaitFor(ctx, "status", func(value any) bool { return value == "done" }); err != nil {
+		t.Fatalf("wait for error: %s", err)
+	}
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestMemoryReducers(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	bp.SetMemoryReducer("messages", core.AppendReducer())
+	bp.SetMemoryReducer("steps", core.SumReducer())
+	bp.SetMemoryReducer("scores", core.MergeMapReducer())
+	bp.SetMemoryReducer("best", core.MaxReducer())
+	bp.SetMemoryReducer("longest", func(current, update any) (any, error) {
+		if c, _ := current.(string); len(c) >= len(update.(string)) {
+			return c, nil
+		}
+		return update, nil
+	})
+
+	const parallel = 8
+	join := bp.AddNeuron(func(bc processor.BrainContext) error { return nil })
+	for i := 0; i < parallel; i++ {
+		i := i
+		n := bp.AddNeuron(func(bc processor.BrainContext) error {
+			return bc.SetMemory(
+				"messages", []string{fmt.Sprintf("step %d", i)},
+				"steps", 1,
+				"scores", map[string]int{fmt.Sprintf("n%d", i): i},
+				"best", i,
+				"longest", fmt.Sprintf("%*d", i+1, i),
+			)
+		})
+		_, _ = bp.AddEntryLinkTo(n)
+		_, _ = bp.AddLink(n, join)
+	}
+	_, _ = bp.AddEndLinkFrom(join)
+
+	brain := brainlocal.BuildBrain(bp, brainlocal.WithNeuronWorkerNum(parallel))
+	defer brain.Shutdown(context.Background())
+	for run := 0; run < 3; run++ {
+		brain.ClearMemory()
+		_ = brain.Entry()
+		brain.Wait()
+		// join is activated by the first in-link, wait all parallel neurons
+		_, _ = brain.WaitFor(context.Background(), "steps", func(value any) bool { return value == parallel })
+
+		messages, _ := brain.GetMemory("messages").([]string)
+		sort.Strings(messages)
+		if len(messages) != parallel || messages[0] != "step 0" {
+			t.Fatalf("expected %d messages appended, got %v", parallel, messages)
+		}
+		if scores, _ := brain.GetMemory("scores").(map[string]int); len(scores) != parallel {
+			t.Fatalf("expected %d scores merged, got %v", parallel, scores)
+		}
+		if best := brain.GetMemory("best"); best != parallel-1 {
+			t.Fatalf("unexpected max: %v", best)
+		}
+		if longest, _ := brain.GetMemory("longest").(string); len(longest) != parallel {
+			t.Fatalf("unexpected custom reduce: %q", longest)
+		}
+	}
+}
+
+func TestMemoryReducerError(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	bp.SetMemoryReducer("steps", core.SumReducer())
+	n := bp.AddNeuron(func(bc processor.BrainContext) error { return nil })
+	_, _ = bp.AddEntryLinkTo(n)
+	brain := brainlocal.BuildBrain(bp)
+	defer brain.Shutdown(context.Background())
+
+	if err := brain.SetMemory("steps", "one"); err == nil {
+		t.Fatalf("expected error when sum a string")
+	}
+	if err := brain.SetMemory("steps", 1.5, "steps", 1); err != nil {
+		t.Fatalf("set memory error: %s", err)
+	}
+	if steps := brain.GetMemory("steps"); steps != 2.5 {
+		t.Fatalf("unexpected sum: %v", steps)
+	}
+}
+
+func TestReducersWithKeyNotComparable(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	bp.SetMemoryReducer("steps", core.SumReducer())
+	brain := brainlocal.BuildBrain(bp)
+	defer brain.Shutdown(context.Background())
+
+	// the lookup of reducer must not panic with the memory locked
+	_ = brain.SetMemory([]byte("token"), "bytes")
+	if err := brain.SetMemory(map[string]int{}, 1); err == nil {
+		t.Errorf("expected error of key not comparable")
+	}
+	if err := brain.SetMemory("steps", 1, "steps", 2); err != nil {
+		t.Fatal(err)
+	}
+	if v := brain.GetMemory("steps"); v != 3 {
+		t.Errorf("expected steps 3, got %v", v)
+	}
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestShutdownIdempotent(t *testing.T) {
+	// brain never started
+	brain := brainlocal.BuildBrain(newEchoBlueprint())
+	for i := 0; i < 2; i++ {
+		if err := brain.Shutdown(context.Background()); err != nil {
+			t.Fatalf("shutdown error: %s", err)
+		}
+	}
+
+	_ = brain.EntryWithMemory("question", "hi")
+	brain.Wait()
+	for i := 0; i < 2; i++ {
+		if err := brain.Shutdown(context.Background()); err != nil {
+			t.Fatalf("shutdown error: %s", err)
+		}
+	}
+	if state := brain.GetState(); state != core.BrainStateShutdown {
+		t.Fatalf("expected brain shutdown, got %s", state)
+	}
+}
+
+func TestEntryAfterShutdown(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint())
+	defer brain.Shutdown(context.Background())
+
+	for i := 0; i < 3; i++ {
+		if err := brain.EntryWithMemory("question", i); err != nil {
+			t.Fatalf("entry error: %s", err)
+		}
+		brain.Wait()
+		if answer := brain.GetMemory("answer"); answer != fmt.Sprintf("echo: %d", i) {
+			t.Fatalf("unexpected answer of run %d: %v", i, answer)
+		}
+		if err := brain.Shutdown(context.Background()); err != nil {
+			t.Fatalf("shutdown error: %s", err)
+		}
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+	var downstream atomic.Bool
+	bp := rModel.NewBlueprint()
+	slow := bp.AddNeuron(func(bc processor.BrainContext) error {
+		time.Sleep(100 * time.Millisecond)
+		return bc.SetMemory("slow", "done")
+	})
+	next := bp.AddNeuron(func(bc processor.BrainContext) error {
+		downstream.Store(true)
+		return nil
+	})
+	_, _ = bp.AddEntryLinkTo(slow)
+	_, _ = bp.AddLink(slow, next)
+	_, _ = bp.AddEndLinkFrom(next)
+
+	brain := brainlocal.BuildBrain(bp)
+	_ = brain.Entry()
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		done <- brain.Shutdown(context.Background())
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := brain.Entry(); err == nil {
+		t.Fatalf("expected entry refused while shutting down")
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("shutdown error: %s", err)
+	}
+	if downstream.Load() {
+		t.Fatalf("expected no neuron activated while shutting down")
+	}
+}
+
+func TestShutdownCancelsProcessors(t *testing.T) {
+	var cancelled atomic.Bool
+	bp := rModel.NewBlueprint()
+	n := bp.AddNeuron(func(bc processor.BrainContext) error {
+		select {
+		case <-bc.Done():
+			cancelled.Store(true)
+			return bc.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	})
+	_, _ = bp.AddEntryLinkTo(n)
+	_, _ = bp.AddEndLinkFrom(n)
+
+	brain := brainlocal.BuildBrain(bp)
+	_ = brain.Entry()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := brain.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("shutdown took %s", elapsed)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !cancelled.Load() {
+		t.Fatalf("expected context of processor cancelled")
+	}
+}
@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func newEchoBlueprint() core.Blueprint {
+	bp := rModel.NewBlueprint()
+	echo := bp.AddNeuron(func(bc processor.BrainContext) error {
+		time.Sleep(10 * time.Millisecond)
+		turns, _ := bc.GetMemory("turns").(int)
+		return bc.SetMemory(
+			"answer", fmt.Sprintf("echo: %v", bc.GetMemory("question")),
+			"turns", turns+1,
+		)
+	})
+	_, _ = bp.AddEntryLinkTo(echo)
+	_, _ = bp.AddEndLinkFrom(echo)
+
+	return bp
+}
+
+func TestConcurrentThreads(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint(), brainlocal.WithNeuronWorkerNum(2))
+	defer brain.Shutdown(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			question := fmt.Sprintf("question %d", i)
+			thread, err := brain.Run(context.Background(), fmt.Sprintf("user-%d", i), map[string]any{"question": question})
+			if err != nil {
+				t.Errorf("run error: %s", err)
+				return
+			}
+			if answer := thread.GetMemory("answer"); answer != "echo: "+question {
+				t.Errorf("thread %s got answer of another thread: %v", thread.GetThreadID(), answer)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if threads := brain.ListThreads(); len(threads) != 8 {
+		t.Fatalf("expected 8 threads, got %v", threads)
+	}
+	if brain.ExistMemory("answer") {
+		t.Fatalf("memory of threads should not be visible in brain")
+	}
+}
+
+func TestThreadKeepsMemoryBetweenRuns(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint())
+	defer brain.Shutdown(context.Background())
+
+	for i := 1; i <= 2; i++ {
+		thread, err := brain.Run(context.Background(), "chat", map[string]any{"question": i})
+		if err != nil {
+			t.Fatalf("run error: %s", err)
+		}
+		if turns := thread.GetMemory("turns"); turns != i {
+			t.Fatalf("expected %d turns, got %v", i, turns)
+		}
+	}
+
+	brain.Thread("chat").Shutdown(context.Background())
+	if brain.Thread("chat") != nil {
+		t.Fatalf("thread should be removed after shutdown")
+	}
+	thread, err := brain.Run(context.Background(), "chat", map[string]any{"question": "again"})
+	if err != nil {
+		t.Fatalf("run error: %s", err)
+	}
+	if turns := thread.GetMemory("turns"); turns != 1 {
+		t.Fatalf("expected memory of thread cleared after shutdown, got %v turns", turns)
+	}
+}
+
+func TestInterruptInThread(t *testing.T) {
+	brain := brainlocal.BuildBrain(newApprovalBlueprint())
+	defer brain.Shutdown(context.Background())
+
+	thread, err := brain.Run(context.Background(), "review", nil)
+	if err != nil {
+		t.Fatalf("run error: %s", err)
+	}
+	if state := thread.GetState(); state != core.BrainStateInterrupted {
+		t.Fatalf("expected thread interrupted, got %s", state)
+	}
+	interrupts := brain.ListInterrupts()
+	if len(interrupts) != 1 || interrupts[0].RunID != "review" {
+		t.Fatalf("unexpected interrupts: %+v", interrupts)
+	}
+
+	// resume the thread from brain, until it is sleeping
+	for len(interrupts) > 0 {
+		if err = brain.Resume(interrupts[0].RunID); err != nil {
+			t.Fatalf("resume error: %s", err)
+		}
+		thread.Wait()
+		interrupts = brain.ListInterrupts()
+	}
+	if sent := thread.GetMemory("sent"); sent != "hi" {
+		t.Fatalf("unexpected memory of thread: %v", sent)
+	}
+}
+
+func TestRunContextDone(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	slow := bp.AddNeuron(func(bc processor.BrainContext) error {
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	})
+	_, _ = bp.AddEntryLinkTo(slow)
+	_, _ = bp.AddEndLinkFrom(slow)
+	brain := brainlocal.BuildBrain(bp)
+	defer brain.Shutdown(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	thread, err := brain.Run(ctx, "slow", nil)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	// the run goes on
+	thread.Wait()
+	if state := thread.GetState(); state != core.BrainStateSleeping {
+		t.Fatalf("expected thread sleeping, got %s", state)
+	}
+}
+
+func TestThreadMemoryIsolatedFromBrain(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint())
+	defer brain.Shutdown(context.Background())
+
+	thread, err := brain.Run(context.Background(), "u1", map[string]any{"question": "hi"})
+	if err != nil {
+		t.Fatalf("run error: %s", err)
+	}
+	// keys of brain which look like the keys of threads in the store
+	for _, key := range []string{"thread:u1:string:answer", "thread:2:u1:string:answer", "root:answer"} {
+		if err = brain.SetMemory(key, "forged"); err != nil {
+			t.Fatalf("set memory error: %s", err)
+		}
+		if brain.GetMemory(key) != "forged" {
+			t.Fatalf("unexpected memory of brain %s: %v", key, brain.GetMemory(key))
+		}
+	}
+	if answer := thread.GetMemory("answer"); answer != "echo: hi" {
+		t.Fatalf("memory of thread is overwritten by brain: %v", answer)
+	}
+
+	brain.ClearMemory()
+	if brain.ExistMemory("root:answer") {
+		t.Fatal("memory of brain should be cleared")
+	}
+	if answer := thread.GetMemory("answer"); answer != "echo: hi" {
+		t.Fatalf("memory of thread is cleared by brain: %v", answer)
+	}
+	if keys, _ := thread.ListMemoryKeys(); len(keys) != 3 {
+		t.Fatalf("unexpected memory keys of thread: %v", keys)
+	}
+}
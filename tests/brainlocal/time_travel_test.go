@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/memstore"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestForkFromSnapshot(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	prepare := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("query", "bad query")
+	})
+	tool := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("result", fmt.Sprintf("result of %s", bc.GetMemory("query")))
+	})
+	_, _ = bp.AddEntryLinkTo(prepare)
+	toolLink, _ := bp.AddLink(prepare, tool)
+	_, _ = bp.AddEndLinkFrom(tool)
+
+	brain := brainlocal.BuildBrain(bp, brainlocal.WithSnapshots(0))
+	_ = brain.Entry()
+	brain.Wait()
+	if result := brain.GetMemory("result"); result != "result of bad query" {
+		t.Fatalf("unexpected result: %v", result)
+	}
+
+	snapshots, err := brain.ListSnapshots()
+	if err != nil {
+		t.Fatalf("list snapshots error: %s", err)
+	}
+	// rewind to the step just before the tool is called
+	snapshotID := 0
+	for _, snap := range snapshots {
+		if snap.Step == fmt.Sprintf("link link_ready %s", toolLink.GetID()) {
+			snapshotID = snap.ID
+		}
+	}
+	if snapshotID == 0 {
+		t.Fatalf("snapshot before tool call not found in %d snapshots", len(snapshots))
+	}
+
+	forked, err := brain.Fork(snapshotID)
+	if err != nil {
+		t.Fatalf("fork error: %s", err)
+	}
+	if forked.GetMemory("result") != nil {
+		t.Fatalf("forked brain should not have the result of tool")
+	}
+	_ = forked.SetMemory("query", "good query")
+	if err = forked.Continue(); err != nil {
+		t.Fatalf("continue error: %s", err)
+	}
+	forked.Wait()
+
+	if result := forked.GetMemory("result"); result != "result of good query" {
+		t.Fatalf("unexpected result of forked brain: %v", result)
+	}
+	if result := brain.GetMemory("result"); result != "result of bad query" {
+		t.Fatalf("origin brain should not be changed by fork: %v", result)
+	}
+	forked.Shutdown(context.Background())
+	brain.Shutdown(context.Background())
+}
+
+func TestSnapshotDeepCopy(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	prepare := bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("tags", map[string][]string{"query": {"bad"}})
+	})
+	tool := bp.AddNeuron(func(bc processor.BrainContext) error {
+		// the value in memory is changed in place after the snapshot of prepare is taken
+		tags := bc.GetMemory("tags").(map[string][]string)
+		tags["query"][0] = "changed"
+		tags["tool"] = []string{"called"}
+		return nil
+	})
+	_, _ = bp.AddEntryLinkTo(prepare)
+	toolLink, _ := bp.AddLink(prepare, tool)
+	_, _ = bp.AddEndLinkFrom(tool)
+
+	brain := brainlocal.BuildBrain(bp, brainlocal.WithSnapshots(0))
+	defer brain.Shutdown(context.Background())
+	_ = brain.Entry()
+	brain.Wait()
+
+	snapshots, err := brain.ListSnapshots()
+	if err != nil {
+		t.Fatalf("list snapshots error: %s", err)
+	}
+	snapshotID := 0
+	for _, snap := range snapshots {
+		if snap.Step == fmt.Sprintf("link link_ready %s", toolLink.GetID()) {
+			snapshotID = snap.ID
+		}
+	}
+	forked, err := brain.Fork(snapshotID)
+	if err != nil {
+		t.Fatalf("fork error: %s", err)
+	}
+	defer forked.Shutdown(context.Background())
+
+	tags := forked.GetMemory("tags").(map[string][]string)
+	if len(tags) != 1 || tags["query"][0] != "bad" {
+		t.Fatalf("snapshot should not be changed by writes into memory values, got %v", tags)
+	}
+}
+
+func TestListSnapshotsDisabled(t *testing.T) {
+	brain := brainlocal.BuildBrain(rModel.NewBlueprint())
+	if _, err := brain.ListSnapshots(); err == nil {
+		t.Fatal("expected error when listing snapshots which are not enabled")
+	}
+}
+
+func TestForkWithMemoryStore(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	_, _ = bp.AddEntryLinkTo(bp.AddNeuron(func(bc processor.BrainContext) error {
+		return bc.SetMemory("steps", 1)
+	}))
+	// steps are summed by the reducer
+	bp.SetMemoryReducer("steps", func(old, new any) (any, error) {
+		steps, _ := old.(int)
+		return steps + new.(int), nil
+	})
+
+	store := memstore.NewMap()
+	brain := brainlocal.BuildBrain(bp, brainlocal.WithSnapshots(0), brainlocal.WithMemoryStore(func() (core.MemoryStore, error) {
+		return store, nil
+	}))
+	defer brain.Shutdown(context.Background())
+	_ = brain.Entry()
+	brain.Wait()
+
+	snapshots, err := brain.ListSnapshots()
+	if err != nil || len(snapshots) == 0 {
+		t.Fatalf("expected snapshots, got %d, error: %v", len(snapshots), err)
+	}
+	forked, err := brain.Fork(snapshots[len(snapshots)-1].ID)
+	if err != nil {
+		t.Fatalf("fork error: %s", err)
+	}
+	defer forked.Shutdown(context.Background())
+	// the memories are restored without the reducers
+	if steps := forked.GetMemory("steps"); steps != 1 {
+		t.Fatalf("expected memory restored as it is, got %v", steps)
+	}
+
+	_ = forked.SetMemory("steps", 10)
+	if steps, _, _ := store.Get("steps"); steps != 1 {
+		t.Fatalf("expected store of brain not written by fork, got %v", steps)
+	}
+	if steps := brain.GetMemory("steps"); steps != 1 {
+		t.Fatalf("expected memory of brain not changed by fork, got %v", steps)
+	}
+}
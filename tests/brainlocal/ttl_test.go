@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// waitExpired waits for the change of key expired by the sweeper
+func waitExpired(t *testing.T, changes <-chan processor.MemoryChange, key any) processor.MemoryChange {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case c := <-changes:
+			if c.Expired && c.Key == key {
+				return c
+			}
+		case <-timeout:
+			t.Fatalf("expected memory %v expired", key)
+		}
+	}
+}
+
+func TestMemoryTTL(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint(), brainlocal.WithMemorySweepInterval(20*time.Millisecond))
+	defer brain.Shutdown(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := brain.WatchMemory(ctx)
+
+	if err := brain.SetMemoryWithTTL("session", "s", 0); err == nil {
+		t.Error("expected error of ttl 0")
+	}
+	_ = brain.SetMemoryWithTTL("session", "s", 50*time.Millisecond)
+	_ = brain.SetMemoryWithTTL("token", "t", 50*time.Millisecond)
+	// set again without TTL, it never expires
+	_ = brain.SetMemory("token", "t2")
+	if session := brain.GetMemory("session"); session != "s" {
+		t.Fatalf("expected memory before expiry, got %v", session)
+	}
+
+	c := waitExpired(t, changes, "session")
+	if c.OldValue != "s" || c.NewValue != nil {
+		t.Errorf("unexpected expiry: %+v", c)
+	}
+	if brain.ExistMemory("session") {
+		t.Error("expected expired memory deleted")
+	}
+	if token := brain.GetMemory("token"); token != "t2" {
+		t.Errorf("expected memory set without TTL kept, got %v", token)
+	}
+	if n := brain.ExpiredMemoryCount(); n != 1 {
+		t.Errorf("expected 1 expired memory, got %d", n)
+	}
+}
+
+func TestMemoryTTLLazy(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint(), brainlocal.WithMemorySweepInterval(time.Hour))
+	defer brain.Shutdown(context.Background())
+
+	_ = brain.SetMemoryWithTTL("session", "s", 20*time.Millisecond)
+	_ = brain.SetMemory("question", "hi")
+	time.Sleep(40 * time.Millisecond)
+
+	// the expired memory is not read before the sweeper deletes it
+	if brain.ExistMemory("session") {
+		t.Error("expected expired memory not read")
+	}
+	if keys, _ := brain.ListMemoryKeys(); len(keys) != 1 || keys[0] != "question" {
+		t.Errorf("expected expired memory not listed, got %v", keys)
+	}
+	if dump, _ := brain.DumpMemory(); len(dump) != 1 {
+		t.Errorf("expected expired memory not dumped, got %v", dump)
+	}
+	if n := brain.ExpiredMemoryCount(); n != 0 {
+		t.Errorf("expected no memory swept, got %d", n)
+	}
+}
+
+func TestMemoryTTLCache(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint(),
+		brainlocal.WithMemoryCache(1e4, 1<<20), brainlocal.WithMemorySweepInterval(20*time.Millisecond))
+	defer brain.Shutdown(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := brain.WatchMemory(ctx)
+
+	_ = brain.SetMemoryWithTTL("session", "s", 50*time.Millisecond)
+	if c := waitExpired(t, changes, "session"); c.Evicted {
+		t.Errorf("expected memory expired, not evicted: %+v", c)
+	}
+	if brain.ExistMemory("session") {
+		t.Error("expected expired memory deleted")
+	}
+}
@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/memstore"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestCompareAndSwapMemory(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	const parallel = 8
+	for i := 0; i < parallel; i++ {
+		n := bp.AddNeuron(func(bc processor.BrainContext) error {
+			// increase the counter by CAS retries
+			for {
+				count, _ := bc.GetMemory("count").(int)
+				var old any
+				if bc.ExistMemory("count") {
+					old = count
+				}
+				swapped, err := bc.CompareAndSwapMemory("count", old, count+1)
+				if err != nil || swapped {
+					return err
+				}
+			}
+		})
+		_, _ = bp.AddEntryLinkTo(n)
+	}
+
+	brain := brainlocal.BuildBrain(bp, brainlocal.WithNeuronWorkerNum(parallel))
+	defer brain.Shutdown(context.Background())
+	_ = brain.Entry()
+	brain.Wait()
+
+	if count := brain.GetMemory("count"); count != parallel {
+		t.Fatalf("expected count %d, got %v", parallel, count)
+	}
+	if swapped, _ := brain.CompareAndSwapMemory("count", 0, 1); swapped {
+		t.Fatal("expected no swap with a stale old value")
+	}
+}
+
+func TestUpdateMemory(t *testing.T) {
+	brain := brainlocal.BuildBrain(newEchoBlueprint())
+	defer brain.Shutdown(context.Background())
+	_ = brain.SetMemory("from", 10, "to", 0)
+
+	// a failed transaction is rolled back
+	errRollback := errors.New("rollback")
+	err := brain.UpdateMemory(func(tx processor.MemoryTx) error {
+		_ = tx.SetMemory("from", 5, "to", 5)
+		if tx.GetMemory("from") != 5 {
+			t.Error("expected transaction sees its own writes")
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("expected rollback error, got %v", err)
+	}
+	if brain.GetMemory("from") != 10 || brain.GetMemory("to") != 0 {
+		t.Fatalf("expected memories not changed, got from %v, to %v", brain.GetMemory("from"), brain.GetMemory("to"))
+	}
+
+	// readers never see a part of a transaction
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = brain.UpdateMemory(func(tx processor.MemoryTx) error {
+				from, _ := tx.GetMemory("from").(int)
+				to, _ := tx.GetMemory("to").(int)
+				return tx.SetMemory("from", from-1, "to", to+1)
+			})
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		_ = brain.UpdateMemory(func(tx processor.MemoryTx) error {
+			from, _ := tx.GetMemory("from").(int)
+			to, _ := tx.GetMemory("to").(int)
+			if from+to != 10 {
+				t.Errorf("unexpected total %d", from+to)
+			}
+			return nil
+		})
+	}
+
+	if err = brain.UpdateMemory(func(tx processor.MemoryTx) error {
+		tx.DeleteMemory("to")
+		if tx.ExistMemory("to") {
+			t.Error("expected memory deleted in transaction")
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("update memory error: %s", err)
+	}
+	if brain.ExistMemory("to") {
+		t.Fatal("expected memory deleted")
+	}
+}
+
+// failingStore fails to set memory of key "broken"
+type failingStore struct {
+	*memstore.Map
+}
+
+func (s failingStore) Set(key, value any) error {
+	if key == "broken" {
+		return errors.New("store is broken")
+	}
+
+	return s.Map.Set(key, value)
+}
+
+func TestUpdateMemoryRollback(t *testing.T) {
+	store := failingStore{Map: memstore.NewMap()}
+	brain := brainlocal.BuildBrain(rModel.NewBlueprint(), brainlocal.WithMemoryStore(func() (core.MemoryStore, error) {
+		return store, nil
+	}))
+	defer brain.Shutdown(context.Background())
+	_ = brain.SetMemory("balance", 100, "history", "opened")
+
+	err := brain.UpdateMemory(func(tx processor.MemoryTx) error {
+		if err := tx.SetMemory("balance", 50, "spent", 50); err != nil {
+			return err
+		}
+		tx.DeleteMemory("history")
+		return tx.SetMemory("broken", true)
+	})
+	if err == nil {
+		t.Fatalf("expected commit failed")
+	}
+
+	// none of the writes is kept
+	if balance := brain.GetMemory("balance"); balance != 100 {
+		t.Fatalf("expected balance rolled back, got %v", balance)
+	}
+	if history := brain.GetMemory("history"); history != "opened" {
+		t.Fatalf("expected history rolled back, got %v", history)
+	}
+	if brain.ExistMemory("spent") || brain.ExistMemory("broken") {
+		t.Fatalf("expected new memories rolled back")
+	}
+	if snap, _ := store.Snapshot(); len(snap) != 2 {
+		t.Fatalf("expected store rolled back, got %v", snap)
+	}
+}
@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+// newLoopBrain builds a brain which never goes to sleep, as a voice call loop
+func newLoopBrain() *brainlocal.BrainLocal {
+	bp := rModel.NewBlueprint()
+	turn := bp.AddNeuron(func(bc processor.BrainContext) error {
+		time.Sleep(5 * time.Millisecond)
+		turns, _ := bc.GetMemory("turns").(int)
+		return bc.SetMemory("turns", turns+1)
+	})
+	_, _ = bp.AddEntryLinkTo(turn)
+	_, _ = bp.AddLink(turn, turn)
+
+	return brainlocal.BuildBrain(bp)
+}
+
+func TestWaitContext(t *testing.T) {
+	brain := newLoopBrain()
+	defer brain.Shutdown(context.Background())
+	_ = brain.Entry()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := brain.WaitContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	sleeping := brainlocal.BuildBrain(newEchoBlueprint())
+	defer sleeping.Shutdown(context.Background())
+	_ = sleeping.EntryWithMemory("question", "hi")
+	if err := sleeping.WaitContext(context.Background()); err != nil {
+		t.Fatalf("wait error: %s", err)
+	}
+}
+
+func TestWaitFor(t *testing.T) {
+	brain := newLoopBrain()
+	defer brain.Shutdown(context.Background())
+	_ = brain.Entry()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	value, err := brain.WaitFor(ctx, "turns", func(value any) bool {
+		turns, _ := value.(int)
+		return turns >= 5
+	})
+	if err != nil {
+		t.Fatalf("wait for error: %s", err)
+	}
+	if turns, _ := value.(int); turns < 5 {
+		t.Fatalf("expected at least 5 turns, got %v", value)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = brain.WaitFor(ctx, "never", func(value any) bool { return value != nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel"
+	"github.com/Rovanta/rmodel/brainlocal"
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/processor"
+)
+
+func TestWatchMemory(t *testing.T) {
+	bp := rModel.NewBlueprint()
+	writer := bp.AddNeuron(func(bc processor.BrainContext) error {
+		// wait listener starts to watch
+		time.Sleep(50 * time.Millisecond)
+		if err := bc.SetMemory("status", "searching"); err != nil {
+			return err
+		}
+		return bc.SetMemory("status", "done")
+	}, core.WithNeuronID("writer"))
+	// listener reacts to the input from the other branch
+	listener := bp.AddNeuron(func(bc processor.BrainContext) error {
+		ctx, cancel := context.WithTimeout(bc, time.Second)
+		defer cancel()
+		for change := range bc.WatchMemory(ctx, "status") {
+			if change.NewValue == "done" {
+				return bc.SetMemory("heard", change.NeuronID, "before", change.OldValue)
+			}
+		}
+		return nil
+	})
+	_, _ = bp.AddEntryLinkTo(writer)
+	_, _ = bp.AddEntryLinkTo(listener)
+	_, _ = bp.AddEndLinkFrom(listener)
+
+	brain := brainlocal.BuildBrain(bp)
+	defer brain.Shutdown(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := brain.WatchMemory(ctx)
+
+	_ = brain.Entry()
+	brain.Wait()
+	if heard, before := brain.GetMemory("heard"), brain.GetMemory("before"); heard != "writer" || before != "searching" {
+		t.Fatalf("unexpected change heard by listener: %v, %v", heard, before)
+	}
+
+	brain.DeleteMemory("status")
+	expected := []processor.MemoryChange{
+		{Key: "status", NewValue: "searching", NeuronID: "writer"},
+		{Key: "status", OldValue: "searching", NewValue: "done", NeuronID: "writer"},
+	}
+	for _, e := range expected {
+		if c := <-changes; c != e {
+			t.Fatalf("expected change %+v, got %+v", e, c)
+		}
+	}
+	// changes of heard and before, then the deletion
+	<-changes
+	<-changes
+	if c := <-changes; c.Key != "status" || c.OldValue != "done" || c.NewValue != nil || c.NeuronID != "" {
+		t.Fatalf("unexpected deletion: %+v", c)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-changes:
+		if ok {
+			t.Fatalf("expected no more changes")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected channel closed after watch cancelled")
+	}
+}
+
+func TestWatchMemoryDropsOldest(t *testing.T) {
+	brain := brainlocal.BuildBrain(rModel.NewBlueprint())
+	defer brain.Shutdown(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := brain.WatchMemory(ctx, "counter")
+
+	// the watcher does not receive while the changes are written
+	const writes = 3000
+	for i := 1; i <= writes; i++ {
+		if err := brain.SetMemory("counter", i); err != nil {
+			t.Fatalf("set memory error: %s", err)
+		}
+	}
+
+	total, dropped := 0, 0
+	for total < writes {
+		select {
+		case c := <-changes:
+			total += 1 + c.Dropped
+			dropped += c.Dropped
+			if total == writes && c.NewValue != writes {
+				t.Fatalf("expected the last change kept, got %+v", c)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %d changes counted, got %d", writes, total)
+		}
+	}
+	if total != writes || dropped == 0 {
+		t.Fatalf("expected changes dropped and counted, total %d, dropped %d", total, dropped)
+	}
+}
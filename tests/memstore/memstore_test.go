@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/memstore"
+	"github.com/Rovanta/rmodel/memstore/memstoretest"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestMap(t *testing.T) {
+	memstoretest.TestMemoryStore(t, func(t *testing.T) core.MemoryStore {
+		return memstore.NewMap()
+	})
+}
+
+func TestCache(t *testing.T) {
+	memstoretest.TestMemoryStore(t, func(t *testing.T) core.MemoryStore {
+		s, err := memstore.NewCache(1e4, 1<<20)
+		if err != nil {
+			t.Fatalf("new cache error: %s", err)
+		}
+		return s
+	})
+}
+
+func TestCacheTTL(t *testing.T) {
+	s, err := memstore.NewCache(1e4, 1<<20)
+	if err != nil {
+		t.Fatalf("new cache error: %s", err)
+	}
+	defer s.Close()
+
+	_ = s.SetWithTTL("short", "gone", 50*time.Millisecond)
+	_ = s.SetWithTTL("long", "kept", time.Hour)
+	if v, ok, _ := s.Get("short"); !ok || v != "gone" {
+		t.Fatalf("expected memory before expiry, got %v, %v", v, ok)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok, _ := s.Get("short"); ok {
+		t.Error("expected memory expired")
+	}
+	if v, ok, _ := s.Get("long"); !ok || v != "kept" {
+		t.Errorf("expected memory not expired, got %v, %v", v, ok)
+	}
+}
+
+func newSQLite(t *testing.T, brainID string) *memstore.SQLite {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "memory.db")+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("open database error: %s", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	s, err := memstore.NewSQLite(db, brainID)
+	if err != nil {
+		t.Fatalf("new sqlite error: %s", err)
+	}
+
+	return s
+}
+
+func TestSQLite(t *testing.T) {
+	memstoretest.TestMemoryStore(t, func(t *testing.T) core.MemoryStore {
+		return newSQLite(t, "conformance")
+	})
+}
+
+func TestSQLiteTTL(t *testing.T) {
+	s := newSQLite(t, "ttl")
+	defer s.Close()
+
+	_ = s.SetWithTTL("short", "gone", 50*time.Millisecond)
+	_ = s.SetWithTTL("long", "kept", time.Hour)
+	if v, ok, _ := s.Get("short"); !ok || v != "gone" {
+		t.Fatalf("expected memory before expiry, got %v, %v", v, ok)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok, _ := s.Get("short"); ok {
+		t.Error("expected memory expired")
+	}
+	if keys, _ := s.Keys(); len(keys) != 1 || keys[0] != "long" {
+		t.Errorf("expected only the memory not expired, got %v", keys)
+	}
+}
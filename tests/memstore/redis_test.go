@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Rovanta/rmodel/core"
+	"github.com/Rovanta/rmodel/memstore"
+	"github.com/Rovanta/rmodel/memstore/memstoretest"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedisClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	return mr, client
+}
+
+func TestRedis(t *testing.T) {
+	memstoretest.TestMemoryStore(t, func(t *testing.T) core.MemoryStore {
+		_, client := newRedisClient(t)
+		return memstore.NewRedis(client, "brain")
+	})
+}
+
+func TestRedisPrefix(t *testing.T) {
+	mr, client := newRedisClient(t)
+	a := memstore.NewRedis(client, "a")
+	b := memstore.NewRedis(client, "b")
+	_ = a.Set("question", "hi")
+	_ = b.Set("question", 1)
+
+	if v, _, _ := a.Get("question"); v != "hi" {
+		t.Fatalf("expected memory of brain a, got %v", v)
+	}
+	if keys, _ := b.Keys(); len(keys) != 1 {
+		t.Fatalf("expected one key of brain b, got %v", keys)
+	}
+	// the memory can be read by the other languages
+	if typ := mr.HGet("rmodel:a:memory:string:question", "type"); typ != "string" {
+		t.Fatalf("unexpected type tag %q", typ)
+	}
+}
+
+func TestRedisTTL(t *testing.T) {
+	mr, client := newRedisClient(t)
+	s := memstore.NewRedis(client, "brain", memstore.WithRedisTTL(time.Minute))
+	_ = s.Set("draft", "v1")
+	_ = s.SetWithTTL("tool_result", "ok", time.Second)
+
+	mr.FastForward(2 * time.Second)
+	if _, ok, _ := s.Get("tool_result"); ok {
+		t.Fatal("expected memory expired")
+	}
+	if _, ok, _ := s.Get("draft"); !ok {
+		t.Fatal("expected memory not expired yet")
+	}
+	mr.FastForward(time.Minute)
+	if _, ok, _ := s.Get("draft"); ok {
+		t.Fatal("expected memory expired by default TTL")
+	}
+}
+
+func TestRedisOnChange(t *testing.T) {
+	_, client := newRedisClient(t)
+	a := memstore.NewRedis(client, "brain")
+	b := memstore.NewRedis(client, "brain")
+	defer a.Close()
+
+	changes := make(chan core.MemoryStoreChange, 10)
+	a.OnChange(func(change core.MemoryStoreChange) {
+		changes <- change
+	})
+	_ = a.Set("own", 1)
+	_ = b.Set("count", 2)
+	_ = b.Delete("count")
+	_ = b.Clear()
+
+	expected := []core.MemoryStoreChange{
+		{Key: "count", Value: 2},
+		{Key: "count", Deleted: true},
+		{Cleared: true},
+	}
+	for _, want := range expected {
+		select {
+		case got := <-changes:
+			if got != want {
+				t.Fatalf("expected change %+v, got %+v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected change %+v", want)
+		}
+	}
+}